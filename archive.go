@@ -0,0 +1,132 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// RotateAndOpen writes all completed writes in the buffer to the log
+// file, rotates it, and returns a reader positioned at the start of the
+// rotated log file, so the caller may process the file it just rotated.
+// Streaming the rotated log file to ArchiveWriter, which removes it, and
+// remembering to remove it when RemoveOnClose is true, are deferred
+// until the reader is closed, so the file is not removed while it is
+// being read. A rotated log file whose reader is closed after the
+// LogWriter is closed is not removed by RemoveOnClose. RotateAndOpen
+// returns an error when the log file is empty, because it is not
+// rotated then.
+func (lw *LogWriter) RotateAndOpen() (io.ReadCloser, error) {
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.cfg.NoRotate {
+		return nil, errors.New("cannot rotate log file when NoRotate is true")
+	}
+
+	lw.holdArchive, lw.heldArchive = true, ""
+	err := lw.rotate()
+	archivePath := lw.heldArchive
+	lw.holdArchive, lw.heldArchive = false, ""
+
+	if archivePath == "" {
+		if err == nil {
+			err = errors.New("cannot open rotated log file: log file was not rotated")
+		}
+		return nil, err
+	}
+	if err != nil {
+		lw.releaseArchive(archivePath)
+		return nil, err
+	}
+
+	fh, err := os.Open(archivePath)
+	if err != nil {
+		lw.releaseArchive(archivePath)
+		return nil, fmt.Errorf("cannot open rotated log file: %w", err)
+	}
+	return &heldArchive{File: fh, lw: lw, path: archivePath}, nil
+}
+
+// heldArchive is the reader returned by RotateAndOpen, which releases
+// the rotated log file when it is closed.
+type heldArchive struct {
+	*os.File
+	lw     *LogWriter
+	path   string
+	closed bool
+}
+
+// Close closes the rotated log file, then releases it to be streamed to
+// ArchiveWriter, or removed by RemoveOnClose.
+func (h *heldArchive) Close() error {
+	if h.closed {
+		return os.ErrClosed
+	}
+	h.closed = true
+	err := h.File.Close()
+
+	h.lw.lock()
+	h.lw.releaseArchive(h.path)
+	h.lw.unlock()
+	return err
+}
+
+// releaseArchive streams the rotated log file at archivePath to
+// ArchiveWriter, or remembers it to be removed when RemoveOnClose is
+// true, and returns its path, which is empty once it has been streamed.
+// It is invoked while the lock is held.
+func (lw *LogWriter) releaseArchive(archivePath string) string {
+	if lw.cfg.ArchiveWriter != nil {
+		if err := lw.streamArchive(archivePath); err != nil {
+			lw.reportError(err)
+		} else {
+			archivePath = ""
+		}
+	}
+	if lw.cfg.RemoveOnClose && archivePath != "" {
+		lw.produced = append(lw.produced, archivePath)
+	}
+	return archivePath
+}
+
+// streamArchive copies the contents of the rotated log file at
+// archivePath to the writer returned by ArchiveWriter, then removes the
+// rotated log file. When it returns an error, the rotated log file is
+// kept.
+func (lw *LogWriter) streamArchive(archivePath string) error {
+	if lw.tracing() {
+		lw.trace("streamArchive", "path", archivePath)
+	}
+
+	var w io.WriteCloser
+	var err error
+	if perr := callSafely("ArchiveWriter", func() { w, err = lw.cfg.ArchiveWriter(filepath.Base(archivePath)) }); perr != nil {
+		return perr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot create archive writer, so kept rotated log file: %w", err)
+	}
+
+	fh, err := os.Open(archivePath)
+	if err != nil {
+		_ = w.Close()
+		return fmt.Errorf("cannot open rotated log file to stream it, so kept it: %w", err)
+	}
+	copied, err := io.Copy(w, fh)
+	lw.stats.BytesMoved += copied
+	_ = fh.Close()
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("cannot stream rotated log file, so kept it: %w", err)
+	}
+
+	if err = os.Remove(archivePath); err != nil {
+		return fmt.Errorf("cannot remove streamed rotated log file: %w", err)
+	}
+	return nil
+}
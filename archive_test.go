@@ -0,0 +1,316 @@
+package golw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchiveDirFunc(t *testing.T) {
+	directory := t.TempDir()
+	absolute := t.TempDir()
+
+	type call struct {
+		stamp string
+		seq   int
+	}
+	var calls []call
+
+	lw, err := NewLogWriter(&Config{
+		ArchiveDirFunc: func(stamp string, seq int) string {
+			calls = append(calls, call{stamp, seq})
+			switch seq % 3 {
+			case 1:
+				return "disk1" // relative to Directory
+			case 2:
+				return absolute
+			default:
+				return "" // remain in Directory
+			}
+		},
+		BaseNamePrefix: "shard",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		MaxBytes:       10,
+		TimeFormatter:  func(time.Time) string { return "20220322T120000" },
+	})
+	ensureError(t, err)
+
+	for i := 1; i <= 4; i++ {
+		_, err = fmt.Fprintf(lw, "line %d\n", i)
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Close())
+
+	if got, want := len(calls), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, c := range calls {
+		if c.stamp != "20220322T120000" || c.seq != i+1 {
+			t.Errorf("GOT: %v; WANT: %v", c, call{"20220322T120000", i + 1})
+		}
+	}
+
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "disk1", "shard.20220322T120000.log")), []byte("line 1\n"))
+	ensureBuffer(t, readFile(t, filepath.Join(absolute, "shard.20220322T120000.log")), []byte("line 2\n"))
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "shard.20220322T120000.log")), []byte("line 3\n"))
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "shard.log")), []byte("line 4\n"))
+}
+
+func TestCopyFile(t *testing.T) {
+	directory := t.TempDir()
+	source := filepath.Join(directory, "source.log")
+	target := filepath.Join(directory, "target.log")
+
+	ensureError(t, os.WriteFile(source, []byte("line 1\n"), 0644))
+	modTime := time.Date(2022, 3, 22, 12, 0, 0, 0, time.UTC)
+	ensureError(t, os.Chtimes(source, modTime, modTime))
+
+	_, err := copyFile(source, target, 0600)
+	ensureError(t, err)
+
+	ensureBuffer(t, readFile(t, target), []byte("line 1\n"))
+	st, err := os.Stat(target)
+	ensureError(t, err)
+	if got, want := st.ModTime(), modTime; !got.Equal(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestPostRotate(t *testing.T) {
+	t.Run("moves archived file", func(t *testing.T) {
+		directory := t.TempDir()
+		canonical := t.TempDir()
+
+		var finalPaths []string
+		var events []RotationEvent
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "post",
+			BufferSizeMax:     -1,
+			Directory:         directory,
+			MaxBytes:          10,
+			WriteSidecarMeta:  true,
+			OnArchiveComplete: func(finalPath string) { finalPaths = append(finalPaths, finalPath) },
+			PostRotate: func(path string) (string, error) {
+				newPath := filepath.Join(canonical, "canonical-"+filepath.Base(path))
+				return newPath, os.Rename(path, newPath)
+			},
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+		lw.Subscribe(func(event RotationEvent) { events = append(events, event) })
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		if got, want := len(archivedLogs(t, directory, "post")), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		archives := archivedLogs(t, canonical, "canonical-post")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+		readFile(t, archives[0]+sidecarMetaExtension)
+
+		if got, want := fmt.Sprint(finalPaths), fmt.Sprint(archives); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(events), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := events[0].ArchivePath, archives[0]; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "post",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			PostRotate:     func(path string) (string, error) { return path, nil },
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		if got, want := len(archivedLogs(t, directory, "post")), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "post",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			PostRotate:     func(path string) (string, error) { return "", fmt.Errorf("upload failed") },
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate(), "post process", "upload failed")
+
+		// The replacement log file was opened regardless.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "post.log")), []byte("line 2\n"))
+	})
+}
+
+// archiveBuffer is an in-memory io.WriteCloser that records whether it
+// was closed.
+type archiveBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *archiveBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestArchiveWriter(t *testing.T) {
+	directory := t.TempDir()
+
+	var names []string
+	var buffers []*archiveBuffer
+	var reported []error
+	lw, err := NewLogWriter(&Config{
+		ArchiveWriter: func(name string) (io.WriteCloser, error) {
+			names = append(names, name)
+			if len(names) == 2 {
+				return nil, errors.New("connection refused")
+			}
+			buffer := new(archiveBuffer)
+			buffers = append(buffers, buffer)
+			return buffer, nil
+		},
+		BaseNamePrefix:  "stream",
+		BufferSizeMax:   -1,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        10,
+		OnError:         func(err error) { reported = append(reported, err) },
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	var events []RotationEvent
+	lw.Subscribe(func(event RotationEvent) { events = append(events, event) })
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n"} {
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Close())
+
+	if got, want := len(names), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, buffer := range buffers {
+		if !buffer.closed {
+			t.Errorf("buffer %d: GOT: open; WANT: closed", i)
+		}
+	}
+	ensureBuffer(t, buffers[0].Bytes(), []byte("line 1\n"))
+	ensureBuffer(t, buffers[1].Bytes(), []byte("line 3\n"))
+
+	// Only the rotated log file that could not be streamed is kept.
+	archived := archivedLogs(t, directory, "stream")
+	if got, want := len(archived), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := filepath.Base(archived[0]), names[1]; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archived[0]), []byte("line 2\n"))
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 4\n"))
+
+	if got, want := len(reported), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, reported[0], "kept rotated log file", "connection refused")
+
+	if got, want := len(events), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i, want := range []string{"", archived[0], ""} {
+		if got := events[i].ArchivePath; got != want {
+			t.Errorf("event %d: GOT: %q; WANT: %q", i, got, want)
+		}
+	}
+}
+
+func TestRotateAndOpen(t *testing.T) {
+	directory := t.TempDir()
+
+	var buffers []*archiveBuffer
+	lw, err := NewLogWriter(&Config{
+		ArchiveWriter: func(string) (io.WriteCloser, error) {
+			buffer := new(archiveBuffer)
+			buffers = append(buffers, buffer)
+			return buffer, nil
+		},
+		BaseNamePrefix: "held",
+		Directory:      directory,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	_, err = lw.RotateAndOpen()
+	ensureError(t, err, "not rotated")
+
+	_, err = lw.Write([]byte("line 1\nline 2\n"))
+	ensureError(t, err)
+
+	rc, err := lw.RotateAndOpen()
+	ensureError(t, err)
+
+	// The rotated log file is neither streamed nor removed while it is
+	// being read.
+	archives := archivedLogs(t, directory, "held")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(buffers), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	contents, err := io.ReadAll(rc)
+	ensureError(t, err)
+	ensureBuffer(t, contents, []byte("line 1\nline 2\n"))
+
+	// Closing the reader releases the rotated log file to be streamed.
+	ensureError(t, rc.Close())
+	ensureError(t, rc.Close(), "closed")
+	if got, want := len(buffers), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, buffers[0].Bytes(), []byte("line 1\nline 2\n"))
+	if got := archivedLogs(t, directory, "held"); len(got) != 0 {
+		t.Errorf("GOT: %v; WANT: streamed log file removed", got)
+	}
+
+	ensureError(t, lw.Close())
+}
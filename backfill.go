@@ -0,0 +1,76 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WriteBackup writes content to a new rotated log file named as the
+// LogWriter would name a log file whose first write happened at time t,
+// without affecting the open log file, and returns its path. It allows
+// tools that import historical logs to backfill rotated log files that
+// FS and ParseBackupName recognize. When IncludeSequence is true, the
+// sequence number in the name is zero, so a backfilled file sorts before
+// any file rotated with the same time stamp. The file is placed in the
+// directory chosen by ArchiveDirFunc when it is set, and a name that is
+// already used is handled according to ClobberPolicy. The content is
+// first copied to a hidden temporary file in the same directory, without
+// holding the lock, so the file only appears under its final name once
+// it is complete, and writes to the LogWriter are not blocked meanwhile.
+// WriteBackup returns an error when IncludeByteRange is true, because a
+// backfilled file has no place in the stream of log files.
+func (lw *LogWriter) WriteBackup(t time.Time, content io.Reader) (string, error) {
+	lw.lock()
+	if lw.cfg.IncludeByteRange {
+		lw.unlock()
+		return "", errors.New("cannot write backup when IncludeByteRange is true")
+	}
+	timeStamp := lw.formatTime(t)
+	directory, err := lw.archiveDirectory(timeStamp, 0)
+	prefix, mode := lw.cfg.BaseNamePrefix, lw.fileModeFor(directory)
+	lw.unlock()
+	if err != nil {
+		return "", err
+	}
+
+	fp, err := os.CreateTemp(directory, "."+prefix+".backfill.*")
+	if err != nil {
+		return "", fmt.Errorf("cannot create backup: %w", err)
+	}
+	temp := fp.Name()
+
+	_, err = io.Copy(fp, content)
+	if err == nil {
+		err = fp.Sync()
+	}
+	if err2 := fp.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(temp, mode)
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+		return "", fmt.Errorf("cannot write backup: %w", err)
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	path, err := lw.archivePath(filepath.Join(directory, lw.archiveStem(timeStamp, 0)), ".log")
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+		return "", fmt.Errorf("cannot write backup: %w", err)
+	}
+	if lw.tracing() {
+		lw.trace("WriteBackup", "path", path)
+	}
+	return path, nil
+}
@@ -0,0 +1,234 @@
+package golw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BackupInfo describes the components of the name of a rotated log
+// file, as decoded by ParseBackupName.
+type BackupInfo struct {
+	// Stamp is the formatted time of the first write to the log file,
+	// or its byte range when IncludeByteRange is true, exactly as it
+	// appears in the file name.
+	Stamp string
+
+	// Time is the time of the first write to the log file decoded from
+	// Stamp, or the zero time when Stamp cannot be decoded, such as
+	// when it was produced by a TimeFormatter whose output cannot be
+	// parsed, or sanitized.
+	Time time.Time
+
+	// Sequence is the rotation sequence number of the log file when
+	// IncludeSequence is true, and zero otherwise.
+	Sequence int
+
+	// Suffix is the number appended to the name of the log file to
+	// make it unique when ClobberPolicy is ClobberSuffix, and zero when
+	// no number was appended.
+	Suffix int
+
+	// ByteStart and ByteEnd are the half open range of offsets in the
+	// stream of all log files covered by the log file when
+	// IncludeByteRange is true, and zero otherwise.
+	ByteStart, ByteEnd int64
+
+	// Compressed is true when the name ends with ".gz", such as a
+	// rotated log file compressed by PostRotate.
+	Compressed bool
+}
+
+// archiveStem returns the base name of a rotated log file, without its
+// extension, given the formatted time of its first write and its
+// rotation sequence number.
+func (lw *LogWriter) archiveStem(timeStamp string, sequence uint64) string {
+	rest := lw.cfg.NameSeparator + timeStamp
+	if lw.cfg.IncludeSequence {
+		rest += "." + formatSequence(sequence)
+	}
+	prefix, shortened := fitPrefix(lw.cfg.BaseNamePrefix, rest+".log", lw.cfg.MaxNameBytes)
+	if shortened {
+		lw.reportShortenedName(prefix + rest + ".log")
+	}
+	return prefix + rest
+}
+
+// reportShortenedName reports to OnError the first time BaseNamePrefix
+// is shortened to respect MaxNameBytes, in the file name name.
+func (lw *LogWriter) reportShortenedName(name string) {
+	if lw.nameShortened {
+		return
+	}
+	lw.nameShortened = true
+	lw.reportError(fmt.Errorf("cannot fit BaseNamePrefix in file names of at most %d bytes, shortened it in %q", lw.cfg.MaxNameBytes, name))
+}
+
+// ParseBackupName decodes the name of a log file rotated by a
+// LogWriter created with this LogWriter's configuration, returning its
+// components, and true when name matches the naming scheme.
+func (lw *LogWriter) ParseBackupName(name string) (BackupInfo, bool) {
+	lw.lock()
+	cfg := lw.cfg
+	if !lw.customTimeFormatter {
+		// Parse time stamps strictly, because the format is known.
+		cfg.TimeFormatter = nil
+	}
+	lw.unlock()
+	return ParseBackupName(&cfg, name)
+}
+
+// ParseBackupName decodes the name of a log file rotated by a
+// LogWriter created with cfg, which is the inverse of how the LogWriter
+// names the log files it rotates, returning the components of the name,
+// and true when name matches the naming scheme. The name may include
+// its directory, which is ignored, so names of log files moved by
+// ArchiveDirFunc can be decoded as well. The time stamp is decoded
+// using TimeFormat when it is set, and as the default number of
+// nanoseconds since the Unix epoch otherwise. When cfg has a
+// TimeFormatter, the decoded time is only reported when formatting it
+// reproduces the time stamp, and names whose time stamp cannot be
+// decoded are still reported as matching, with a zero Time, as they are
+// when SanitizeTimestamp is true. When ClobberPolicy is ClobberSuffix,
+// a time stamp that ends with a hyphen and digits may be ambiguous, in
+// which case the hyphen and digits are decoded as the suffix. When
+// IncludeByteRange is true, the byte range is decoded instead of a time
+// stamp. A name that ends with ".log.gz", such as a rotated log file
+// compressed by PostRotate, is decoded like the name without ".gz", and
+// reported as Compressed.
+//
+//	info, ok := golw.ParseBackupName(cfg, "server.1647950400000000000.log")
+func ParseBackupName(cfg *Config, name string) (BackupInfo, bool) {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+	prefix := cfg.BaseNamePrefix
+	if prefix == "" {
+		prefix = programBaseNamePrefix(os.Args[0], runtime.GOOS)
+	}
+	separator := cfg.NameSeparator
+	if separator == "" {
+		separator = defaultNameSeparator
+	}
+
+	stem, ok := trimNamePrefix(filepath.Base(name), prefix, separator)
+	if !ok {
+		return BackupInfo{}, false
+	}
+	compressed := strings.HasSuffix(stem, ".log.gz")
+	if compressed {
+		stem = stem[:len(stem)-len(".gz")]
+	}
+	if len(stem) <= len(".log") || !strings.HasSuffix(stem, ".log") {
+		return BackupInfo{}, false
+	}
+	stem = stem[:len(stem)-len(".log")]
+
+	if cfg.ClobberPolicy == ClobberSuffix {
+		if i := strings.LastIndexByte(stem, '-'); i > 0 {
+			if suffix, ok := parseDigits(stem[i+1:]); ok && suffix > 0 {
+				if info, ok := parseBackupStem(cfg, stem[:i]); ok {
+					info.Suffix = suffix
+					info.Compressed = compressed
+					return info, true
+				}
+			}
+		}
+	}
+
+	info, ok := parseBackupStem(cfg, stem)
+	info.Compressed = compressed && ok
+	return info, ok
+}
+
+// parseBackupStem decodes the time stamp and sequence number from stem,
+// which is the name of a rotated log file without its prefix, suffix,
+// and extension.
+func parseBackupStem(cfg *Config, stem string) (BackupInfo, bool) {
+	var info BackupInfo
+
+	if cfg.IncludeSequence {
+		i := strings.LastIndexByte(stem, '.')
+		if i == -1 {
+			return BackupInfo{}, false
+		}
+		sequence, ok := parseDigits(stem[i+1:])
+		if !ok || len(stem)-i-1 < len(formatSequence(0)) {
+			return BackupInfo{}, false
+		}
+		info.Sequence = sequence
+		stem = stem[:i]
+	}
+
+	if stem == "" {
+		return BackupInfo{}, false
+	}
+	info.Stamp = stem
+
+	if cfg.IncludeByteRange {
+		var ok bool
+		if info.ByteStart, info.ByteEnd, ok = parseByteRange(stem); !ok {
+			return BackupInfo{}, false
+		}
+		return info, true
+	}
+
+	var t time.Time
+	var err error
+	if cfg.TimeFormat != "" {
+		t, err = time.Parse(cfg.TimeFormat, stem)
+	} else if nanos, ok := parseDigits(stem); ok {
+		t = time.Unix(0, int64(nanos)).UTC()
+	} else {
+		err = strconv.ErrSyntax
+	}
+
+	if cfg.TimeFormatter != nil || cfg.SanitizeTimestamp {
+		// The time stamp may not be decodable, so only report the
+		// time when it reproduces the time stamp.
+		if err == nil && formatStamp(cfg, t) == stem {
+			info.Time = t.UTC()
+		}
+		return info, true
+	}
+
+	if err != nil {
+		return BackupInfo{}, false
+	}
+	info.Time = t.UTC()
+	return info, true
+}
+
+// formatStamp returns t formatted as cfg would format the time stamp of
+// a rotated log file.
+func formatStamp(cfg *Config, t time.Time) (s string) {
+	formatter := cfg.TimeFormatter
+	if formatter == nil {
+		if cfg.TimeFormat != "" {
+			formatter = makeDateTimeFormatter(cfg.TimeFormat)
+		} else {
+			formatter = nanoDateTimeFormatter
+		}
+	}
+	if cfg.SanitizeTimestamp {
+		formatter = makeSanitizingFormatter(formatter, runtime.GOOS)
+	}
+	if callSafely("TimeFormatter", func() { s = formatter(t) }) != nil {
+		return ""
+	}
+	return s
+}
+
+// parseDigits returns the non-negative int represented by s, and true,
+// when s consists only of decimal digits.
+func parseDigits(s string) (int, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
@@ -0,0 +1,262 @@
+package golw
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBackupName(t *testing.T) {
+	secondsFormatter := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+	cases := map[string]struct {
+		cfg      Config
+		advance  time.Duration // advance is how far the clock moves between rotations
+		wantTime bool          // wantTime is whether the time stamp can be decoded
+	}{
+		"default":           {cfg: Config{}, advance: time.Second, wantTime: true},
+		"IncludeSequence":   {cfg: Config{IncludeSequence: true}, advance: time.Second, wantTime: true},
+		"TimeFormat":        {cfg: Config{TimeFormat: DateTime}, advance: time.Second, wantTime: true},
+		"ClobberSuffix":     {cfg: Config{ClobberPolicy: ClobberSuffix, TimeFormat: DateTime}, wantTime: true},
+		"sequence and time": {cfg: Config{IncludeSequence: true, TimeFormat: DateTime}, wantTime: true},
+		"TimeFormatter":     {cfg: Config{TimeFormatter: secondsFormatter}, advance: time.Second},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			directory := t.TempDir()
+
+			cfg := tc.cfg
+			cfg.BaseNamePrefix = "parse"
+			cfg.BufferSizeMax = -1
+			cfg.Directory = directory
+
+			lw, err := NewLogWriter(&cfg)
+			ensureError(t, err)
+			clock := newTestClock()
+			setClock(lw, clock)
+
+			var times []time.Time
+			for i := 0; i < 3; i++ {
+				times = append(times, clock.Now())
+				_, err = fmt.Fprintf(lw, "line %d\n", i)
+				ensureError(t, err)
+				ensureError(t, lw.Rotate())
+				clock.Advance(tc.advance)
+			}
+			ensureError(t, lw.Close())
+
+			archives := archivedLogs(t, directory, "parse")
+			if got, want := len(archives), len(times); got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+
+			suffixes := make(map[int]int)
+			for i, archive := range archives {
+				for _, parse := range []func(string) (BackupInfo, bool){
+					lw.ParseBackupName,
+					func(name string) (BackupInfo, bool) { return ParseBackupName(&cfg, name) },
+				} {
+					info, ok := parse(archive)
+					if !ok {
+						t.Fatalf("%s: GOT: %v; WANT: %v", archive, ok, true)
+					}
+					if got, want := lw.archiveStem(info.Stamp, uint64(info.Sequence)), lw.archiveStem(lw.cfg.TimeFormatter(times[i]), uint64(i+1)); got != want {
+						t.Errorf("GOT: %v; WANT: %v", got, want)
+					}
+					if tc.wantTime && !info.Time.Equal(times[i]) {
+						t.Errorf("%s: GOT: %v; WANT: %v", archive, info.Time, times[i])
+					}
+					if !tc.wantTime && !info.Time.IsZero() {
+						t.Errorf("%s: GOT: %v; WANT: zero time", archive, info.Time)
+					}
+					suffixes[info.Suffix]++
+				}
+			}
+
+			if cfg.ClobberPolicy == ClobberSuffix {
+				// Each archive is parsed twice, and all are rotated
+				// to the same time stamp, so each after the first
+				// has its own suffix.
+				if got, want := fmt.Sprint(suffixes), "map[0:2 1:2 2:2]"; got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+		})
+	}
+
+	t.Run("not matching", func(t *testing.T) {
+		cfg := &Config{BaseNamePrefix: "parse", IncludeSequence: true}
+		for _, name := range []string{
+			"parse.log",
+			"other.1647950400000000000.000001.log",
+			"parse.1647950400000000000.000001.txt",
+			"parse.1647950400000000000.log",
+			"parse.1647950400000000000.01.log",
+			"parse.yesterday.000001.log",
+			"parse..000001.log",
+			"parse.1647950400000000000.000001.gz",
+			"parse.1647950400000000000.000001.log.gz.corrupt",
+		} {
+			if info, ok := ParseBackupName(cfg, name); ok {
+				t.Errorf("%s: GOT: %#v; WANT: no match", name, info)
+			}
+		}
+	})
+
+	t.Run("compressed", func(t *testing.T) {
+		cfg := &Config{BaseNamePrefix: "parse", ClobberPolicy: ClobberSuffix, IncludeSequence: true}
+		for name, want := range map[string]BackupInfo{
+			"parse.1647950400000000000.000001.log":      {Stamp: "1647950400000000000", Time: time.Unix(1647950400, 0).UTC(), Sequence: 1},
+			"parse.1647950400000000000.000001.log.gz":   {Stamp: "1647950400000000000", Time: time.Unix(1647950400, 0).UTC(), Sequence: 1, Compressed: true},
+			"parse.1647950400000000000.000001-2.log.gz": {Stamp: "1647950400000000000", Time: time.Unix(1647950400, 0).UTC(), Sequence: 1, Suffix: 2, Compressed: true},
+		} {
+			info, ok := ParseBackupName(cfg, name)
+			if !ok {
+				t.Errorf("%s: GOT: %v; WANT: %v", name, ok, true)
+				continue
+			}
+			if info != want {
+				t.Errorf("%s: GOT: %#v; WANT: %#v", name, info, want)
+			}
+		}
+	})
+}
+
+func TestNameSeparator(t *testing.T) {
+	t.Run("hyphen", func(t *testing.T) {
+		directory := t.TempDir()
+
+		cfg := Config{
+			BaseNamePrefix:  "app",
+			BufferSizeMax:   -1,
+			Directory:       directory,
+			IncludeSequence: true,
+			NameSeparator:   "-",
+		}
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+		clock := newTestClock()
+		setClock(lw, clock)
+
+		for i := 0; i < 2; i++ {
+			_, err = fmt.Fprintf(lw, "line %d\n", i)
+			ensureError(t, err)
+			ensureError(t, lw.Rotate())
+			clock.Advance(time.Second)
+		}
+
+		// A log file of another LogWriter whose names use the default
+		// separator is not mistaken for one of this LogWriter.
+		ensureError(t, os.WriteFile(filepath.Join(directory, "app.1647950400000000000.000001.log"), nil, 0644))
+
+		stamp := nanoDateTimeFormatter(newTestClock().Now())
+		first := filepath.Join(directory, "app-"+stamp+".000001.log")
+		info, ok := ParseBackupName(&cfg, first)
+		if !ok {
+			t.Fatalf("GOT: %v; WANT: %v", ok, true)
+		}
+		if got, want := info.Stamp, stamp; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, first), []byte("line 0\n"))
+
+		if _, ok = ParseBackupName(&cfg, filepath.Join(directory, "app.1647950400000000000.000001.log")); ok {
+			t.Errorf("GOT: %v; WANT: %v", ok, false)
+		}
+
+		entries, err := fs.ReadDir(lw.FS(), ".")
+		ensureError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		if got, want := fmt.Sprint(names), fmt.Sprintf("[app-%s.000001.log app-%d.000002.log app.log]", stamp, newTestClock().Now().Add(time.Second).UnixNano()); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		var snapshot struct{ BackupCount int }
+		ensureError(t, json.Unmarshal(lw.MetricsSnapshot(), &snapshot))
+		if got, want := snapshot.BackupCount, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{Directory: t.TempDir(), NameSeparator: "/"})
+		ensureError(t, err, "name separator")
+	})
+}
+
+func TestWriteBackup(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "backfill",
+		ClobberPolicy:  ClobberError,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	older := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newer := older.Add(24 * time.Hour)
+
+	// Backfill the newer file first, to show names sort by time.
+	for _, tc := range []struct {
+		when    time.Time
+		content string
+	}{
+		{newer, "newer 1\nnewer 2\n"},
+		{older, "older 1\n"},
+	} {
+		path, err := lw.WriteBackup(tc.when, strings.NewReader(tc.content))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, path), []byte(tc.content))
+	}
+
+	_, err = lw.Write([]byte("current\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Flush())
+
+	entries, err := fs.ReadDir(lw.FS(), ".")
+	ensureError(t, err)
+	var times []time.Time
+	for _, entry := range entries {
+		if entry.Name() == "backfill.log" {
+			continue
+		}
+		info, ok := lw.ParseBackupName(entry.Name())
+		if !ok {
+			t.Fatalf("GOT: %q; WANT: backup name", entry.Name())
+		}
+		times = append(times, info.Time)
+	}
+	if got, want := fmt.Sprint(times), fmt.Sprint([]time.Time{older, newer}); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("current\n"))
+
+	// Backfilling a name already used follows ClobberPolicy, and leaves
+	// no temporary file behind.
+	_, err = lw.WriteBackup(older, strings.NewReader("duplicate\n"))
+	ensureError(t, err, "existing file")
+	if !errors.Is(err, fs.ErrExist) {
+		t.Errorf("GOT: %v; WANT: %v", err, fs.ErrExist)
+	}
+	matches, err := filepath.Glob(filepath.Join(directory, ".backfill.*"))
+	ensureError(t, err)
+	if len(matches) != 0 {
+		t.Errorf("GOT: %v; WANT: no temporary files", matches)
+	}
+
+	ensureError(t, lw.Close())
+}
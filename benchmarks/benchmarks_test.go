@@ -59,6 +59,7 @@ func TestMain(m *testing.M) {
 
 func benchmarkWriteCloser(b *testing.B, callback func() (io.WriteCloser, error)) {
 	b.Helper()
+	b.ReportAllocs()
 
 	const limit = 1024 * 1024 // 1 MiB
 	var r io.Reader
@@ -0,0 +1,55 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// formatByteRange returns the half open range of offsets in the stream
+// of all log files covered by a rotated log file, zero padded to a
+// fixed width, so that names of rotated log files sort in stream order.
+func formatByteRange(start, end int64) string {
+	return fmt.Sprintf("%012d-%012d", start, end)
+}
+
+// parseByteRange returns the offsets encoded in s by formatByteRange,
+// and true when s is a valid range.
+func parseByteRange(s string) (int64, int64, bool) {
+	i := strings.IndexByte(s, '-')
+	if i == -1 {
+		return 0, 0, false
+	}
+	start, ok := parseDigits(s[:i])
+	if !ok {
+		return 0, 0, false
+	}
+	end, ok := parseDigits(s[i+1:])
+	if !ok || end < start {
+		return 0, 0, false
+	}
+	return int64(start), int64(end), true
+}
+
+// resumeByteOffset continues the stream offset after the largest end
+// offset among the rotated log files in the log directory, so log files
+// rotated by this LogWriter do not cover ranges already used by those
+// rotated by an earlier one.
+func (lw *LogWriter) resumeByteOffset() error {
+	entries, err := os.ReadDir(lw.logDirectory())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // CreateDirectory creates it when opening the log file
+		}
+		return fmt.Errorf("cannot read log directory: %w", err)
+	}
+	for _, entry := range entries {
+		info, ok := ParseBackupName(&lw.cfg, entry.Name())
+		if ok && info.ByteEnd > lw.byteOffset {
+			lw.byteOffset = info.ByteEnd
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package golw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIncludeByteRange(t *testing.T) {
+	directory := t.TempDir()
+	cfg := Config{
+		BaseNamePrefix:   "range",
+		BufferSizeMax:    -1,
+		Directory:        directory,
+		IncludeByteRange: true,
+		MaxBytes:         20,
+	}
+
+	var total int64
+	write := func(t *testing.T, lw *LogWriter, lines int) {
+		t.Helper()
+		for i := 0; i < lines; i++ {
+			n, err := fmt.Fprintf(lw, "line %d\n", i)
+			ensureError(t, err)
+			total += int64(n)
+		}
+	}
+
+	// ensureContiguous ensures the rotated log files cover the stream
+	// from offset zero without gaps or overlaps, and returns the end
+	// offset of the last one.
+	ensureContiguous := func(t *testing.T, lw *LogWriter) int64 {
+		t.Helper()
+		var offset int64
+		for _, archive := range archivedLogs(t, directory, "range") {
+			info, ok := lw.ParseBackupName(archive)
+			if !ok {
+				t.Fatalf("%s: GOT: %v; WANT: %v", archive, ok, true)
+			}
+			if got, want := info.ByteStart, offset; got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", archive, got, want)
+			}
+			if got, want := info.ByteEnd-info.ByteStart, int64(len(readFile(t, archive))); got != want {
+				t.Errorf("%s: GOT: %v; WANT: %v", archive, got, want)
+			}
+			offset = info.ByteEnd
+		}
+		return offset
+	}
+
+	lw, err := NewLogWriter(&cfg)
+	ensureError(t, err)
+	write(t, lw, 10)
+	ensureError(t, lw.Close())
+
+	end := ensureContiguous(t, lw)
+	if got := len(archivedLogs(t, directory, "range")); got < 3 {
+		t.Errorf("GOT: %v; WANT: at least 3 rotated log files", got)
+	}
+	st, err := os.Stat(filepath.Join(directory, "range.log"))
+	ensureError(t, err)
+	if got, want := end+st.Size(), total; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	t.Run("continued by new LogWriter", func(t *testing.T) {
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+		write(t, lw, 3)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		if got, want := ensureContiguous(t, lw), total; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("CreateDirectory", func(t *testing.T) {
+		cfg := cfg
+		cfg.CreateDirectory = true
+		cfg.Directory = filepath.Join(t.TempDir(), "new")
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("WriteBackup", func(t *testing.T) {
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+		_, err = lw.WriteBackup(time.Now(), nil)
+		ensureError(t, err, "IncludeByteRange")
+		ensureError(t, lw.Close())
+	})
+}
@@ -0,0 +1,182 @@
+package golw
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// chainTag introduces the trailer TamperEvident appends to each line,
+// which holds the chain link of the previous line and that of the line
+// itself, formatted as hexadecimal and separated by a colon.
+const chainTag = " chain="
+
+// chainLinkSize is the number of bytes of each chain link, which is a
+// truncated SHA-256 hash.
+const chainLinkSize = 16
+
+// chainTrailerSize is the number of bytes TamperEvident adds to each
+// line, not counting its newline.
+const chainTrailerSize = len(chainTag) + 2*chainLinkSize + 1 + 2*chainLinkSize
+
+// chainLink returns the chain link of a line with content, which is
+// the truncated SHA-256 hash of the link of the previous line followed
+// by content.
+func chainLink(previous, content []byte) []byte {
+	h := sha256.New()
+	h.Write(previous)
+	h.Write(content)
+	return h.Sum(nil)[:chainLinkSize]
+}
+
+// appendChained appends the record p to buf, with a trailer before the
+// newline of each of its lines that chains the line to the one before
+// it, and returns the extended buffer. A final line that is not newline
+// terminated is chained once a later record completes it. The chain
+// only advances past p when commitChain is invoked after the extended
+// buffer is written, so a record that fails to be written is not
+// chained.
+func (lw *LogWriter) appendChained(buf, p []byte) []byte {
+	lw.chainNextPrev = lw.chainPrev
+	line := append(lw.chainNextLine[:0], lw.chainLine...)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i == -1 {
+			line = append(line, p...)
+			buf = append(buf, p...)
+			break
+		}
+		line = append(line, p[:i]...)
+		link := chainLink(lw.chainNextPrev[:], line)
+
+		buf = append(append(buf, p[:i]...), chainTag...)
+		buf = append(buf, hex.EncodeToString(lw.chainNextPrev[:])...)
+		buf = append(append(buf, ':'), hex.EncodeToString(link)...)
+		buf = append(buf, '\n')
+
+		copy(lw.chainNextPrev[:], link)
+		line = line[:0]
+		p = p[i+1:]
+	}
+	lw.chainNextLine = line
+	return buf
+}
+
+// commitChain advances the chain past the record most recently passed
+// to appendChained, once it has been written.
+func (lw *LogWriter) commitChain() {
+	if !lw.cfg.TamperEvident {
+		return
+	}
+	lw.chainPrev = lw.chainNextPrev
+	lw.chainLine, lw.chainNextLine = lw.chainNextLine, lw.chainLine
+}
+
+// lineEnding returns the bytes that complete a line the LogWriter
+// finishes on behalf of the caller, which are a newline, preceded by
+// the trailer of the line when TamperEvident is true, in which case
+// commitChain must be invoked once they are written.
+func (lw *LogWriter) lineEnding() []byte {
+	if !lw.cfg.TamperEvident {
+		return newline
+	}
+	return lw.appendChained(nil, newline)
+}
+
+// resumeChain continues the chain from the last line of the open log
+// file when it has contents, so lines appended to it remain chained to
+// those it already holds. When its last line is incomplete, or was not
+// chained, the chain starts over, and VerifyChain reports the log file
+// as broken at that point.
+func (lw *LogWriter) resumeChain() error {
+	if lw.fileSizeNow < int64(chainTrailerSize+1) {
+		return nil
+	}
+	fp, err := os.Open(lw.filePath)
+	if err != nil {
+		return fmt.Errorf("cannot read log file to resume its chain: %w", err)
+	}
+	defer fp.Close()
+
+	last := make([]byte, chainTrailerSize+1)
+	if _, err = fp.ReadAt(last, lw.fileSizeNow-int64(len(last))); err != nil {
+		return fmt.Errorf("cannot read log file to resume its chain: %w", err)
+	}
+	if _, _, link, ok := parseChained(last); ok {
+		copy(lw.chainPrev[:], link)
+	}
+	return nil
+}
+
+// parseChained returns the content of the chained line, the link of the
+// line before it, and its own link, and true when line, which includes
+// its newline, ends with a valid trailer.
+func parseChained(line []byte) ([]byte, []byte, []byte, bool) {
+	if len(line) < chainTrailerSize+1 || line[len(line)-1] != '\n' {
+		return nil, nil, nil, false
+	}
+	content := line[:len(line)-1-chainTrailerSize]
+	trailer := line[len(content) : len(line)-1]
+	if !bytes.HasPrefix(trailer, []byte(chainTag)) {
+		return nil, nil, nil, false
+	}
+	trailer = trailer[len(chainTag):]
+	if trailer[2*chainLinkSize] != ':' {
+		return nil, nil, nil, false
+	}
+	previous := make([]byte, chainLinkSize)
+	if _, err := hex.Decode(previous, trailer[:2*chainLinkSize]); err != nil {
+		return nil, nil, nil, false
+	}
+	link := make([]byte, chainLinkSize)
+	if _, err := hex.Decode(link, trailer[2*chainLinkSize+1:]); err != nil {
+		return nil, nil, nil, false
+	}
+	return content, previous, link, true
+}
+
+// VerifyChain reads the lines written to a log file by a LogWriter with
+// TamperEvident set to true, and returns true when each of them carries
+// a valid trailer, its content matches the link in its trailer, and it
+// is chained to the line before it, so that modifying, inserting,
+// removing, or reordering lines is detected. It returns false, and a
+// nil error, as soon as it finds a line that fails verification, and an
+// error only when reading r fails. The first line of r is chained to a
+// line that r does not include, unless it is the first line the
+// LogWriter ever wrote, so removing lines from the start of r is only
+// detected by verifying it along with the log file rotated before it,
+// such as by concatenating them with io.MultiReader. Likewise, removing
+// lines from the end of the final log file cannot be detected. The
+// chain is not keyed, so it does not detect an attacker who rewrites
+// every line after the one modified, unless the final link is recorded
+// elsewhere.
+func VerifyChain(r io.Reader) (bool, error) {
+	br := bufio.NewReader(r)
+	var previous []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			content, linkPrevious, link, ok := parseChained(line)
+			if !ok {
+				return false, nil
+			}
+			if previous != nil && !bytes.Equal(linkPrevious, previous) {
+				return false, nil
+			}
+			if !bytes.Equal(chainLink(linkPrevious, content), link) {
+				return false, nil
+			}
+			previous = link
+		}
+		if err == io.EOF {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("cannot read chained log: %w", err)
+		}
+	}
+}
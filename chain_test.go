@@ -0,0 +1,182 @@
+package golw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestTamperEvident(t *testing.T) {
+	directory := t.TempDir()
+	cfg := Config{
+		BaseNamePrefix:  "chain",
+		BufferSizeMax:   64,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        256,
+		TamperEvident:   true,
+	}
+
+	lw, err := NewLogWriter(&cfg)
+	ensureError(t, err)
+	for _, s := range []string{"line 1\n", "line 2\nline 3\n", "line ", "4\n", "line 5\n"} {
+		_, err = lw.Write([]byte(s))
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Close())
+
+	// A new LogWriter continues the chain in the existing log file.
+	lw, err = NewLogWriter(&cfg)
+	ensureError(t, err)
+	_, err = lw.Write([]byte("line 6\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	files := append(archivedLogs(t, directory, "chain"), filepath.Join(directory, "chain.log"))
+	if got := len(files); got < 2 {
+		t.Fatalf("GOT: %v; WANT: at least one rotated log file", got)
+	}
+
+	verify := func(t *testing.T, contents ...[]byte) bool {
+		t.Helper()
+		var readers []io.Reader
+		for _, content := range contents {
+			readers = append(readers, bytes.NewReader(content))
+		}
+		ok, err := VerifyChain(io.MultiReader(readers...))
+		ensureError(t, err)
+		return ok
+	}
+
+	var contents [][]byte
+	for _, file := range files {
+		content := readFile(t, file)
+		contents = append(contents, content)
+		if !verify(t, content) {
+			t.Errorf("%s: GOT: %v; WANT: %v", file, false, true)
+		}
+	}
+	lines := bytes.SplitAfter(bytes.Join(contents, nil), newline)
+	lines = lines[:len(lines)-1] // drop the empty remainder
+	if got, want := len(lines), 6; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if !verify(t, contents...) {
+		t.Errorf("GOT: %v; WANT: %v", false, true)
+	}
+	if !bytes.HasPrefix(lines[3], []byte("line 4"+chainTag)) {
+		t.Errorf("GOT: %q; WANT: line completed by a later write chained once", lines[3])
+	}
+
+	t.Run("modified", func(t *testing.T) {
+		tampered := bytes.Join(lines, nil)
+		tampered[bytes.Index(tampered, []byte("line 3"))+5] = '9'
+		if verify(t, tampered) {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		tampered := bytes.Join(append(append([][]byte(nil), lines[:2]...), lines[3:]...), nil)
+		if verify(t, tampered) {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+	})
+
+	t.Run("reordered", func(t *testing.T) {
+		tampered := bytes.Join([][]byte{lines[0], lines[2], lines[1], lines[3]}, nil)
+		if verify(t, tampered) {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+	})
+
+	t.Run("unchained", func(t *testing.T) {
+		if verify(t, []byte("line 1\n")) {
+			t.Errorf("GOT: %v; WANT: %v", true, false)
+		}
+	})
+
+	t.Run("unterminated final line", func(t *testing.T) {
+		for _, bufferSizeMax := range []int{-1, 0, 16, 1024} {
+			t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+				directory := t.TempDir()
+				lw, err := NewLogWriter(&Config{
+					AllowTinyBuffer: true,
+					BaseNamePrefix:  "chain",
+					BufferSizeMax:   bufferSizeMax,
+					Directory:       directory,
+					TamperEvident:   true,
+				})
+				ensureError(t, err)
+				for _, s := range []string{"one\n", "two-unterminated-and-longer-than-the-buffer"} {
+					_, err = lw.Write([]byte(s))
+					ensureError(t, err)
+				}
+				ensureError(t, lw.Close())
+
+				content := readFile(t, filepath.Join(directory, "chain.log"))
+				if !verify(t, content) {
+					t.Errorf("GOT: %q; WANT: verified chain", content)
+				}
+			})
+		}
+	})
+
+	t.Run("completed on unbuffer", func(t *testing.T) {
+		directory := t.TempDir()
+		cfg := Config{
+			BaseNamePrefix:         "chain",
+			BufferSizeMax:          1024,
+			CompleteLineOnUnbuffer: true,
+			Directory:              directory,
+			TamperEvident:          true,
+		}
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+		_, err = lw.Write([]byte("one\ntwo-unterminated"))
+		ensureError(t, err)
+		cfg.BufferSizeMax = -1
+		ensureError(t, lw.Reconfigure(&cfg))
+		ensureError(t, lw.Close())
+
+		content := readFile(t, filepath.Join(directory, "chain.log"))
+		if !verify(t, content) {
+			t.Errorf("GOT: %q; WANT: verified chain", content)
+		}
+	})
+
+	t.Run("failed write not chained", func(t *testing.T) {
+		directory := t.TempDir()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "chain",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			TamperEvident:  true,
+		})
+		ensureError(t, err)
+		_, err = lw.Write([]byte("one\n"))
+		ensureError(t, err)
+
+		healthy := lw.filePointer
+		lw.filePointer = writeFailingFile{healthy}
+		_, err = lw.Write([]byte("lost\n"))
+		ensureError(t, err, "injected write failure")
+		lw.filePointer = healthy
+
+		_, err = lw.Write([]byte("two\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		content := readFile(t, filepath.Join(directory, "chain.log"))
+		if !verify(t, content) {
+			t.Errorf("GOT: %q; WANT: verified chain", content)
+		}
+	})
+
+	t.Run("SharedAppend", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{BufferSizeMax: -1, Directory: t.TempDir(), SharedAppend: true, TamperEvident: true})
+		ensureError(t, err, "TamperEvident", "SharedAppend")
+	})
+}
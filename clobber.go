@@ -0,0 +1,81 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// ClobberPolicy determines what the LogWriter does when rotating the
+// log file to a name that is already used by an existing file, which
+// may happen when the system clock is set backwards.
+type ClobberPolicy int
+
+const (
+	// ClobberOverwrite replaces the existing file with the rotated log
+	// file, losing the contents of the existing file. This is the
+	// default.
+	ClobberOverwrite ClobberPolicy = iota
+
+	// ClobberError causes the rotation to fail with an error that
+	// wraps fs.ErrExist, and leaves the log file open so that writes
+	// continue to be appended to it.
+	ClobberError
+
+	// ClobberSuffix rotates the log file to an unused name, by
+	// appending the smallest number that makes the name unique, as in
+	// "server.20220322T120000-1.log".
+	ClobberSuffix
+)
+
+// clobberPolicyNames are the names of each ClobberPolicy, in order.
+var clobberPolicyNames = []string{"Overwrite", "Error", "Suffix"}
+
+// String returns the name of the policy, without its Clobber prefix.
+func (p ClobberPolicy) String() string {
+	if p < 0 || int(p) >= len(clobberPolicyNames) {
+		return fmt.Sprintf("ClobberPolicy(%d)", int(p))
+	}
+	return clobberPolicyNames[p]
+}
+
+// parseClobberPolicy returns the ClobberPolicy whose name, with or
+// without its Clobber prefix, case-insensitively matches s.
+func parseClobberPolicy(s string) (ClobberPolicy, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "clobber")
+	for i, policyName := range clobberPolicyNames {
+		if name == strings.ToLower(policyName) {
+			return ClobberPolicy(i), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot parse clobber policy: %q", s)
+}
+
+// archivePath returns the path to which the log file is to be rotated,
+// given the path formed from its time stamp and the file extension,
+// according to the configured ClobberPolicy.
+func (lw *LogWriter) archivePath(stem, extension string) (string, error) {
+	path := stem + extension
+	if lw.cfg.ClobberPolicy == ClobberOverwrite {
+		return path, nil
+	}
+
+	for suffix := 1; ; suffix++ {
+		_, err := os.Lstat(path)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return path, nil
+			}
+			return "", fmt.Errorf("cannot stat archive path: %w", err)
+		}
+		if lw.cfg.ClobberPolicy == ClobberError {
+			return "", fmt.Errorf("cannot rotate log file to existing file: %w", &fs.PathError{Op: "rename", Path: path, Err: fs.ErrExist})
+		}
+		if lw.tracing() {
+			lw.trace("archivePath", "exists", path)
+		}
+		path = fmt.Sprintf("%s-%d%s", stem, suffix, extension)
+	}
+}
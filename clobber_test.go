@@ -0,0 +1,96 @@
+package golw
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClobberPolicy(t *testing.T) {
+	// newWriter returns a LogWriter whose rotated log file is always
+	// named the same, and a pre-existing file with that name.
+	newWriter := func(t *testing.T, policy ClobberPolicy) (*LogWriter, string) {
+		t.Helper()
+		directory := t.TempDir()
+
+		existing := filepath.Join(directory, "clobber.20220322T120000.log")
+		ensureError(t, os.WriteFile(existing, []byte("existing\n"), 0644))
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "clobber",
+			BufferSizeMax:  -1,
+			ClobberPolicy:  policy,
+			Directory:      directory,
+			TimeFormatter:  func(time.Time) string { return "20220322T120000" },
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+
+		return lw, existing
+	}
+
+	t.Run("Overwrite", func(t *testing.T) {
+		lw, existing := newWriter(t, ClobberOverwrite)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, existing), []byte("line 1\n"))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		lw, existing := newWriter(t, ClobberError)
+
+		err := lw.Rotate()
+		ensureError(t, err, "existing file")
+		if !errors.Is(err, fs.ErrExist) {
+			t.Errorf("GOT: %v; WANT: %v", err, fs.ErrExist)
+		}
+
+		// The log file remains open, and subsequent writes are
+		// appended to it.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, existing), []byte("existing\n"))
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nline 2\n"))
+	})
+
+	t.Run("Suffix", func(t *testing.T) {
+		lw, existing := newWriter(t, ClobberSuffix)
+		ensureError(t, lw.Rotate())
+		_, err := lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		directory := filepath.Dir(existing)
+		ensureBuffer(t, readFile(t, existing), []byte("existing\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "clobber.20220322T120000-1.log")), []byte("line 1\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "clobber.20220322T120000-2.log")), []byte("line 2\n"))
+	})
+
+	t.Run("String", func(t *testing.T) {
+		for policy, want := range map[ClobberPolicy]string{ClobberOverwrite: "Overwrite", ClobberError: "Error", ClobberSuffix: "Suffix", 13: "ClobberPolicy(13)"} {
+			if got := policy.String(); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+	})
+
+	t.Run("ConfigFromMap", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"ClobberPolicy": "suffix"})
+		ensureError(t, err)
+		if got, want := cfg.ClobberPolicy, ClobberSuffix; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"ClobberPolicy": "ignore"})
+		ensureError(t, err, "ClobberPolicy", "ignore")
+	})
+}
@@ -0,0 +1,57 @@
+package golw
+
+import "time"
+
+// compactFactor is how many times its nominal capacity the buffer, or
+// its list of extents, may retain once empty before it is reallocated.
+const compactFactor = 4
+
+// nominalExtents is the nominal capacity of the list of extents when
+// MaxPendingExtents is zero.
+const nominalExtents = 16
+
+// CompactBuffers writes all completed writes in the buffer to the log
+// file, just like Flush, then, when nothing remains buffered, releases
+// the memory the buffer and its list of extents retain from an earlier
+// burst of writes, reallocating each at its nominal size when it has
+// grown to more than four times that size. The nominal size of the
+// buffer is BufferSizeMax, and that of the list of extents is
+// MaxPendingExtents, or 16 when it is zero. The LogWriter also does this
+// itself each time it writes the entire buffer to the log file, so it
+// is only needed when a program wants to ensure the memory is released.
+func (lw *LogWriter) CompactBuffers() error {
+	lw.lock()
+	defer lw.unlock()
+
+	if err := lw.flush(); err != nil {
+		return err
+	}
+	lw.compactBuffers()
+	return nil
+}
+
+// compactBuffers reallocates the buffer and its list of extents, as
+// described by CompactBuffers, when both are empty, while the lock is
+// held.
+func (lw *LogWriter) compactBuffers() {
+	if lw.cfg.BufferSizeMax <= 0 || len(lw.buf) > 0 || len(lw.extents) > 0 {
+		return
+	}
+	extents := lw.cfg.MaxPendingExtents
+	if extents == 0 {
+		extents = nominalExtents
+	}
+	if cap(lw.buf) <= compactFactor*lw.cfg.BufferSizeMax && cap(lw.extents) <= compactFactor*extents {
+		return
+	}
+	if lw.tracing() {
+		lw.trace("compactBuffers", "buffer", cap(lw.buf), "extents", cap(lw.extents))
+	}
+	if cap(lw.buf) > compactFactor*lw.cfg.BufferSizeMax {
+		lw.buf = make([]byte, 0, lw.cfg.BufferSizeMax)
+	}
+	if cap(lw.extents) > compactFactor*extents {
+		lw.extents = make([]int, 0, extents)
+		lw.writeTimes = make([]time.Time, 0, extents)
+	}
+}
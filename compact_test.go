@@ -0,0 +1,57 @@
+package golw
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompactBuffers(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "compact",
+		BufferSizeMax:  1024,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	// A burst of tiny writes grows the list of extents, and a long
+	// line grows the buffer beyond BufferSizeMax.
+	var want []byte
+	for i := 0; i < 1000; i++ {
+		_, err = lw.Write(newline)
+		ensureError(t, err)
+		want = append(want, '\n')
+	}
+	if got := cap(lw.extents); got <= compactFactor*nominalExtents {
+		t.Fatalf("GOT: %v; WANT: extents grown beyond %v", got, compactFactor*nominalExtents)
+	}
+
+	long := append(bytes.Repeat([]byte("x"), 10000), '\n')
+	_, err = lw.Write(long[:len(long)-1])
+	ensureError(t, err)
+	_, err = lw.Write(newline)
+	ensureError(t, err)
+	want = append(want, long...)
+
+	if got := cap(lw.buf); got <= compactFactor*1024 {
+		t.Fatalf("GOT: %v; WANT: buffer grown beyond %v", got, compactFactor*1024)
+	}
+
+	ensureError(t, lw.CompactBuffers())
+	if got, want := cap(lw.buf), 1024; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := cap(lw.extents), nominalExtents; got > compactFactor*want {
+		t.Errorf("GOT: %v; WANT: at most %v", got, compactFactor*want)
+	}
+
+	// Nothing was lost, and the compacted buffer is still used.
+	_, err = lw.Write([]byte("line\n"))
+	ensureError(t, err)
+	if got, want := lw.Stats().BufferedBytes, len("line\n"); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, lw.Close())
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), append(want, "line\n"...))
+}
@@ -0,0 +1,563 @@
+package golw
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// sizeSuffixes maps the case-insensitive unit suffixes ParseSize
+// recognizes to the number of bytes in one of that unit.
+var sizeSuffixes = map[string]int64{
+	"":    1,
+	"b":   1,
+	"kb":  1000,
+	"mb":  1000 * 1000,
+	"gb":  1000 * 1000 * 1000,
+	"tb":  1000 * 1000 * 1000 * 1000,
+	"kib": 1 << 10,
+	"mib": 1 << 20,
+	"gib": 1 << 30,
+	"tib": 1 << 40,
+}
+
+// ParseSize parses a string representing a non-negative number of
+// bytes, optionally followed by a unit suffix, and returns the number
+// of bytes it represents. Decimal suffixes KB, MB, GB, and TB are
+// powers of 1000, while binary suffixes KiB, MiB, GiB, and TiB are
+// powers of 1024. Suffixes are case-insensitive and may be separated
+// from the number by white space. A string without a suffix, or with
+// the B suffix, is a number of bytes.
+//
+//	size, err := golw.ParseSize("10MiB") // 10485760
+func ParseSize(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+
+	i := strings.IndexFunc(trimmed, func(r rune) bool { return !unicode.IsDigit(r) })
+	if i == -1 {
+		i = len(trimmed)
+	}
+	if i == 0 {
+		return 0, fmt.Errorf("cannot parse size without leading digits: %q", s)
+	}
+
+	multiplier, ok := sizeSuffixes[strings.ToLower(strings.TrimSpace(trimmed[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("cannot parse size with unknown suffix: %q", s)
+	}
+
+	value, err := strconv.ParseInt(trimmed[:i], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse size: %w", err)
+	}
+
+	if value > (1<<63-1)/multiplier {
+		return 0, fmt.Errorf("cannot parse size that overflows int64: %q", s)
+	}
+
+	return value * multiplier, nil
+}
+
+// configParsers maps each Config field that can be represented as a
+// string to a function that parses the string and stores the result
+// in the field.
+var configParsers = map[string]func(*Config, string) error{
+	"AllowTinyBuffer": func(cfg *Config, value string) (err error) {
+		cfg.AllowTinyBuffer, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"BaseNamePrefix": func(cfg *Config, value string) error {
+		cfg.BaseNamePrefix = value
+		return nil
+	},
+	"BufferSizeMax": func(cfg *Config, value string) error {
+		if strings.TrimSpace(value) == "-1" {
+			cfg.BufferSizeMax = -1
+			return nil
+		}
+		size, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		if size > int64(^uint(0)>>1) {
+			return fmt.Errorf("cannot use buffer size larger than maximum int: %d", size)
+		}
+		cfg.BufferSizeMax = int(size)
+		return nil
+	},
+	"ClobberPolicy": func(cfg *Config, value string) (err error) {
+		cfg.ClobberPolicy, err = parseClobberPolicy(value)
+		return err
+	},
+	"CoalesceRotations": func(cfg *Config, value string) (err error) {
+		cfg.CoalesceRotations, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"CompleteLineOnUnbuffer": func(cfg *Config, value string) (err error) {
+		cfg.CompleteLineOnUnbuffer, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"ContentDefinedMaxBytes": func(cfg *Config, value string) (err error) {
+		cfg.ContentDefinedMaxBytes, err = ParseSize(value)
+		return err
+	},
+	"ContentDefinedMinBytes": func(cfg *Config, value string) (err error) {
+		cfg.ContentDefinedMinBytes, err = ParseSize(value)
+		return err
+	},
+	"ContentDefinedRotation": func(cfg *Config, value string) (err error) {
+		cfg.ContentDefinedRotation, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"CoordinationLock": func(cfg *Config, value string) error {
+		cfg.CoordinationLock = value
+		return nil
+	},
+	"CountLines": func(cfg *Config, value string) (err error) {
+		cfg.CountLines, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"CreateDirectory": func(cfg *Config, value string) (err error) {
+		cfg.CreateDirectory, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"DirFileMode": func(cfg *Config, value string) error {
+		modes := make(map[string]fs.FileMode)
+		for _, entry := range filepath.SplitList(value) {
+			i := strings.LastIndexByte(entry, '=')
+			if i < 0 {
+				return fmt.Errorf("cannot parse directory file mode without '=': %q", entry)
+			}
+			mode, err := parseFileMode(entry[i+1:])
+			if err != nil {
+				return err
+			}
+			modes[entry[:i]] = mode
+		}
+		cfg.DirFileMode = modes
+		return nil
+	},
+	"Directory": func(cfg *Config, value string) error {
+		cfg.Directory = value
+		return nil
+	},
+	"FileMode": func(cfg *Config, value string) (err error) {
+		cfg.FileMode, err = parseFileMode(value)
+		return err
+	},
+	"FlushOnBufferFull": func(cfg *Config, value string) (err error) {
+		cfg.FlushOnBufferFull, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"FlushOversizeImmediately": func(cfg *Config, value string) (err error) {
+		cfg.FlushOversizeImmediately, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"FlushTerminatedWrites": func(cfg *Config, value string) (err error) {
+		cfg.FlushTerminatedWrites, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"FlushThreshold": func(cfg *Config, value string) error {
+		size, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		if size > int64(^uint(0)>>1) {
+			return fmt.Errorf("cannot use flush threshold larger than maximum int: %d", size)
+		}
+		cfg.FlushThreshold = int(size)
+		return nil
+	},
+	"FrameMode": func(cfg *Config, value string) (err error) {
+		cfg.FrameMode, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"IdleCloseAfter": func(cfg *Config, value string) (err error) {
+		cfg.IdleCloseAfter, err = time.ParseDuration(strings.TrimSpace(value))
+		return err
+	},
+	"IncludeByteRange": func(cfg *Config, value string) (err error) {
+		cfg.IncludeByteRange, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"IncludeSequence": func(cfg *Config, value string) (err error) {
+		cfg.IncludeSequence, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"LingerDuration": func(cfg *Config, value string) (err error) {
+		cfg.LingerDuration, err = time.ParseDuration(strings.TrimSpace(value))
+		return err
+	},
+	"MaxBytes": func(cfg *Config, value string) (err error) {
+		cfg.MaxBytes, err = ParseSize(value)
+		return err
+	},
+	"MaxBytesBurst": func(cfg *Config, value string) (err error) {
+		cfg.MaxBytesBurst, err = ParseSize(value)
+		return err
+	},
+	"MaxBytesPerSecond": func(cfg *Config, value string) (err error) {
+		cfg.MaxBytesPerSecond, err = ParseSize(value)
+		return err
+	},
+	"MaxMemoryBytes": func(cfg *Config, value string) (err error) {
+		cfg.MaxMemoryBytes, err = ParseSize(value)
+		return err
+	},
+	"MaxNameBytes": func(cfg *Config, value string) (err error) {
+		cfg.MaxNameBytes, err = strconv.Atoi(strings.TrimSpace(value))
+		return err
+	},
+	"MaxPendingExtents": func(cfg *Config, value string) (err error) {
+		cfg.MaxPendingExtents, err = strconv.Atoi(strings.TrimSpace(value))
+		return err
+	},
+	"MeasureLockContention": func(cfg *Config, value string) (err error) {
+		cfg.MeasureLockContention, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"Mmap": func(cfg *Config, value string) (err error) {
+		cfg.Mmap, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"MultiDestination": func(cfg *Config, value string) error {
+		directories := filepath.SplitList(value)
+		for _, directory := range directories {
+			if directory == "" {
+				return fmt.Errorf("cannot parse empty directory in list: %q", value)
+			}
+		}
+		cfg.MultiDestination = directories
+		return nil
+	},
+	"NameSeparator": func(cfg *Config, value string) error {
+		cfg.NameSeparator = value
+		return nil
+	},
+	"NameByWriteTime": func(cfg *Config, value string) (err error) {
+		cfg.NameByWriteTime, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"NoFollowSymlinks": func(cfg *Config, value string) (err error) {
+		cfg.NoFollowSymlinks, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"NoRotate": func(cfg *Config, value string) (err error) {
+		cfg.NoRotate, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"OSBufferSize": func(cfg *Config, value string) error {
+		size, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		if size > int64(^uint(0)>>1) {
+			return fmt.Errorf("cannot use OS buffer size larger than maximum int: %d", size)
+		}
+		cfg.OSBufferSize = int(size)
+		return nil
+	},
+	"OSBuffered": func(cfg *Config, value string) (err error) {
+		cfg.OSBuffered, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"PreallocateNext": func(cfg *Config, value string) (err error) {
+		cfg.PreallocateNext, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RecordSuffix": func(cfg *Config, value string) error {
+		cfg.RecordSuffix = []byte(value)
+		return nil
+	},
+	"RecoverOnFlushError": func(cfg *Config, value string) (err error) {
+		cfg.RecoverOnFlushError, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RemoveEmptyOnClose": func(cfg *Config, value string) (err error) {
+		cfg.RemoveEmptyOnClose, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RemoveOnClose": func(cfg *Config, value string) (err error) {
+		cfg.RemoveOnClose, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RepairOnOpen": func(cfg *Config, value string) (err error) {
+		cfg.RepairOnOpen, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RepairTruncate": func(cfg *Config, value string) (err error) {
+		cfg.RepairTruncate, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"ReplaceInvalidUTF8": func(cfg *Config, value string) (err error) {
+		cfg.ReplaceInvalidUTF8, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RequireExisting": func(cfg *Config, value string) (err error) {
+		cfg.RequireExisting, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"RotateOnMarker": func(cfg *Config, value string) error {
+		cfg.RotateOnMarker = []byte(value)
+		return nil
+	},
+	"RotateOnSessionChange": func(cfg *Config, value string) (err error) {
+		cfg.RotateOnSessionChange, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"SanitizeTimestamp": func(cfg *Config, value string) (err error) {
+		cfg.SanitizeTimestamp, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"SequenceStateFile": func(cfg *Config, value string) error {
+		cfg.SequenceStateFile = value
+		return nil
+	},
+	"SharedAppend": func(cfg *Config, value string) (err error) {
+		cfg.SharedAppend, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"SplitOversizeBackups": func(cfg *Config, value string) (err error) {
+		cfg.SplitOversizeBackups, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"StageActive": func(cfg *Config, value string) (err error) {
+		cfg.StageActive, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"TailBufferSize": func(cfg *Config, value string) error {
+		size, err := ParseSize(value)
+		if err != nil {
+			return err
+		}
+		if size > int64(^uint(0)>>1) {
+			return fmt.Errorf("cannot use tail buffer size larger than maximum int: %d", size)
+		}
+		cfg.TailBufferSize = int(size)
+		return nil
+	},
+	"TamperEvident": func(cfg *Config, value string) (err error) {
+		cfg.TamperEvident, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"TimeFormat": func(cfg *Config, value string) error {
+		cfg.TimeFormat = value
+		return nil
+	},
+	"ValidateUTF8": func(cfg *Config, value string) (err error) {
+		cfg.ValidateUTF8, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"VerifyOnStart": func(cfg *Config, value string) (err error) {
+		cfg.VerifyOnStart, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"WarnOnLeak": func(cfg *Config, value string) (err error) {
+		cfg.WarnOnLeak, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"WriteSidecarMeta": func(cfg *Config, value string) (err error) {
+		cfg.WriteSidecarMeta, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"WriteSyncFsync": func(cfg *Config, value string) (err error) {
+		cfg.WriteSyncFsync, err = strconv.ParseBool(strings.TrimSpace(value))
+		return err
+	},
+	"WriteTimeout": func(cfg *Config, value string) (err error) {
+		cfg.WriteTimeout, err = time.ParseDuration(strings.TrimSpace(value))
+		return err
+	},
+}
+
+// ConfigFromMap returns a new Config with its fields populated from
+// the string values in m, which are keyed by Config field name, such
+// as those loaded from environment variables, command line flags, or
+// configuration files. Fields that hold a number of bytes are parsed
+// with ParseSize, fields that hold a duration with time.ParseDuration,
+// and other numeric and boolean fields with strconv, while FileMode is
+// parsed as an octal number, and MultiDestination as a list of
+// directories separated by the operating system's path list separator,
+// like the PATH environment variable. DirFileMode is parsed as such a
+// list of entries, each a directory followed by an equals sign and its
+// octal file mode, such as "/var/log/shared=0600". Fields that cannot
+// be represented as a string, such as TimeFormatter, are not supported.
+// Fields missing from m are left at their zero value, so NewLogWriter
+// will use their defaults.
+//
+//	cfg, err := golw.ConfigFromMap(map[string]string{
+//	    "Directory": "/var/log/myapp",
+//	    "MaxBytes":  "10MiB",
+//	})
+func ConfigFromMap(m map[string]string) (*Config, error) {
+	// Process keys in sorted order so that when more than one value
+	// is invalid, the same error is returned each time.
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	cfg := new(Config)
+
+	for _, key := range keys {
+		parser, ok := configParsers[key]
+		if !ok {
+			return nil, fmt.Errorf("cannot parse config with unknown key: %q", key)
+		}
+		if err := parser(cfg, m[key]); err != nil {
+			return nil, fmt.Errorf("cannot parse config %s value %q: %w", key, m[key], err)
+		}
+	}
+
+	return cfg, nil
+}
+
+// parseFileMode returns the file mode represented by the octal number in
+// value, which may only have permission bits.
+func parseFileMode(value string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(strings.TrimSpace(value), 8, 32)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse octal file mode: %w", err)
+	}
+	if mode > uint64(fs.ModePerm) {
+		return 0, fmt.Errorf("cannot use file mode with bits other than permission bits: %#o", mode)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// DefaultConfig returns a Config with each field for which NewLogWriter
+// would choose a default value set to that value, so programs may show
+// the defaults, or modify some of them before creating a LogWriter. The
+// returned Config behaves identically to a nil Config. Its Directory is
+// the working directory, or empty when that cannot be determined.
+func DefaultConfig() Config {
+	return withDefaults(Config{})
+}
+
+// String returns a summary of the effective configuration, suitable
+// for logging or debugging. Fields for which NewLogWriter would choose
+// a default value are shown with that default value, and optional
+// fields are only shown when they are set, with functions shown as
+// "func".
+func (c Config) String() string {
+	return formatConfig(withDefaults(c), "BaseNamePrefix", "BufferSizeMax", "Directory", "FileMode", "MaxBytes", "MaxNameBytes", "NameSeparator")
+}
+
+// withDefaults returns c with each of BaseNamePrefix, BufferSizeMax,
+// Directory, FileMode, MaxBytes, MaxNameBytes, and NameSeparator set to
+// its default value when it is zero.
+func withDefaults(c Config) Config {
+	if c.BaseNamePrefix == "" {
+		c.BaseNamePrefix = programBaseNamePrefix(os.Args[0], runtime.GOOS)
+	}
+	if c.BufferSizeMax == 0 {
+		c.BufferSizeMax = DefaultBufferSize
+	}
+	if c.Directory == "" {
+		// When the working directory cannot be determined, leave the
+		// field empty, just as NewLogWriter would return an error.
+		c.Directory, _ = os.Getwd()
+	}
+	if c.FileMode == 0 {
+		c.FileMode = DefaultFileMode
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = DefaultMaxBytes
+	}
+	if c.MaxNameBytes == 0 {
+		c.MaxNameBytes = DefaultMaxNameBytes
+	}
+	if c.NameSeparator == "" {
+		c.NameSeparator = defaultNameSeparator
+	}
+	return c
+}
+
+// formatConfig returns a string showing the fields of c which are
+// either not their zero value, or are included in always.
+func formatConfig(c Config, always ...string) string {
+	v := reflect.ValueOf(c)
+	ty := v.Type()
+
+	var sb strings.Builder
+	sb.WriteString("Config{")
+
+	for i := 0; i < ty.NumField(); i++ {
+		field := ty.Field(i)
+		value := v.Field(i)
+
+		if value.IsZero() && !containsString(always, field.Name) {
+			continue
+		}
+
+		if sb.Len() > len("Config{") {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(field.Name)
+		sb.WriteString(": ")
+
+		switch tv := value.Interface().(type) {
+		case fs.FileMode:
+			fmt.Fprintf(&sb, "%#o", uint32(tv))
+		case []byte:
+			fmt.Fprintf(&sb, "%q", tv)
+		case string:
+			fmt.Fprintf(&sb, "%q", tv)
+		case fmt.Stringer:
+			sb.WriteString(tv.String())
+		default:
+			if value.Kind() == reflect.Func {
+				sb.WriteString("func")
+			} else {
+				fmt.Fprintf(&sb, "%v", tv)
+			}
+		}
+	}
+
+	sb.WriteString("}")
+	return sb.String()
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// equalFileModes returns true when a and b hold the same file mode for
+// each of the same directories.
+func equalFileModes(a, b map[string]fs.FileMode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for directory, mode := range a {
+		if other, ok := b[directory]; !ok || other != mode {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,307 @@
+package golw
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		cases := map[string]int64{
+			"0":       0,
+			"512":     512,
+			"512B":    512,
+			" 42 ":    42,
+			"1KB":     1000,
+			"1kb":     1000,
+			"10MB":    10 * 1000 * 1000,
+			"2GB":     2 * 1000 * 1000 * 1000,
+			"1TB":     1000 * 1000 * 1000 * 1000,
+			"1KiB":    1 << 10,
+			"10 MiB":  10 << 20,
+			"10mib":   10 << 20,
+			"3GiB":    3 << 30,
+			"1TiB":    1 << 40,
+			"8388607": 8388607,
+		}
+		for input, want := range cases {
+			got, err := ParseSize(input)
+			ensureError(t, err)
+			if got != want {
+				t.Errorf("%q: GOT: %v; WANT: %v", input, got, want)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]string{
+			"":                     "without leading digits",
+			"MiB":                  "without leading digits",
+			"-1":                   "without leading digits",
+			"1.5MiB":               "unknown suffix",
+			"10 furlongs":          "unknown suffix",
+			"99999999999999999999": "cannot parse size",
+			"9999999999TiB":        "overflows",
+		}
+		for input, want := range cases {
+			_, err := ParseSize(input)
+			ensureError(t, err, want)
+		}
+	})
+}
+
+func TestSizeHelpers(t *testing.T) {
+	cases := map[string]struct {
+		got, want int64
+	}{
+		"Megabytes":   {Megabytes(10), 10485760},
+		"Mebibytes":   {Mebibytes(10), 10485760},
+		"Gibibytes":   {Gibibytes(3), 3221225472},
+		"MegabytesSI": {MegabytesSI(10), 10000000},
+		"GigabytesSI": {GigabytesSI(3), 3000000000},
+	}
+	for name, tc := range cases {
+		if tc.got != tc.want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestConfigFromMap(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		cfg, err := ConfigFromMap(nil)
+		ensureError(t, err)
+		if cfg.BaseNamePrefix != "" || cfg.BufferSizeMax != 0 || cfg.MaxBytes != 0 {
+			t.Errorf("GOT: %#v; WANT: zero value Config", cfg)
+		}
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := ConfigFromMap(map[string]string{"MaxFurlongs": "13"})
+		ensureError(t, err, "unknown key", "MaxFurlongs")
+	})
+
+	t.Run("BaseNamePrefix", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"BaseNamePrefix": "server"})
+		ensureError(t, err)
+		if got, want := cfg.BaseNamePrefix, "server"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("BufferSizeMax", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"BufferSizeMax": "32KiB"})
+		ensureError(t, err)
+		if got, want := cfg.BufferSizeMax, 32768; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		cfg, err = ConfigFromMap(map[string]string{"BufferSizeMax": "-1"})
+		ensureError(t, err)
+		if got, want := cfg.BufferSizeMax, -1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"BufferSizeMax": "-2"})
+		ensureError(t, err, "BufferSizeMax", "-2")
+	})
+
+	t.Run("ClobberPolicy", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"ClobberPolicy": "ClobberSuffix"})
+		ensureError(t, err)
+		if got, want := cfg.ClobberPolicy, ClobberSuffix; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		cfg, err = ConfigFromMap(map[string]string{"ClobberPolicy": " error "})
+		ensureError(t, err)
+		if got, want := cfg.ClobberPolicy, ClobberError; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"ClobberPolicy": "ClobberSometimes"})
+		ensureError(t, err, "ClobberPolicy", "clobber policy")
+	})
+
+	t.Run("DirFileMode", func(t *testing.T) {
+		value := strings.Join([]string{"/var/log/app=0640", "/var/log/audit=0600"}, string(filepath.ListSeparator))
+		cfg, err := ConfigFromMap(map[string]string{"DirFileMode": value})
+		ensureError(t, err)
+		if got, want := fmt.Sprint(cfg.DirFileMode), "map[/var/log/app:-rw-r----- /var/log/audit:-rw-------]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"DirFileMode": "/var/log/app"})
+		ensureError(t, err, "DirFileMode", "without '='")
+
+		_, err = ConfigFromMap(map[string]string{"DirFileMode": "/var/log/app=0689"})
+		ensureError(t, err, "DirFileMode", "octal")
+	})
+
+	t.Run("Directory", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"Directory": "/var/log"})
+		ensureError(t, err)
+		if got, want := cfg.Directory, "/var/log"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("FileMode", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"FileMode": "0600"})
+		ensureError(t, err)
+		if got, want := cfg.FileMode, fs.FileMode(0600); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"FileMode": "0689"})
+		ensureError(t, err, "FileMode", "octal")
+
+		_, err = ConfigFromMap(map[string]string{"FileMode": "4755"})
+		ensureError(t, err, "FileMode", "permission bits")
+	})
+
+	t.Run("FlushOnBufferFull", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"FlushOnBufferFull": "true"})
+		ensureError(t, err)
+		if got, want := cfg.FlushOnBufferFull, true; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"FlushOnBufferFull": "sometimes"})
+		ensureError(t, err, "FlushOnBufferFull", "sometimes")
+	})
+
+	t.Run("IdleCloseAfter", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"IdleCloseAfter": "90s"})
+		ensureError(t, err)
+		if got, want := cfg.IdleCloseAfter, 90*time.Second; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"IdleCloseAfter": "soon"})
+		ensureError(t, err, "IdleCloseAfter", "soon")
+	})
+
+	t.Run("MaxBytes", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"MaxBytes": "10MiB"})
+		ensureError(t, err)
+		if got, want := cfg.MaxBytes, Megabytes(10); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"MaxBytes": "ten"})
+		ensureError(t, err, "MaxBytes", "ten")
+	})
+
+	t.Run("MultiDestination", func(t *testing.T) {
+		value := strings.Join([]string{"/var/log/a", "/var/log/b"}, string(filepath.ListSeparator))
+		cfg, err := ConfigFromMap(map[string]string{"MultiDestination": value})
+		ensureError(t, err)
+		if got, want := fmt.Sprint(cfg.MultiDestination), "[/var/log/a /var/log/b]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"MultiDestination": "/var/log/a" + string(filepath.ListSeparator)})
+		ensureError(t, err, "MultiDestination", "empty directory")
+	})
+
+	t.Run("TimeFormat", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{"TimeFormat": DateTime})
+		ensureError(t, err)
+		if got, want := cfg.TimeFormat, DateTime; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("first error by key", func(t *testing.T) {
+		_, err := ConfigFromMap(map[string]string{
+			"MaxBytes":      "bad",
+			"BufferSizeMax": "bad",
+		})
+		ensureError(t, err, "BufferSizeMax")
+	})
+}
+
+func TestDefaultConfig(t *testing.T) {
+	directory := t.TempDir()
+	previous, err := os.Getwd()
+	ensureError(t, err)
+	ensureError(t, os.Chdir(directory))
+	defer func() { ensureError(t, os.Chdir(previous)) }()
+
+	dc := DefaultConfig()
+	if got, want := dc.BufferSizeMax, DefaultBufferSize; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := dc.MaxBytes, int64(DefaultMaxBytes); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := dc.FileMode, DefaultFileMode; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := dc.MaxNameBytes, DefaultMaxNameBytes; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	fromNil, err := NewLogWriter(nil)
+	ensureError(t, err)
+	ensureError(t, fromNil.Close())
+
+	fromDefault, err := NewLogWriter(&dc)
+	ensureError(t, err)
+	ensureError(t, fromDefault.Close())
+
+	if got, want := fromDefault.cfg.String(), fromNil.cfg.String(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := fromDefault.CurrentFile(), fromNil.CurrentFile(); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		got := Config{Directory: "/var/log"}.String()
+		for _, want := range []string{
+			fmt.Sprintf("BaseNamePrefix: %q", programBaseNamePrefix(os.Args[0], runtime.GOOS)),
+			"BufferSizeMax: 128",
+			`Directory: "/var/log"`,
+			"FileMode: 0644",
+			"MaxBytes: 104857600",
+			"MaxNameBytes: 255",
+			`NameSeparator: "."`,
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+		if strings.Contains(got, "TimeFormat") {
+			t.Errorf("GOT: %q; WANT: unset fields omitted", got)
+		}
+	})
+
+	t.Run("optional fields", func(t *testing.T) {
+		got := Config{
+			IdleCloseAfter: time.Minute,
+			RotateOnMarker: []byte("=== RESTART"),
+			TimeFormat:     DateTime,
+			TimeFormatter:  nanoDateTimeFormatter,
+		}.String()
+		for _, want := range []string{
+			"IdleCloseAfter: 1m0s",
+			`RotateOnMarker: "=== RESTART"`,
+			`TimeFormat: "2006-01-02T15-04-05.000Z0700"`,
+			"TimeFormatter: func",
+		} {
+			if !strings.Contains(got, want) {
+				t.Errorf("GOT: %q; WANT: %q", got, want)
+			}
+		}
+	})
+}
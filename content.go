@@ -0,0 +1,69 @@
+package golw
+
+import (
+	"math"
+)
+
+// contentDefinedGear is a table of pseudo-random values, one for each
+// possible byte value, used to compute the rolling gear hash for
+// content defined rotation. It is generated deterministically so
+// rotation boundaries are the same for the same content every time.
+var contentDefinedGear = func() (gear [256]uint64) {
+	// splitmix64
+	var state uint64 = 0x676f6c77 // "golw"
+	for i := range gear {
+		state += 0x9e3779b97f4a7c15
+		z := state
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		gear[i] = z ^ (z >> 31)
+	}
+	return gear
+}()
+
+// makeContentDefinedMask returns a mask with the number of bits
+// required for boundaries to occur on average every distance bytes.
+func makeContentDefinedMask(distance int64) uint64 {
+	var bits uint
+	for bits < 63 && int64(1)<<(bits+1) <= distance {
+		bits++
+	}
+	return 1<<bits - 1
+}
+
+// maxFileBytes returns the size a log file may not exceed, unless a
+// single write is larger.
+func (lw *LogWriter) maxFileBytes() int64 {
+	if lw.cfg.NoRotate || lw.suppressed {
+		return math.MaxInt64
+	}
+	if lw.cfg.ContentDefinedRotation {
+		return lw.cfg.ContentDefinedMaxBytes
+	}
+	return lw.cfg.MaxBytes
+}
+
+// wouldExceedMaxFileBytes returns true when writing n more bytes to the
+// open log file would make it exceed maxFileBytes. It compares n with
+// the room remaining in the log file, rather than adding n to the size
+// of the log file, so the comparison cannot overflow.
+func (lw *LogWriter) wouldExceedMaxFileBytes(n int64) bool {
+	return n > lw.maxFileBytes()-lw.fileSizeNow
+}
+
+// scanContentDefined continues the rolling gear hash from hash over p,
+// which will be written to the log file after size bytes, returning
+// the resulting hash and whether p contains a content defined
+// boundary. Because each byte shifts the hash one bit, a byte no
+// longer affects the hash after another 64 bytes, so boundaries only
+// depend on the content immediately before them.
+func (lw *LogWriter) scanContentDefined(hash uint64, size int64, p []byte) (uint64, bool) {
+	for _, b := range p {
+		hash = hash<<1 + contentDefinedGear[b]
+		size++
+		if size >= lw.cfg.ContentDefinedMinBytes && hash&lw.contentDefinedMask == 0 {
+			return hash, true
+		}
+	}
+	return hash, false
+}
@@ -0,0 +1,96 @@
+package golw
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestContentDefinedRotation(t *testing.T) {
+	const maxBytes = 1024
+
+	// writeLines writes lines to a new log writer, where the first
+	// line is only written when insert is true, and returns the
+	// contents of each log file written.
+	writeLines := func(t *testing.T, bufferSizeMax int, insert bool) []string {
+		t.Helper()
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:         "cdc",
+			BufferSizeMax:          bufferSizeMax,
+			ContentDefinedRotation: true,
+			Directory:              directory,
+			IncludeSequence:        true,
+			MaxBytes:               maxBytes,
+			TimeFormatter:          func(time.Time) string { return "20220322T120000" },
+		})
+		ensureError(t, err)
+
+		if insert {
+			_, err = lw.Write([]byte("inserted line that shifts every following byte\n"))
+			ensureError(t, err)
+		}
+
+		var state uint64 = 13
+		for i := 0; i < 2000; i++ {
+			state = state*6364136223846793005 + 1442695040888963407
+			_, err = fmt.Fprintf(lw, "line %d: %x\n", i, state>>(state%32))
+			ensureError(t, err)
+		}
+
+		ensureError(t, lw.Close())
+
+		var contents []string
+		for _, archive := range archivedLogs(t, directory, "cdc") {
+			contents = append(contents, string(readFile(t, archive)))
+		}
+		return contents
+	}
+
+	for _, bufferSizeMax := range []int{-1, 4096} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			original := writeLines(t, bufferSizeMax, false)
+			modified := writeLines(t, bufferSizeMax, true)
+
+			if len(original) < 10 {
+				t.Fatalf("GOT: %v; WANT: at least 10 rotated log files", len(original))
+			}
+
+			// Every rotated log file, other than possibly the last one,
+			// ought to be within the bounds.
+			for i, content := range original[:len(original)-1] {
+				if len(content) < maxBytes/2 || len(content) > maxBytes*2 {
+					t.Errorf("file %d: GOT: %v; WANT: between %v and %v", i, len(content), maxBytes/2, maxBytes*2)
+				}
+			}
+
+			// Inserting a line at the start only changes the first few
+			// log files, after which the content defined boundaries
+			// resynchronize and the log files are identical.
+			unchanged := make(map[string]bool, len(original))
+			for _, content := range original {
+				unchanged[content] = true
+			}
+			var same int
+			for _, content := range modified {
+				if unchanged[content] {
+					same++
+				}
+			}
+			if got, want := same, len(original)-3; got < want {
+				t.Errorf("GOT: %v; WANT: at least %v identical log files", got, want)
+			}
+		})
+	}
+
+	t.Run("invalid bounds", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			ContentDefinedMinBytes: 2048,
+			ContentDefinedRotation: true,
+			Directory:              t.TempDir(),
+			MaxBytes:               maxBytes,
+		})
+		ensureError(t, err, "content defined rotation")
+	})
+}
@@ -0,0 +1,82 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// lockRotation acquires the exclusive coordination lock, then reports
+// whether the open log file has already been rotated by another
+// process, by checking whether the log file path still refers to the
+// file this LogWriter opened. The caller must invoke the returned
+// function to release the lock once it has finished rotating.
+func (lw *LogWriter) lockRotation() (func(), bool, error) {
+	if lw.tracing() {
+		lw.trace("lockRotation", "lock", lw.cfg.CoordinationLock)
+	}
+
+	fp, err := os.OpenFile(lw.cfg.CoordinationLock, os.O_RDWR|os.O_CREATE, lw.fileModeFor(filepath.Dir(lw.cfg.CoordinationLock)))
+	if err != nil {
+		return nil, false, fmt.Errorf("cannot open coordination lock: %w", err)
+	}
+
+	if err = lockFile(fp); err != nil {
+		_ = fp.Close()
+		return nil, false, fmt.Errorf("cannot acquire coordination lock: %w", err)
+	}
+
+	unlock := func() {
+		// Closing the lock file releases the lock even when it cannot
+		// be explicitly unlocked.
+		_ = unlockFile(fp)
+		_ = fp.Close()
+	}
+
+	st, err := os.Stat(lw.filePath)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Another process renamed the log file, but has not yet
+			// created its replacement.
+			return unlock, true, nil
+		}
+		unlock()
+		return nil, false, fmt.Errorf("cannot stat log file: %w", err)
+	}
+
+	return unlock, !os.SameFile(st, lw.fileInfo), nil
+}
+
+// openReplacement closes the open log file, which another process has
+// already rotated, and opens the log file that replaced it.
+func (lw *LogWriter) openReplacement() error {
+	lw.mustExist = false
+	if err := lw.closeLog(); err != nil {
+		return err
+	}
+	lw.contentDefinedHash, lw.contentDefinedCut = 0, false
+	return lw.reopenLog()
+}
+
+// refreshSharedSize updates the size of the open log file from the
+// file at the log file path, which other processes also append to.
+// When another process has rotated the log file, it opens the log file
+// that replaced it.
+func (lw *LogWriter) refreshSharedSize() error {
+	st, err := os.Stat(lw.filePath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot stat shared log file: %w", err)
+	}
+	if err != nil || !os.SameFile(st, lw.fileInfo) {
+		// Another process renamed the log file, and may not have
+		// created its replacement yet.
+		if lw.tracing() {
+			lw.trace("refreshSharedSize", "path", lw.filePath, "rotatedByAnotherProcess", true)
+		}
+		return lw.openReplacement()
+	}
+	lw.fileSizeNow = st.Size()
+	return nil
+}
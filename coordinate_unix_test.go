@@ -0,0 +1,121 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package golw
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinationLock(t *testing.T) {
+	directory := t.TempDir()
+
+	// Two LogWriters write to the same log files, just as two processes
+	// would, and each acquires its own lock on the lock file.
+	newWriter := func(clock *testClock) *LogWriter {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "shared",
+			BufferSizeMax:    -1,
+			CoordinationLock: filepath.Join(directory, "shared.lock"),
+			Directory:        directory,
+		})
+		ensureError(t, err)
+		setClock(lw, clock)
+		return lw
+	}
+
+	clock := newTestClock()
+	first := newWriter(clock)
+	second := newWriter(clock)
+
+	_, err := first.Write([]byte("first 1\n"))
+	ensureError(t, err)
+	_, err = second.Write([]byte("second 1\n"))
+	ensureError(t, err)
+
+	// The first writer rotates the shared log file.
+	ensureError(t, first.Rotate())
+
+	// The second writer discovers the log file it has open was already
+	// rotated, so it only switches to the new log file.
+	clock.Advance(time.Second)
+	ensureError(t, second.Rotate())
+
+	archives := archivedLogs(t, directory, "shared")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("first 1\nsecond 1\n"))
+
+	_, err = first.Write([]byte("first 2\n"))
+	ensureError(t, err)
+	_, err = second.Write([]byte("second 2\n"))
+	ensureError(t, err)
+
+	ensureError(t, first.Close())
+	ensureError(t, second.Close())
+
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "shared.log")), []byte("first 2\nsecond 2\n"))
+
+	if got, want := first.Stats().Rotations, int64(1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := second.Stats().Rotations, int64(0); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestCoordinationLockConcurrent(t *testing.T) {
+	directory := t.TempDir()
+
+	const writers = 2
+	const lines = 500
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+
+	for i := 0; i < writers; i++ {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "concurrent",
+			BufferSizeMax:    -1,
+			CoordinationLock: filepath.Join(directory, "concurrent.lock"),
+			Directory:        directory,
+			IncludeSequence:  true,
+			MaxBytes:         256,
+		})
+		ensureError(t, err)
+
+		wg.Add(1)
+		go func(i int, lw *LogWriter) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				if _, err := fmt.Fprintf(lw, "writer %d line %d\n", i, j); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- lw.Close()
+		}(i, lw)
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ensureError(t, err)
+	}
+
+	// No line may be lost, even though both writers rotated the same
+	// log files many times.
+	var count int
+	for _, path := range append(archivedLogs(t, directory, "concurrent"), filepath.Join(directory, "concurrent.log")) {
+		count += bytes.Count(readFile(t, path), newline)
+	}
+	if got, want := count, writers*lines; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
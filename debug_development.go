@@ -8,6 +8,10 @@ import (
 	"os"
 )
 
+// debugging is true for development builds, which print trace events
+// to stderr.
+const debugging = true
+
 // debug formats and prints arguments to stderr for development builds
 func debug(f string, a ...interface{}) {
 	os.Stderr.Write([]byte("golw: " + fmt.Sprintf(f, a...)))
@@ -3,5 +3,8 @@
 
 package golw
 
+// debugging is false for release builds, which print nothing.
+const debugging = false
+
 // debug is a no-op for release builds
 func debug(_ string, _ ...interface{}) {}
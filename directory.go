@@ -0,0 +1,108 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// directoryRemoved returns true when err was caused by a file not
+// existing, and the reason is that the directory of the log file no
+// longer exists.
+func (lw *LogWriter) directoryRemoved(err error) bool {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return false
+	}
+	_, err = os.Stat(lw.logDirectory())
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// createDirectory creates the directory of the log file, along with any
+// missing parent directories, after the log file could not be opened
+// because of cause, and reports that it did so to OnError.
+func (lw *LogWriter) createDirectory(cause error) error {
+	directory := lw.logDirectory()
+	if lw.tracing() {
+		lw.trace("createDirectory", "directory", directory)
+	}
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("cannot create log directory: %w", err)
+	}
+	lw.reportError(fmt.Errorf("cannot open log file in missing directory, so created directory: %w", cause))
+	return nil
+}
+
+// explainOpenError returns err, which was returned when opening the log
+// file in directory, wrapped with a hint of how to resolve it when it
+// is a common mistake the first time a program is run, such as the
+// directory being on a read-only file system, or not writable by the
+// user running the program.
+func explainOpenError(directory string, err error) error {
+	switch {
+	case isReadOnly(err):
+		return fmt.Errorf("cannot open log file in directory on read-only file system: %q; configure a Directory on a writable file system: %w", directory, err)
+	case errors.Is(err, fs.ErrPermission):
+		return fmt.Errorf("cannot open log file in directory without permission: %q; grant the user running this program write permission to it, or configure another Directory: %w", directory, err)
+	}
+	return err
+}
+
+// logDirectory returns the directory of the log file.
+func (lw *LogWriter) logDirectory() string {
+	return filepath.Dir(lw.filePath)
+}
+
+// nextDestination changes the log file path to the next directory of
+// MultiDestination, so the replacement of a rotated log file is
+// created there.
+func (lw *LogWriter) nextDestination() {
+	if len(lw.cfg.MultiDestination) == 0 {
+		return
+	}
+	lw.destination = (lw.destination + 1) % len(lw.cfg.MultiDestination)
+	lw.filePath = filepath.Join(lw.cfg.MultiDestination[lw.destination], activeName(&lw.cfg))
+}
+
+// activeName returns the base name of the open log file, which is the
+// hidden staging name when StageActive is set.
+func activeName(cfg *Config) string {
+	name, _ := fitActiveName(cfg)
+	return name
+}
+
+// fitActiveName returns the base name of the log file, and whether its
+// prefix was shortened to respect MaxNameBytes.
+func fitActiveName(cfg *Config) (string, bool) {
+	if cfg.StageActive {
+		prefix, shortened := fitPrefix(cfg.BaseNamePrefix, ".log.partial", cfg.MaxNameBytes-1)
+		return "." + prefix + ".log.partial", shortened
+	}
+	prefix, shortened := fitPrefix(cfg.BaseNamePrefix, ".log", cfg.MaxNameBytes)
+	return prefix + ".log", shortened
+}
+
+// upcomingDirectory returns the directory in which the replacement of
+// the log file will be created when it is rotated.
+func (lw *LogWriter) upcomingDirectory() string {
+	if len(lw.cfg.MultiDestination) == 0 {
+		return lw.cfg.Directory
+	}
+	return lw.cfg.MultiDestination[(lw.destination+1)%len(lw.cfg.MultiDestination)]
+}
+
+// fileModeFor returns the file mode with which to create files in
+// directory, which is its DirFileMode entry when it has one, compared
+// after cleaning both paths, and is FileMode otherwise.
+func (lw *LogWriter) fileModeFor(directory string) fs.FileMode {
+	if len(lw.cfg.DirFileMode) > 0 {
+		directory = filepath.Clean(directory)
+		for key, mode := range lw.cfg.DirFileMode {
+			if filepath.Clean(key) == directory {
+				return mode
+			}
+		}
+	}
+	return lw.cfg.FileMode
+}
@@ -0,0 +1,272 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCreateDirectory(t *testing.T) {
+	t.Run("created when missing", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "a", "b")
+
+		var reported []error
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "created",
+			BufferSizeMax:   -1,
+			CreateDirectory: true,
+			Directory:       directory,
+			OnError:         func(err error) { reported = append(reported, err) },
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		if _, err = os.Stat(filepath.Join(directory, "created.log")); err != nil {
+			t.Errorf("GOT: %v; WANT: %v", err, nil)
+		}
+		if got, want := len(reported), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("error when missing", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "missing")
+
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix: "missing",
+			Directory:      directory,
+		})
+		ensureError(t, err, "no such file or directory")
+	})
+}
+
+func TestDirectoryRemoved(t *testing.T) {
+	t.Run("recreated with CreateDirectory", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "logs")
+		ensureError(t, os.Mkdir(directory, 0755))
+
+		var reported []error
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "removed",
+			BufferSizeMax:   -1,
+			CreateDirectory: true,
+			Directory:       directory,
+			OnError:         func(err error) { reported = append(reported, err) },
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+
+		ensureError(t, os.RemoveAll(directory))
+
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+
+		_, err = lw.Write([]byte("line 3\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		if got, want := len(reported), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, reported[0], "directory was removed")
+		ensureError(t, reported[1], "created directory")
+
+		if got, want := string(readFile(t, filepath.Join(directory, "removed.log"))), "line 3\n"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+		if got, want := len(archivedLogs(t, directory, "removed")), 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.Stats().Rotations, int64(1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("error without CreateDirectory", func(t *testing.T) {
+		directory := filepath.Join(t.TempDir(), "logs")
+		ensureError(t, os.Mkdir(directory, 0755))
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "removed",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+
+		ensureError(t, os.RemoveAll(directory))
+
+		ensureError(t, lw.Rotate(), "directory was removed")
+
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err, "no such file or directory")
+
+		ensureError(t, lw.Close())
+	})
+}
+
+func TestExplainOpenError(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "logs")
+	path := filepath.Join(directory, "explain.log")
+
+	t.Run("permission denied", func(t *testing.T) {
+		cause := &fs.PathError{Op: "open", Path: path, Err: fs.ErrPermission}
+		err := explainOpenError(directory, cause)
+		ensureError(t, err, "without permission", directory, "write permission")
+		if !errors.Is(err, fs.ErrPermission) {
+			t.Errorf("GOT: %v; WANT: %v", err, fs.ErrPermission)
+		}
+	})
+
+	t.Run("other errors unchanged", func(t *testing.T) {
+		cause := &fs.PathError{Op: "open", Path: path, Err: fs.ErrNotExist}
+		if got := explainOpenError(directory, cause); got != error(cause) {
+			t.Errorf("GOT: %v; WANT: %v", got, cause)
+		}
+	})
+
+	t.Run("NewLogWriter", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+			t.Skip("directory permissions are not enforced for this user")
+		}
+		directory := t.TempDir()
+		if err := os.Chmod(directory, 0555); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Chmod(directory, 0755)
+
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix: "explain",
+			Directory:      directory,
+		})
+		ensureError(t, err, "without permission", directory)
+	})
+}
+
+func TestDirFileMode(t *testing.T) {
+	t.Run("mode per directory", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("file permissions are not enforced on windows")
+		}
+		root := t.TempDir()
+		first, second := filepath.Join(root, "disk1"), filepath.Join(root, "disk2")
+		ensureError(t, os.Mkdir(first, 0755))
+		ensureError(t, os.Mkdir(second, 0755))
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "striped",
+			BufferSizeMax:    -1,
+			DirFileMode:      map[string]fs.FileMode{second + "/": 0600},
+			FileMode:         0640,
+			IncludeSequence:  true,
+			MaxBytes:         10,
+			MultiDestination: []string{first, second},
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		for i := 1; i <= 4; i++ {
+			_, err = fmt.Fprintf(lw, "line %d\n", i)
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		// Line 4 is written to the log file created in the second
+		// directory, after each directory has rotated log files.
+		st, err := os.Stat(filepath.Join(second, "striped.log"))
+		ensureError(t, err)
+		if got, want := st.Mode().Perm(), fs.FileMode(0600); got != want {
+			t.Errorf("GOT: %#o; WANT: %#o", got, want)
+		}
+
+		for directory, want := range map[string]fs.FileMode{first: 0640, second: 0600} {
+			paths := archivedLogs(t, directory, "striped")
+			if got, want := len(paths), 1; got < want {
+				t.Fatalf("%s: GOT: %v; WANT: %v", directory, got, want)
+			}
+			for _, path := range paths {
+				st, err := os.Stat(path)
+				ensureError(t, err)
+				if got := st.Mode().Perm(); got != want {
+					t.Errorf("%s: GOT: %#o; WANT: %#o", path, got, want)
+				}
+			}
+		}
+	})
+
+	t.Run("archive directory", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("file permissions are not enforced on windows")
+		}
+		directory := t.TempDir()
+		archive := filepath.Join(directory, "archive")
+		ensureError(t, os.Mkdir(archive, 0755))
+
+		lw, err := NewLogWriter(&Config{
+			ArchiveDirFunc: func(string, int) string { return archive },
+			BaseNamePrefix: "moved",
+			BufferSizeMax:  -1,
+			DirFileMode:    map[string]fs.FileMode{archive: 0600},
+			Directory:      directory,
+			FileMode:       0644,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, archive, "moved")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		for path, want := range map[string]fs.FileMode{
+			archives[0]:                           0600,
+			filepath.Join(directory, "moved.log"): 0644,
+		} {
+			st, err := os.Stat(path)
+			ensureError(t, err)
+			if got := st.Mode().Perm(); got != want {
+				t.Errorf("%s: GOT: %#o; WANT: %#o", path, got, want)
+			}
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			Directory:   t.TempDir(),
+			DirFileMode: map[string]fs.FileMode{"/var/log": fs.ModeSetuid | 0644},
+		})
+		ensureError(t, err, "/var/log", "permission bits")
+	})
+
+	t.Run("ConfigFromMap", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{
+			"DirFileMode": "/disk1=0600" + string(os.PathListSeparator) + "/disk2=0640",
+		})
+		ensureError(t, err)
+		if got, want := fmt.Sprint(cfg.DirFileMode), fmt.Sprint(map[string]fs.FileMode{"/disk1": 0600, "/disk2": 0640}); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		_, err = ConfigFromMap(map[string]string{"DirFileMode": "/disk1"})
+		ensureError(t, err, "DirFileMode", "'='")
+
+		_, err = ConfigFromMap(map[string]string{"DirFileMode": "/disk1=4755"})
+		ensureError(t, err, "DirFileMode", "permission bits")
+	})
+}
@@ -0,0 +1,105 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package golw
+
+import (
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// interruptedFile is a logFile that writes at most max bytes of each
+// write to the file it wraps, and then fails with EINTR, until its
+// budget of interruptions is exhausted.
+type interruptedFile struct {
+	logFile
+	max        int
+	interrupts int
+}
+
+func (f *interruptedFile) Write(p []byte) (int, error) {
+	if f.interrupts > 0 {
+		f.interrupts--
+		if len(p) > f.max {
+			p = p[:f.max]
+		}
+		n, _ := f.logFile.Write(p)
+		return n, syscall.EINTR
+	}
+	return f.logFile.Write(p)
+}
+
+func TestInterruptedWrite(t *testing.T) {
+	t.Run("unbuffered", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "eintr",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+
+		lw.filePointer = &interruptedFile{logFile: lw.filePointer, max: 0, interrupts: 1}
+		n, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		if got, want := n, 7; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		lw.filePointer = &interruptedFile{logFile: lw.filePointer, max: 3, interrupts: 2}
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "eintr.log")), []byte("line 1\nline 2\n"))
+		if got, want := lw.stats.BytesWritten, int64(14); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("buffered", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "eintr",
+			BufferSizeMax:  1024,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{"line 1\n", "line 2\n", "partial"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+
+		lw.filePointer = &interruptedFile{logFile: lw.filePointer, max: 10, interrupts: 1}
+		ensureError(t, lw.Flush())
+		if got, want := string(lw.buf), "partial"; got != want {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "eintr.log")), []byte("line 1\nline 2\npartial\n"))
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "eintr",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+
+		fp := lw.filePointer
+		lw.filePointer = &interruptedFile{logFile: fp, max: 0, interrupts: maxInterruptedWrites}
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err, syscall.EINTR.Error())
+
+		lw.filePointer = fp
+		ensureError(t, lw.Close())
+	})
+}
@@ -0,0 +1,216 @@
+package golw
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// EnforceSizePolicy returns the paths of the rotated log files in the
+// log directory that are larger than MaxBytes, such as those rotated
+// before Reconfigure reduced MaxBytes, so a program may bring the
+// directory into compliance with a tighter policy. When
+// SplitOversizeBackups is true, it also splits each of them into
+// several rotated log files no larger than MaxBytes, each holding whole
+// lines, or whole frames when FrameMode is true, except that a single
+// line larger than MaxBytes is kept in a file of its own. The first
+// part replaces the original file, and the others are named after it
+// with a hyphen and the smallest numbers that make their names unique,
+// just as ClobberSuffix names rotated log files, so later calls only
+// recognize those parts when ClobberPolicy is ClobberSuffix. Rotated log
+// files that ArchiveDirFunc moved to other directories, and compressed
+// rotated log files, are not scanned. The open log file is neither
+// scanned nor affected.
+func (lw *LogWriter) EnforceSizePolicy() ([]string, error) {
+	lw.lock()
+	directory, current, maxBytes := lw.logDirectory(), lw.filePath, lw.maxFileBytes()
+	split, frameMode, mode := lw.cfg.SplitOversizeBackups, lw.cfg.FrameMode, lw.fileModeFor(directory)
+	lw.unlock()
+
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read log directory: %w", err)
+	}
+
+	var oversize []string
+	for _, entry := range entries {
+		path := filepath.Join(directory, entry.Name())
+		if !entry.Type().IsRegular() || path == current {
+			continue
+		}
+		if backup, ok := lw.ParseBackupName(entry.Name()); !ok || backup.Compressed {
+			continue // splitting would corrupt a compressed file
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue // removed since the directory was read
+			}
+			return oversize, fmt.Errorf("cannot stat rotated log file: %w", err)
+		}
+		if info.Size() <= maxBytes {
+			continue
+		}
+		oversize = append(oversize, path)
+		if split {
+			if lw.tracing() {
+				lw.trace("EnforceSizePolicy", "path", path, "bytes", info.Size())
+			}
+			if err = splitBackup(path, maxBytes, frameMode, mode); err != nil {
+				return oversize, err
+			}
+		}
+	}
+	return oversize, nil
+}
+
+// splitBackup splits the rotated log file at path into parts no larger
+// than maxBytes, without splitting any record, as described by
+// EnforceSizePolicy. The parts are written to temporary files first, so
+// when it fails before renaming them, path is unchanged.
+func splitBackup(path string, maxBytes int64, frameMode bool, mode fs.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot split rotated log file: %w", err)
+	}
+	defer src.Close()
+
+	directory, base := filepath.Split(path)
+	var parts []string
+	var dst *os.File
+	var size int64
+
+	closePart := func() error {
+		err := dst.Sync()
+		if err2 := dst.Close(); err == nil {
+			err = err2
+		}
+		if err == nil {
+			err = os.Chmod(dst.Name(), mode)
+		}
+		dst = nil
+		return err
+	}
+
+	fail := func(err error) error {
+		if dst != nil {
+			_ = dst.Close()
+		}
+		for _, part := range parts {
+			_ = os.Remove(part)
+		}
+		return fmt.Errorf("cannot split rotated log file: %w", err)
+	}
+
+	st, err := src.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot split rotated log file: %w", err)
+	}
+	remaining := st.Size()
+
+	r := bufio.NewReader(src)
+	for {
+		record, rerr := readRecord(r, frameMode, remaining)
+		remaining -= int64(len(record))
+		if len(record) > 0 {
+			if dst != nil && size > 0 && size+int64(len(record)) > maxBytes {
+				if err = closePart(); err != nil {
+					return fail(err)
+				}
+			}
+			if dst == nil {
+				if dst, err = os.CreateTemp(directory, "."+base+".split.*"); err != nil {
+					return fail(err)
+				}
+				parts = append(parts, dst.Name())
+				size = 0
+			}
+			if _, err = dst.Write(record); err != nil {
+				return fail(err)
+			}
+			size += int64(len(record))
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return fail(rerr)
+		}
+	}
+	if dst != nil {
+		if err = closePart(); err != nil {
+			return fail(err)
+		}
+	}
+	if len(parts) < 2 {
+		// The file holds a single record, which cannot be split.
+		for _, part := range parts {
+			_ = os.Remove(part)
+		}
+		return nil
+	}
+
+	// Publish the later parts before replacing the original file with
+	// the first part, so a failure part way through duplicates data
+	// rather than losing it.
+	stem := filepath.Join(directory, strings.TrimSuffix(base, ".log"))
+	suffix := 0
+	for i, part := range parts[1:] {
+		var target string
+		for {
+			suffix++
+			target = fmt.Sprintf("%s-%d.log", stem, suffix)
+			if _, err = os.Lstat(target); errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+		}
+		if err = os.Rename(part, target); err != nil {
+			for _, part := range parts[i+1:] {
+				_ = os.Remove(part)
+			}
+			return fmt.Errorf("cannot split rotated log file: %w", err)
+		}
+	}
+	if err = os.Rename(parts[0], path); err != nil {
+		_ = os.Remove(parts[0])
+		return fmt.Errorf("cannot split rotated log file: %w", err)
+	}
+	return nil
+}
+
+// readRecord returns the next line from r, including its newline, or
+// the next frame, including its header, when frameMode is true. At the
+// end of r it returns any remaining bytes, which do not form a complete
+// record, along with io.EOF. The remaining argument is the number of
+// bytes r has yet to return, so that a frame header claiming a longer
+// payload, as in a corrupt or truncated file, is treated as the start
+// of such a partial record, rather than causing the claimed length to
+// be allocated.
+func readRecord(r *bufio.Reader, frameMode bool, remaining int64) ([]byte, error) {
+	if !frameMode {
+		return r.ReadBytes('\n')
+	}
+	frame := make([]byte, frameHeaderSize)
+	if n, err := io.ReadFull(r, frame); err != nil {
+		return frame[:n], io.EOF
+	}
+	length := int64(binary.BigEndian.Uint32(frame))
+	if length > remaining-frameHeaderSize {
+		rest, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return append(frame, rest...), io.EOF
+	}
+	frame = append(frame, make([]byte, length)...)
+	if n, err := io.ReadFull(r, frame[frameHeaderSize:]); err != nil {
+		return frame[:frameHeaderSize+n], io.EOF
+	}
+	return frame, nil
+}
@@ -0,0 +1,121 @@
+package golw
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestEnforceSizePolicy(t *testing.T) {
+	directory := t.TempDir()
+
+	var want []byte
+	for i := 0; i < 10; i++ {
+		want = append(want, fmt.Sprintf("line %d\n", i)...)
+	}
+	long := strings.Repeat("x", 59) + "\n"
+	want = append(want, long...)
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "enforce",
+		BufferSizeMax:  -1,
+		ClobberPolicy:  ClobberSuffix,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+	_, err = lw.Write(want)
+	ensureError(t, err)
+	ensureError(t, lw.Rotate())
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "enforce")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	for _, split := range []bool{false, true} {
+		t.Run(fmt.Sprintf("SplitOversizeBackups %t", split), func(t *testing.T) {
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:       "enforce",
+				BufferSizeMax:        -1,
+				ClobberPolicy:        ClobberSuffix,
+				Directory:            directory,
+				MaxBytes:             20,
+				SplitOversizeBackups: split,
+			})
+			ensureError(t, err)
+			defer func() { ensureError(t, lw.Close()) }()
+
+			oversize, err := lw.EnforceSizePolicy()
+			ensureError(t, err)
+			if got, want := strings.Join(oversize, ","), archives[0]; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			parts := archivedLogs(t, directory, "enforce")
+			if !split {
+				if got, want := len(parts), 1; got != want {
+					t.Fatalf("GOT: %v; WANT: %v", got, want)
+				}
+				ensureBuffer(t, readFile(t, parts[0]), want)
+				return
+			}
+
+			// Ten lines of seven bytes fit two to a part, and the long
+			// line is kept whole in a part of its own.
+			if got, want := len(parts), 6; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			stem := strings.TrimSuffix(archives[0], ".log")
+			var joined []byte
+			for i := range parts {
+				part := archives[0]
+				if i > 0 {
+					part = fmt.Sprintf("%s-%d.log", stem, i)
+				}
+				content := readFile(t, part)
+				if len(content) > 20 && !bytes.Equal(content, []byte(long)) {
+					t.Errorf("%s: GOT: %q; WANT: at most 20 bytes", part, content)
+				}
+				if !bytes.HasSuffix(content, []byte("\n")) {
+					t.Errorf("%s: GOT: %q; WANT: whole lines", part, content)
+				}
+				joined = append(joined, content...)
+			}
+			ensureBuffer(t, joined, want)
+
+			oversize, err = lw.EnforceSizePolicy()
+			ensureError(t, err)
+			if got, want := len(oversize), 1; got != want {
+				t.Errorf("GOT: %v; WANT: only the single long line", oversize)
+			}
+		})
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	t.Run("corrupt frame length", func(t *testing.T) {
+		// The header claims a payload of nearly 4 GiB, but only three
+		// bytes of the file remain after it.
+		corrupt := []byte{0xff, 0xff, 0xff, 0xff, 'a', 'b', 'c'}
+		record, err := readRecord(bufio.NewReader(bytes.NewReader(corrupt)), true, int64(len(corrupt)))
+		if err != io.EOF {
+			t.Errorf("GOT: %v; WANT: %v", err, io.EOF)
+		}
+		ensureBuffer(t, record, corrupt)
+	})
+
+	t.Run("frames", func(t *testing.T) {
+		frames := []byte{0, 0, 0, 2, 'a', 'b', 0, 0, 0, 1, 'c'}
+		r := bufio.NewReader(bytes.NewReader(frames))
+		record, err := readRecord(r, true, int64(len(frames)))
+		ensureError(t, err)
+		ensureBuffer(t, record, frames[:6])
+		record, err = readRecord(r, true, int64(len(frames)-6))
+		ensureError(t, err)
+		ensureBuffer(t, record, frames[6:])
+	})
+}
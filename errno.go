@@ -0,0 +1,27 @@
+//go:build !plan9
+// +build !plan9
+
+package golw
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isCrossDevice returns true when err is the result of renaming a file
+// to a different file system.
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+// isInterrupted returns true when err is the result of a system call
+// being interrupted by a signal before it completed.
+func isInterrupted(err error) bool {
+	return errors.Is(err, syscall.EINTR)
+}
+
+// isReadOnly returns true when err is the result of attempting to
+// modify a file on a read-only file system.
+func isReadOnly(err error) bool {
+	return errors.Is(err, syscall.EROFS)
+}
@@ -0,0 +1,19 @@
+package golw
+
+// isCrossDevice returns false, because Plan 9 does not report renaming
+// a file to a different file system with a specific error.
+func isCrossDevice(_ error) bool {
+	return false
+}
+
+// isInterrupted returns false, because Plan 9 does not report
+// interrupted system calls with a specific error.
+func isInterrupted(_ error) bool {
+	return false
+}
+
+// isReadOnly returns false, because Plan 9 does not report modifying a
+// file on a read-only file system with a specific error.
+func isReadOnly(_ error) bool {
+	return false
+}
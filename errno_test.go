@@ -0,0 +1,23 @@
+//go:build !plan9
+// +build !plan9
+
+package golw
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestExplainReadOnly(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "logs")
+	cause := &fs.PathError{Op: "open", Path: filepath.Join(directory, "explain.log"), Err: syscall.EROFS}
+
+	err := explainOpenError(directory, cause)
+	ensureError(t, err, "read-only file system", directory, "writable file system")
+	if !errors.Is(err, syscall.EROFS) {
+		t.Errorf("GOT: %v; WANT: %v", err, syscall.EROFS)
+	}
+}
@@ -1,74 +1,274 @@
 package golw
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"time"
 )
 
-// closeLog closes file pointer to the log file.
+var newline = []byte{'\n'}
+
+// logFile is the interface the LogWriter uses to write to and close
+// the open log file.
+type logFile interface {
+	io.WriteCloser
+}
+
+// countingLines returns true when the LogWriter needs to count the
+// number of lines it writes to the open log file.
+func (lw *LogWriter) countingLines() bool {
+	return lw.cfg.CountLines || lw.cfg.FileFooterFunc != nil || lw.cfg.WriteSidecarMeta
+}
+
+// fileState returns the state of the open log file.
+func (lw *LogWriter) fileState() FileState {
+	return FileState{
+		Path:  lw.filePath,
+		Bytes: lw.fileSizeNow,
+		Lines: lw.fileLinesNow,
+	}
+}
+
+// closeLog closes file pointer to the log file, or returns fs.ErrClosed
+// when it is not open.
 func (lw *LogWriter) closeLog() error {
-	debug("closeLog\n")
+	if lw.filePointer == nil {
+		return fs.ErrClosed
+	}
+	if lw.tracing() {
+		lw.trace("closeLog", "path", lw.filePath, "bytes", lw.fileSizeNow)
+	}
 	err := lw.filePointer.Close()
 	lw.filePointer = nil
 	lw.fileSizeNow = 0
+	lw.fileLinesNow = 0
 	return err
 }
 
 // openLog opens file pointer to log file for writing, creating the
 // log file if it does not exist.
 func (lw *LogWriter) openLog() error {
-	debug("openLog\n")
-	var err error
-	lw.filePointer, err = os.OpenFile(lw.filePath,
-		os.O_WRONLY|os.O_CREATE|os.O_APPEND,
-		lw.cfg.FileMode)
+	if lw.tracing() {
+		lw.trace("openLog", "path", lw.filePath)
+	}
+
+	flag := lw.openFlag()
+	if lw.mustExist {
+		flag &^= os.O_CREATE
+	}
+	if lw.cfg.NoFollowSymlinks {
+		if err := refuseSymlink(lw.filePath); err != nil {
+			return err
+		}
+		flag |= oNoFollow
+	}
+
+	mode := lw.fileModeFor(filepath.Dir(lw.filePath))
+	fp, err := os.OpenFile(lw.filePath, flag, mode)
+	if err != nil && lw.cfg.CreateDirectory && !lw.mustExist && lw.directoryRemoved(err) {
+		if err = lw.createDirectory(err); err == nil {
+			fp, err = os.OpenFile(lw.filePath, flag, mode)
+		}
+	}
 	if err != nil {
+		if lw.mustExist && errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("cannot open log file that must already exist: %w", err)
+		}
+		if lw.cfg.NoFollowSymlinks {
+			// O_NOFOLLOW fails with a different errno on each
+			// platform, so report why the open failed when the path
+			// became a symbolic link after it was checked.
+			if serr := refuseSymlink(lw.filePath); serr != nil {
+				return serr
+			}
+		}
 		return err
 	}
 
+	return lw.useLogFile(fp)
+}
+
+// openFlag returns the flags with which to open log files.
+func (lw *LogWriter) openFlag() int {
+	if lw.cfg.Mmap {
+		// Memory mapping a file for writing requires it be opened
+		// for reading as well, and the mapping tracks the offset
+		// for appending.
+		return os.O_RDWR | os.O_CREATE
+	}
+	return os.O_WRONLY | os.O_CREATE | os.O_APPEND
+}
+
+// refuseSymlink returns an error when path is a symbolic link.
+func refuseSymlink(path string) error {
+	fi, err := os.Lstat(path)
+	if err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		return fmt.Errorf("cannot open log file that is a symbolic link: %q", path)
+	}
+	return nil
+}
+
+// useLogFile makes fp, which was opened at the log file path, the open
+// log file, or closes it when it cannot be used.
+func (lw *LogWriter) useLogFile(fp *os.File) error {
 	// Because the log file might already have some contents, check
 	// its size and store it to prevent going over the configured max
 	// log file size.
-	st, err := lw.filePointer.Stat()
+	st, err := fp.Stat()
 	if err != nil {
 		// When cannot stat the open file pointer, close the file as
 		// if it could not be opened.
-		_ = lw.filePointer.Close()
-		lw.filePointer = nil
+		_ = fp.Close()
 		return err
 	}
 
 	// Store start size so know when to rotate.
 	lw.fileSizeNow = st.Size()
+	lw.fileInfo = st
+
+	switch {
+	case lw.cfg.Mmap:
+		lw.filePointer = newMmapFile(fp, st.Size(), lw.maxFileBytes())
+	case lw.cfg.OSBuffered:
+		lw.filePointer = newOSBufferedFile(fp, lw.cfg.OSBufferSize)
+	default:
+		lw.filePointer = fp
+	}
 
 	return nil
 }
 
 // renameLog renames the log file to a name that includes the
-// timestamp of the first write written to it.
-func (lw *LogWriter) renameLog() error {
-	// timeStamp := lw.timeOfFirstWrite
-	// if timeStamp == "" {
-	// 	// Only happens when this is invoked multiple times without
-	// 	// intervening write invocation.
-	timeStamp := lw.cfg.TimeFormatter(time.Now())
-	// TODO
-	// }
+// timestamp of the first write written to it, and returns the new
+// path of the file. The log file has already been closed, so size is
+// the number of bytes it held.
+func (lw *LogWriter) renameLog(size int64) (string, error) {
+	firstWrite := lw.fileFirstWrite
+	if firstWrite.IsZero() {
+		// Only happens when the log file is rotated before this
+		// LogWriter has written to it, for instance, when the log
+		// file had contents prior to being opened.
+		firstWrite = lw.now()
+	}
+	// The time stamp is formatted when it is needed, rather than upon
+	// the first write to each log file, to keep writes from
+	// allocating.
+	timeStamp := lw.formatTime(firstWrite)
 
-	// Reset first write time so the next write stores the time it
-	// took place.
-	lw.timeOfFirstWrite = ""
+	lw.sequence++
+	if lw.cfg.SequenceStateFile != "" {
+		if err := lw.persistSequence(); err != nil {
+			lw.sequence--
+			return "", err
+		}
+	}
 
-	fileNameStamp := lw.cfg.BaseNamePrefix + "." + timeStamp + ".log"
+	nameStamp := timeStamp
+	if lw.cfg.IncludeByteRange {
+		nameStamp = formatByteRange(lw.byteOffset, lw.byteOffset+size)
+	}
+	fileNameStamp := lw.archiveStem(nameStamp, lw.sequence)
+
+	directory, err := lw.archiveDirectory(timeStamp, lw.sequence)
+	if err != nil {
+		return "", err
+	}
 
-	debug("renameLog: %s\n", fileNameStamp)
+	filePathStamp, err := lw.archivePath(filepath.Join(directory, fileNameStamp), ".log")
+	if err != nil {
+		return "", err
+	}
 
-	filePathStamp := filepath.Join(lw.cfg.Directory, fileNameStamp)
+	mode := lw.fileModeFor(directory)
+	copied, err := moveFile(lw.filePath, filePathStamp, mode)
+	lw.stats.BytesMoved += copied
+	if err != nil {
+		return "", err
+	}
+	if mode != lw.fileModeFor(filepath.Dir(lw.filePath)) {
+		// The log file was created with the mode of its own directory.
+		if err = os.Chmod(filePathStamp, mode); err != nil {
+			return "", fmt.Errorf("cannot change mode of rotated log file: %w", err)
+		}
+	}
+	if lw.tracing() {
+		lw.trace("renameLog", "path", lw.filePath, "archivePath", filePathStamp)
+	}
+	lw.byteOffset += size
 
-	return os.Rename(lw.filePath, filePathStamp)
+	lw.resetLogFile()
+	lw.nextDestination()
+	return filePathStamp, nil
+}
+
+// resetLogFile forgets the state of the log file after it is rotated,
+// in preparation for opening its replacement.
+func (lw *LogWriter) resetLogFile() {
+	// The provisioned log file was rotated, so its replacement must
+	// be created.
+	lw.mustExist = false
+
+	// Reset first write time so the first write to the new log file
+	// stores the time it took place.
+	lw.fileFirstWrite = time.Time{}
+	lw.fileLastWrite = time.Time{}
+}
+
+// archiveDirectory returns the directory to which a log file whose
+// first write has the formatted time stamp and rotation sequence number
+// is rotated, which is the log directory unless ArchiveDirFunc selects
+// another, which is created when needed.
+func (lw *LogWriter) archiveDirectory(timeStamp string, sequence uint64) (string, error) {
+	directory := lw.logDirectory()
+	if lw.cfg.ArchiveDirFunc == nil {
+		return directory, nil
+	}
+	var archiveDir string
+	if err := callSafely("ArchiveDirFunc", func() { archiveDir = lw.cfg.ArchiveDirFunc(timeStamp, int(sequence)) }); err != nil {
+		lw.reportError(err)
+	}
+	if archiveDir == "" {
+		return directory, nil
+	}
+	if !filepath.IsAbs(archiveDir) {
+		archiveDir = filepath.Join(directory, archiveDir)
+	}
+	if !lw.cfg.RequireExisting {
+		if err := os.MkdirAll(archiveDir, 0755); err != nil {
+			return "", fmt.Errorf("cannot create archive directory: %w", err)
+		}
+	}
+	return archiveDir, nil
+}
+
+// formatSequence returns the sequence number zero padded to a fixed
+// width, so that file names with the same timestamp sort in sequence
+// order.
+func formatSequence(sequence uint64) string {
+	return fmt.Sprintf("%06d", sequence)
+}
+
+// recordWrite stores the current time as the time of the most recent
+// write to the open log file, and when the open log file has yet to be
+// written to, also as the time of its first write. Later, when renaming
+// the log file with a timestamp, will use the recorded time of first
+// write in the file name for the renamed log file. Because it is
+// invoked when data is written to the log file rather than when Write
+// is invoked, the recorded times are correct for each log file even
+// when buffered data is flushed to a newly opened log file.
+func (lw *LogWriter) recordWrite() {
+	now := lw.now()
+	if lw.fileFirstWrite.IsZero() {
+		lw.fileFirstWrite = now
+		debug("time of first write: %s\n", now)
+	}
+	lw.fileLastWrite = now
 }
 
 // rotateLog closes the open log file, renames it so it includes a
@@ -77,32 +277,239 @@ func (lw *LogWriter) renameLog() error {
 // timestamp formatting callback function of the log rotator when
 // invoked with the time recorded the first time that file was written
 // to.
-//
-// TODO: Consider exporting this method, or one that flushes completed
-// extents then rotateLogs.
 func (lw *LogWriter) rotateLog() error {
-	debug("rotateLog: having written %d bytes\n", lw.fileSizeNow)
+	if lw.tracing() {
+		lw.trace("rotateLog", "path", lw.filePath, "bytes", lw.fileSizeNow)
+	}
 	var err error
 
+	if lw.cfg.CoordinationLock != "" {
+		unlock, rotated, err := lw.lockRotation()
+		if err != nil {
+			return err
+		}
+		defer unlock()
+		if rotated {
+			// Another process already rotated the log file this
+			// LogWriter has open, so simply open its replacement.
+			if lw.tracing() {
+				lw.trace("rotateLog", "path", lw.filePath, "rotatedByAnotherProcess", true)
+			}
+			return lw.openReplacement()
+		}
+	}
+
 	// TODO: Consider renaming the existing log file before follow on
 	// actions, because the current file pointer remains valid until
 	// it is closed, even after the file it points to is renamed.
 
+	if lw.cfg.FileFooterFunc != nil {
+		var footer []byte
+		if err = callSafely("FileFooterFunc", func() { footer = lw.cfg.FileFooterFunc(lw.fileState()) }); err != nil {
+			lw.reportError(err)
+		}
+		if len(footer) > 0 {
+			if _, err = lw.writeBytes(footer); err != nil {
+				return err
+			}
+		}
+	}
+
+	if lw.barrier {
+		// Barrier requires everything written so far to be durable,
+		// including what was written to this log file.
+		if err = lw.syncLog(); err != nil {
+			return err
+		}
+	}
+
+	archivedBytes, archivedLines := lw.fileSizeNow, lw.fileLinesNow
+	meta := lw.sidecarMeta()
+
 	if err = lw.closeLog(); err != nil {
+		// The file descriptor is released even when closing fails,
+		// so reopen the log file that could not be rotated, so that
+		// writes continue to be appended to it.
+		_ = lw.reopenLog()
+		return lw.rotationFailed(fmt.Errorf("cannot close log file to rotate it: %w", err), true)
+	}
+
+	archivePath, err := lw.renameLog(archivedBytes)
+	if err != nil {
+		if !lw.directoryRemoved(err) {
+			// Reopen the log file that could not be rotated, so
+			// that writes continue to be appended to it.
+			_ = lw.reopenLog()
+			return lw.rotationFailed(err, true)
+		}
+		if !lw.cfg.CreateDirectory {
+			lw.idleClosed = true
+			return lw.rotationFailed(fmt.Errorf("cannot rotate log file after its directory was removed: %w", err), false)
+		}
+		// The log file was removed along with its directory, so
+		// there is nothing left to archive. Replace it with a new
+		// log file in the recreated directory.
+		lw.reportError(fmt.Errorf("cannot rotate log file after its directory was removed: %w", err))
+		lw.resetLogFile()
+		lw.contentDefinedHash, lw.contentDefinedCut = 0, false
+		if err = lw.openNextLog(); err != nil {
+			return lw.rotationFailed(err, false)
+		}
+		lw.rotationRecovered()
+		return nil
+	}
+
+	var postRotateErr error
+	if lw.cfg.PostRotate != nil {
+		var newPath string
+		if err = callSafely("PostRotate", func() { newPath, postRotateErr = lw.cfg.PostRotate(archivePath) }); err != nil {
+			lw.reportError(err)
+		} else if postRotateErr != nil {
+			postRotateErr = fmt.Errorf("cannot post process rotated log file: %w", postRotateErr)
+		} else if newPath != "" {
+			archivePath = newPath
+		}
+	}
+
+	if lw.holdArchive {
+		// RotateAndOpen releases the rotated log file once the caller
+		// closes the reader it returns.
+		lw.heldArchive = archivePath
+	} else {
+		archivePath = lw.releaseArchive(archivePath)
+	}
+
+	lw.stats.Rotations++
+	atomic.AddUint32(&lw.rotationCount, 1)
+	lw.stats.RotatedLines = archivedLines
+	lw.contentDefinedHash, lw.contentDefinedCut = 0, false
+	lw.queueRotationEvent(RotationEvent{
+		ArchivePath: archivePath,
+		Bytes:       archivedBytes,
+		Time:        lw.now(),
+	})
+
+	if err = lw.openNextLog(); err != nil {
+		// The log file was archived, and the next write attempts to
+		// open its replacement again.
+		return lw.rotationFailed(err, false)
+	}
+	lw.rotationRecovered()
+	if postRotateErr != nil {
+		return postRotateErr
+	}
+
+	if lw.cfg.NewFileFunc != nil {
+		var preamble []byte
+		if err = callSafely("NewFileFunc", func() { preamble = lw.cfg.NewFileFunc(archivePath) }); err != nil {
+			lw.reportError(err)
+		}
+		if len(preamble) > 0 {
+			if _, err = lw.writeBytes(preamble); err != nil {
+				return err
+			}
+		}
+	}
+
+	if archivePath == "" {
+		// The rotated log file was streamed to ArchiveWriter.
+		return nil
+	}
+	if lw.cfg.WriteSidecarMeta {
+		meta.Path = archivePath
+		err = lw.writeSidecarMeta(meta)
+	}
+	lw.queueArchiveComplete(archivePath)
+	return err
+}
+
+// reopenLog opens the log file after it was closed by rotateLog. The log
+// file was closed before opening its replacement, so rotating does not
+// require an additional file descriptor. Nevertheless, when the process
+// is out of file descriptors the log file cannot be opened, in which
+// case the LogWriter behaves as though the log file was closed while
+// idle, so the next write attempts to open it again, rather than using
+// a closed log file.
+func (lw *LogWriter) reopenLog() error {
+	if err := lw.openLog(); err != nil {
+		if lw.tracing() {
+			lw.trace("reopenLog", "path", lw.filePath, "error", err)
+		}
+		lw.idleClosed = true
 		return err
 	}
+	return nil
+}
 
-	if err = lw.renameLog(); err != nil {
+// setWriteDeadline sets the deadline for the next write to the open
+// log file when configured with a write timeout, and the file supports
+// deadlines.
+func (lw *LogWriter) setWriteDeadline() error {
+	if lw.cfg.WriteTimeout <= 0 {
+		return nil
+	}
+	df, ok := lw.filePointer.(interface{ SetWriteDeadline(time.Time) error })
+	if !ok {
+		return nil
+	}
+	// The deadline is enforced by the runtime against the wall clock,
+	// so it is not calculated using the LogWriter's clock.
+	if err := df.SetWriteDeadline(time.Now().Add(lw.cfg.WriteTimeout)); err != nil && !errors.Is(err, os.ErrNoDeadline) {
 		return err
 	}
+	return nil
+}
+
+// maxInterruptedWrites is the maximum number of consecutive times
+// writeFile retries a write to the log file interrupted by a signal.
+const maxInterruptedWrites = 100
 
-	return lw.openLog()
+// writeFile writes p to the open log file. Writes interrupted by a
+// signal, which may have written only some of p, are retried with the
+// remainder of p, so programs that handle many signals do not see
+// spurious write failures. To avoid retrying forever, it gives up after
+// maxInterruptedWrites consecutive interrupted writes that made no
+// progress.
+func (lw *LogWriter) writeFile(p []byte) (int, error) {
+	if lw.filePointer == nil {
+		return 0, fs.ErrClosed
+	}
+	var nw, interrupted int
+	for {
+		n, err := lw.filePointer.Write(p[nw:])
+		if n < 0 || n > len(p)-nw {
+			// Let the caller report the invalid write result.
+			return -1, err
+		}
+		nw += n
+		if !isInterrupted(err) {
+			return nw, err
+		}
+		if nw == len(p) {
+			return nw, nil
+		}
+		if n > 0 {
+			interrupted = 0
+		} else if interrupted++; interrupted == maxInterruptedWrites {
+			return nw, err
+		}
+		if lw.tracing() {
+			lw.trace("writeFile", "interrupted", true, "remaining", len(p)-nw)
+		}
+	}
 }
 
 // writeBytes will write p to the open log file.
 func (lw *LogWriter) writeBytes(p []byte) (int, error) {
 	debug("writeBytes(%d bytes)\n", len(p))
-	nw, err := lw.filePointer.Write(p)
+	if lw.filePointer == nil {
+		return 0, fs.ErrClosed
+	}
+	if err := lw.setWriteDeadline(); err != nil {
+		return 0, err
+	}
+	lw.recordWrite()
+	nw, err := lw.writeFile(p)
 
 	if nw < 0 || nw > len(p) {
 		if err != nil {
@@ -117,6 +524,11 @@ func (lw *LogWriter) writeBytes(p []byte) (int, error) {
 	}
 
 	lw.fileSizeNow += int64(nw)
+	lw.stats.BytesWritten += int64(nw)
+	lw.stats.BytesMoved += int64(nw)
+	if lw.countingLines() {
+		lw.fileLinesNow += int64(bytes.Count(p[:nw], newline))
+	}
 
 	debug("writeBytes: fileSizeNow: %d\n", lw.fileSizeNow)
 
@@ -125,7 +537,19 @@ func (lw *LogWriter) writeBytes(p []byte) (int, error) {
 
 func (lw *LogWriter) writeExtents(extentCount, byteCount int) (int, error) {
 	debug("writeExtents(%d extents, %d bytes)\n", extentCount, byteCount)
-	nw, err := lw.filePointer.Write(lw.buf[:byteCount])
+	if lw.filePointer == nil {
+		return 0, fs.ErrClosed
+	}
+	if err := lw.setWriteDeadline(); err != nil {
+		return 0, err
+	}
+	if lw.cfg.NameByWriteTime && lw.fileFirstWrite.IsZero() && len(lw.writeTimes) > 0 {
+		// Name the log file for when its first extent was buffered,
+		// which may be before the log file was opened.
+		lw.fileFirstWrite = lw.writeTimes[0]
+	}
+	lw.recordWrite()
+	nw, err := lw.writeFile(lw.buf[:byteCount])
 
 	if nw < 0 || nw > byteCount {
 		if err != nil {
@@ -142,7 +566,18 @@ func (lw *LogWriter) writeExtents(extentCount, byteCount int) (int, error) {
 	}
 
 	lw.fileSizeNow += int64(nw)
-	lw.buf = lw.buf[nw:]
+	lw.stats.BytesWritten += int64(nw)
+	lw.stats.BytesMoved += int64(nw)
+	if lw.countingLines() {
+		lw.fileLinesNow += int64(bytes.Count(lw.buf[:nw], newline))
+	}
+	// Move the bytes that were not written to the start of the buffer
+	// rather than slicing them off, so the buffer keeps its capacity,
+	// and later writes are appended to it without reallocating.
+	if nw > 0 {
+		lw.stats.BytesMoved += int64(len(lw.buf) - nw)
+	}
+	lw.buf = lw.buf[:copy(lw.buf, lw.buf[nw:])]
 
 	if err != nil {
 		// Use the number of bytes written to determine which extents
@@ -150,14 +585,12 @@ func (lw *LogWriter) writeExtents(extentCount, byteCount int) (int, error) {
 		// then update its length accordingly.
 		extentCountMax := extentCount
 		extentCount = 0
-		for nw > 0 && extentCount < extentCountMax {
-			nw -= lw.extents[extentCount]
-			if nw < 0 {
-				// nw = -5, means we have written 5 bytes of this
-				// extent. Adding the negative value to the size of
-				// the extent will subtract that length from the count
-				// of bytes remaining to be written.
-				lw.extents[extentCount] += nw
+		for remaining := nw; remaining > 0 && extentCount < extentCountMax; {
+			remaining -= lw.extents[extentCount]
+			if remaining < 0 {
+				// remaining = -5, means 5 bytes of this extent were
+				// not written, and remain at the start of the buffer.
+				lw.extents[extentCount] = -remaining
 			} else {
 				extentCount++
 			}
@@ -165,7 +598,7 @@ func (lw *LogWriter) writeExtents(extentCount, byteCount int) (int, error) {
 		// NOTE: Intentional fall through because same code.
 	}
 
-	lw.extents = lw.extents[extentCount:]
+	lw.discardExtents(extentCount)
 
 	debug("writeExtents: fileSizeNow: %d\n", lw.fileSizeNow)
 	debug("writeExtents: extents remaining: %d\n", len(lw.extents))
@@ -173,3 +606,142 @@ func (lw *LogWriter) writeExtents(extentCount, byteCount int) (int, error) {
 
 	return nw, err
 }
+
+// discardExtents removes the first extentCount extents and their write
+// times, after their bytes have been removed from the buffer.
+func (lw *LogWriter) discardExtents(extentCount int) {
+	lw.extents = lw.extents[:copy(lw.extents, lw.extents[extentCount:])]
+	if extentCount > len(lw.writeTimes) {
+		extentCount = len(lw.writeTimes)
+	}
+	lw.writeTimes = lw.writeTimes[:copy(lw.writeTimes, lw.writeTimes[extentCount:])]
+}
+
+// repairLog ensures the existing log file at path ends with a newline,
+// either by appending a newline to complete its partial final line, or
+// when truncate is true, by removing its partial final line. It does
+// nothing when the log file does not exist or is empty.
+func repairLog(path string, truncate bool) error {
+	debug("repairLog(%q, %t)\n", path, truncate)
+
+	fp, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("cannot repair log file: %w", err)
+	}
+
+	if err = repairFile(fp, truncate); err != nil {
+		_ = fp.Close()
+		return fmt.Errorf("cannot repair log file: %w", err)
+	}
+
+	if err = fp.Close(); err != nil {
+		return fmt.Errorf("cannot repair log file: %w", err)
+	}
+	return nil
+}
+
+// repairFile ensures fp ends with a newline, as described by repairLog.
+func repairFile(fp *os.File, truncate bool) error {
+	st, err := fp.Stat()
+	if err != nil {
+		return err
+	}
+	size := st.Size()
+	if size == 0 {
+		return nil
+	}
+
+	last := make([]byte, 1)
+	if _, err = fp.ReadAt(last, size-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil // log file ends with complete line
+	}
+
+	if !truncate {
+		debug("repairFile: completing partial final line\n")
+		_, err = fp.WriteAt(newline, size)
+		return err
+	}
+
+	// Scan backwards through the log file for the newline that ends
+	// the final complete line, and truncate the log file after it.
+	buf := make([]byte, 4096)
+	end := size
+	for end > 0 {
+		start := end - int64(len(buf))
+		if start < 0 {
+			start = 0
+		}
+		chunk := buf[:end-start]
+		if _, err = fp.ReadAt(chunk, start); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(chunk, '\n'); i >= 0 {
+			debug("repairFile: truncating partial final line\n")
+			return fp.Truncate(start + int64(i) + 1)
+		}
+		end = start
+	}
+
+	debug("repairFile: truncating log file without complete lines\n")
+	return fp.Truncate(0)
+}
+
+// moveFile renames source to target, and when they are on different
+// file systems, copies source to target then removes source instead.
+// It returns the number of bytes copied, which is zero when source is
+// renamed.
+func moveFile(source, target string, mode fs.FileMode) (int64, error) {
+	err := os.Rename(source, target)
+	if err == nil || !isCrossDevice(err) {
+		return 0, err
+	}
+	debug("moveFile: copying across file systems: %q -> %q\n", source, target)
+	copied, err := copyFile(source, target, mode)
+	if err != nil {
+		return copied, err
+	}
+	return copied, os.Remove(source)
+}
+
+// copyFile copies the contents of source to target, committing target
+// to stable storage, and preserving the modification time of source. It
+// returns the number of bytes copied.
+func copyFile(source, target string, mode fs.FileMode) (int64, error) {
+	src, err := os.Open(source)
+	if err != nil {
+		return 0, fmt.Errorf("cannot copy file: %w", err)
+	}
+	defer src.Close()
+
+	st, err := src.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("cannot copy file: %w", err)
+	}
+
+	dst, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return 0, fmt.Errorf("cannot copy file: %w", err)
+	}
+
+	copied, err := io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if err2 := dst.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chtimes(target, st.ModTime(), st.ModTime())
+	}
+	if err != nil {
+		_ = os.Remove(target)
+		return copied, fmt.Errorf("cannot copy file: %w", err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,52 @@
+package golw
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// frameHeaderSize is the number of bytes of the big-endian length that
+// precedes the payload of each frame when FrameMode is true.
+const frameHeaderSize = 4
+
+// maxFramePayload is the largest payload whose length fits in a frame
+// header.
+const maxFramePayload = 1<<32 - 1
+
+// appendFrameHeader appends a frame header with a placeholder length to
+// buf, to be filled in by putFrameLength once the payload is appended.
+func appendFrameHeader(buf []byte) []byte {
+	return append(buf, 0, 0, 0, 0)
+}
+
+// putFrameLength stores the length of the payload that follows the
+// frame header at the beginning of frame.
+func putFrameLength(frame []byte) error {
+	size := uint64(len(frame) - frameHeaderSize)
+	if size > maxFramePayload {
+		return fmt.Errorf("cannot write frame larger than %d bytes: %d", uint64(maxFramePayload), size)
+	}
+	binary.BigEndian.PutUint32(frame, uint32(size))
+	return nil
+}
+
+// ReadFrame reads the next frame from r, which reads a log file written
+// with FrameMode, and returns its payload. It returns io.EOF when r is
+// exhausted at a frame boundary, and io.ErrUnexpectedEOF when r ends in
+// the middle of a frame.
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return payload, nil
+}
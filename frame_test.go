@@ -0,0 +1,121 @@
+package golw
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestFrameMode(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]Config{
+			"FileFooterFunc": {FileFooterFunc: func(FileState) []byte { return nil }},
+			"NewFileFunc":    {NewFileFunc: func(string) []byte { return nil }},
+			"RepairOnOpen":   {RepairOnOpen: true},
+			"RotateOnMarker": {RotateOnMarker: []byte("MARK")},
+		}
+		for field, cfg := range cases {
+			cfg.Directory = t.TempDir()
+			cfg.FrameMode = true
+			_, err := NewLogWriter(&cfg)
+			ensureError(t, err, "FrameMode", field)
+		}
+	})
+
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:  "frame",
+				BufferSizeMax:   bufferSizeMax,
+				Directory:       directory,
+				FrameMode:       true,
+				IncludeSequence: true,
+				MaxBytes:        20,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			// Payloads are not newline terminated, and may contain
+			// newlines. Each frame of 10 payload bytes occupies 14
+			// bytes, so only one fits in each log file.
+			records := [][]byte{
+				[]byte("record\x00\x01\x02"),
+				[]byte("two\nlines"),
+				[]byte("\xff\xfe\xfd\xfc\xfb\xfa\xf9\xf8\xf7\xf6"),
+			}
+			for _, record := range records {
+				n, err := lw.Write(record)
+				ensureError(t, err)
+				if got, want := n, len(record); got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+			ensureError(t, lw.Close())
+
+			files := append(archivedLogs(t, directory, "frame"), lw.CurrentFile())
+			if got, want := len(files), len(records); got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			for i, file := range files {
+				fh, err := os.Open(file)
+				ensureError(t, err)
+				got, err := ReadFrame(fh)
+				ensureError(t, err)
+				ensureBuffer(t, got, records[i])
+				_, err = ReadFrame(fh)
+				if err != io.EOF {
+					t.Errorf("GOT: %v; WANT: %v", err, io.EOF)
+				}
+				ensureError(t, fh.Close())
+			}
+		})
+	}
+
+	t.Run("RecordSuffix", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "frame",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			FrameMode:      true,
+			RecordSuffix:   []byte("!"),
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("hello"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("\x00\x00\x00\x06hello!"))
+	})
+}
+
+func TestReadFrame(t *testing.T) {
+	t.Run("truncated header", func(t *testing.T) {
+		_, err := ReadFrame(bytes.NewReader([]byte{0, 0}))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("GOT: %v; WANT: %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("truncated payload", func(t *testing.T) {
+		_, err := ReadFrame(bytes.NewReader([]byte{0, 0, 0, 5, 'a', 'b'}))
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("GOT: %v; WANT: %v", err, io.ErrUnexpectedEOF)
+		}
+	})
+
+	t.Run("empty payload", func(t *testing.T) {
+		got, err := ReadFrame(bytes.NewReader([]byte{0, 0, 0, 0}))
+		ensureError(t, err)
+		if len(got) != 0 {
+			t.Errorf("GOT: %q; WANT: empty payload", got)
+		}
+	})
+}
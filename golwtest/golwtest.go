@@ -0,0 +1,136 @@
+// Package golwtest provides an in-memory implementation of golw.Writer
+// for testing programs that write logs through a golw.Writer.
+package golwtest
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/karrick/golw"
+)
+
+// ErrClosed is returned when writing to, flushing, or rotating a
+// Writer after it has been closed.
+var ErrClosed = errors.New("golwtest: writer already closed")
+
+// Writer is an in-memory golw.Writer that records the data written to
+// it in memory rather than in log files. Data is never buffered, so
+// Flush only checks whether the Writer is closed, and Rotate moves the
+// current contents to the list of rotated contents. The zero value is
+// ready to use. A Writer is safe for concurrent use by multiple
+// goroutines.
+type Writer struct {
+	// Path is the name returned by CurrentFile.
+	Path string
+
+	mu      sync.Mutex
+	current bytes.Buffer
+	rotated [][]byte
+	stats   golw.Stats
+	closed  bool
+}
+
+var _ golw.Writer = (*Writer)(nil)
+
+// Write appends p to the current contents of the Writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if len(p) > 0 {
+		w.stats.Writes++
+	}
+	// Each logged byte is copied once, into the current contents.
+	w.stats.BytesLogged += int64(len(p))
+	w.stats.BytesMoved += int64(len(p))
+	w.stats.BytesWritten += int64(len(p))
+	return w.current.Write(p)
+}
+
+// Close marks the Writer closed. Its contents remain available.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	w.closed = true
+	return nil
+}
+
+// CurrentFile returns the value of the Path field.
+func (w *Writer) CurrentFile() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.Path
+}
+
+// Flush returns ErrClosed when the Writer has been closed, or nil
+// otherwise.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	return nil
+}
+
+// Rotate moves the current contents of the Writer to the end of its
+// rotated contents. Like golw.LogWriter, it does nothing when the
+// current contents are empty.
+func (w *Writer) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return ErrClosed
+	}
+	if w.current.Len() == 0 {
+		return nil
+	}
+	w.rotated = append(w.rotated, append([]byte(nil), w.current.Bytes()...))
+	w.current.Reset()
+	w.stats.Rotations++
+	return nil
+}
+
+// Stats returns a snapshot of the Writer's statistics.
+func (w *Writer) Stats() golw.Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.FileSize = int64(w.current.Len())
+	if stats.BytesLogged > 0 {
+		stats.WriteAmplification = float64(stats.BytesMoved) / float64(stats.BytesLogged)
+	}
+	return stats
+}
+
+// Current returns a copy of the data written since the most recent
+// rotation.
+func (w *Writer) Current() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte(nil), w.current.Bytes()...)
+}
+
+// Rotated returns a copy of the contents of each rotation, oldest
+// first.
+func (w *Writer) Rotated() [][]byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	rotated := make([][]byte, len(w.rotated))
+	for i, contents := range w.rotated {
+		rotated[i] = append([]byte(nil), contents...)
+	}
+	return rotated
+}
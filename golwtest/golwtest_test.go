@@ -0,0 +1,51 @@
+package golwtest
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	w := &Writer{Path: "memory.log"}
+
+	if _, err := w.Write([]byte("line 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Rotate(); err != nil { // empty rotation does nothing
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("line 2\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := fmt.Sprintf("%q", w.Rotated()), `["line 1\n"]`; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := string(w.Current()), "line 2\n"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+
+	stats := w.Stats()
+	if got, want := stats.Rotations, int64(1); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := stats.BytesWritten, int64(14); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := stats.BytesLogged, int64(14); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := stats.WriteAmplification, 1.0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late\n")); err != ErrClosed {
+		t.Errorf("GOT: %v; WANT: %v", err, ErrClosed)
+	}
+}
@@ -0,0 +1,71 @@
+package golw
+
+import (
+	"fmt"
+)
+
+// Healthy returns false while the LogWriter is degraded because its
+// most recent attempt to rotate the log file failed, and true once the
+// log file has been rotated, or at least reopened, successfully. See
+// LastError.
+//
+// When closing or renaming the log file fails, the LogWriter reopens
+// the log file at its original path so writes continue to be appended
+// to it, and the rotation is retried before the next write to the log
+// file. When the retry fails, its error is reported to OnError, and the
+// write is appended to the log file at its original path. When opening
+// the replacement log file fails, the rotated log file remains
+// archived, and opening the replacement is retried by the next write.
+func (lw *LogWriter) Healthy() bool {
+	lw.lock()
+	defer lw.unlock()
+	return lw.degradedErr == nil
+}
+
+// LastError returns the error that caused the LogWriter to become
+// degraded, or nil when it is healthy. See Healthy.
+func (lw *LogWriter) LastError() error {
+	lw.lock()
+	defer lw.unlock()
+	return lw.degradedErr
+}
+
+// rotationFailed records err as the reason the LogWriter is degraded
+// while the lock is held, and returns it. When retry is true, the log
+// file at its original path is still to be rotated, and the next write
+// to the log file retries rotating it.
+func (lw *LogWriter) rotationFailed(err error, retry bool) error {
+	if lw.tracing() {
+		lw.trace("rotationFailed", "retry", retry, "error", err)
+	}
+	lw.degradedErr = err
+	lw.rotateRetry = retry
+	return err
+}
+
+// rotationRecovered records that the LogWriter is no longer degraded
+// while the lock is held.
+func (lw *LogWriter) rotationRecovered() {
+	lw.degradedErr = nil
+	lw.rotateRetry = false
+}
+
+// retryRotation rotates the log file while the lock is held, when a
+// previous rotation failed after which the log file was reopened at its
+// original path. Because the data being written does not require a new
+// log file, a failure is reported to OnError rather than failing the
+// write, which is then appended to the log file at its original path.
+// It only returns an error when no log file is open afterwards.
+func (lw *LogWriter) retryRotation() error {
+	if !lw.rotateRetry || lw.fileSizeNow == 0 {
+		return nil
+	}
+	if lw.tracing() {
+		lw.trace("retryRotation", "path", lw.filePath)
+	}
+	if err := lw.rotateLog(); err != nil {
+		lw.reportError(fmt.Errorf("cannot retry log file rotation: %w", err))
+		return lw.ensureLogOpen()
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// closeFailingFile is a logFile that closes the file it wraps, and then
+// returns an error, as when a deferred write error is reported by
+// closing a file.
+type closeFailingFile struct {
+	logFile
+}
+
+func (f closeFailingFile) Close() error {
+	_ = f.logFile.Close()
+	return errors.New("injected close failure")
+}
+
+func ensureHealthy(tb testing.TB, lw *LogWriter, want bool) {
+	tb.Helper()
+	if got := lw.Healthy(); got != want {
+		tb.Errorf("Healthy: GOT: %v; WANT: %v", got, want)
+	}
+	if got := lw.LastError(); (got == nil) != want {
+		tb.Errorf("LastError: GOT: %v; WANT: healthy %v", got, want)
+	}
+}
+
+func TestRotationFailure(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("close BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "health",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+			ensureHealthy(t, lw, true)
+
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Flush())
+
+			lw.filePointer = closeFailingFile{lw.filePointer}
+			ensureError(t, lw.Rotate(), "cannot close log file", "injected close failure")
+			ensureHealthy(t, lw, false)
+			if got := archivedLogs(t, directory, "health"); len(got) != 0 {
+				t.Errorf("GOT: %v; WANT: no archived logs", got)
+			}
+
+			// The next write to the log file retries the rotation.
+			_, err = lw.Write([]byte("line 2\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Flush())
+			ensureHealthy(t, lw, true)
+
+			ensureError(t, lw.Close())
+
+			archived := archivedLogs(t, directory, "health")
+			if got, want := len(archived), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureBuffer(t, readFile(t, archived[0]), []byte("line 1\n"))
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 2\n"))
+		})
+	}
+
+	t.Run("rename", func(t *testing.T) {
+		directory := t.TempDir()
+
+		// A regular file where the archive directory must be created
+		// causes renaming the log file to fail.
+		blocker := filepath.Join(directory, "blocker")
+		if err := os.WriteFile(blocker, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		archiveDir := filepath.Join(blocker, "archive")
+
+		var reported []error
+		lw, err := NewLogWriter(&Config{
+			ArchiveDirFunc: func(string, int) string { return archiveDir },
+			BaseNamePrefix: "health",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			OnError:        func(err error) { reported = append(reported, err) },
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+
+		ensureError(t, lw.Rotate(), "cannot create archive directory")
+		ensureHealthy(t, lw, false)
+
+		// When the retry fails, it is reported, and the write is
+		// appended to the log file that could not be rotated.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		if got, want := len(reported), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, reported[0], "cannot retry log file rotation", "cannot create archive directory")
+		ensureHealthy(t, lw, false)
+
+		archiveDir = ""
+		_, err = lw.Write([]byte("line 3\n"))
+		ensureError(t, err)
+		ensureHealthy(t, lw, true)
+
+		ensureError(t, lw.Close())
+
+		archived := archivedLogs(t, directory, "health")
+		if got, want := len(archived), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archived[0]), []byte("line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 3\n"))
+	})
+
+	t.Run("open", func(t *testing.T) {
+		directory := t.TempDir()
+		logPath := filepath.Join(directory, "health.log")
+
+		// A directory where the replacement log file must be created
+		// causes opening it to fail.
+		var blocked bool
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "health",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			PostRotate: func(string) (string, error) {
+				if !blocked {
+					blocked = true
+					return "", os.Mkdir(logPath, 0755)
+				}
+				return "", nil
+			},
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+
+		ensureError(t, lw.Rotate(), logPath)
+		ensureHealthy(t, lw, false)
+
+		// Writes fail while the replacement cannot be opened, rather
+		// than using a closed log file.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err, logPath)
+		ensureHealthy(t, lw, false)
+
+		if err = os.Remove(logPath); err != nil {
+			t.Fatal(err)
+		}
+		_, err = lw.Write([]byte("line 3\n"))
+		ensureError(t, err)
+		ensureHealthy(t, lw, true)
+
+		ensureError(t, lw.Close())
+
+		archived := archivedLogs(t, directory, "health")
+		if got, want := len(archived), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archived[0]), []byte("line 1\n"))
+		ensureBuffer(t, readFile(t, logPath), []byte("line 3\n"))
+	})
+}
@@ -0,0 +1,83 @@
+package golw
+
+import (
+	"time"
+)
+
+// closeWhenIdle runs in its own goroutine, periodically closing the
+// open log file when it has not been written to for the configured
+// idle duration, until idleDone is closed.
+func (lw *LogWriter) closeWhenIdle() {
+	defer lw.idleWait.Done()
+
+	ticker := time.NewTicker(lw.cfg.IdleCloseAfter)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lw.idleDone:
+			return
+		case <-ticker.C:
+			// There is no caller to return an error to. When the
+			// buffer cannot be flushed, the log file remains open and
+			// the next Write or Close will encounter the error.
+			_ = lw.closeIfIdle()
+		}
+	}
+}
+
+// closeIfIdle flushes completed extents and closes the open log file
+// when it has not been written to for at least the configured idle
+// duration.
+func (lw *LogWriter) closeIfIdle() error {
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.filePointer == nil {
+		return nil // already closed, either while idle or by Close
+	}
+
+	idle := lw.now().Sub(lw.timeOfLastWrite)
+	if idle < lw.cfg.IdleCloseAfter {
+		return nil
+	}
+
+	if lw.tracing() {
+		lw.trace("closeIfIdle", "path", lw.filePath, "idle", idle)
+	}
+
+	if len(lw.buf) > 0 {
+		// A final extent that is not newline terminated remains in
+		// the buffer, to be written once it is completed.
+		if err := lw.flushCompletedExtents(); err != nil {
+			return err
+		}
+	}
+
+	// Unlike closeLog, leave the file size and line counts alone,
+	// because the same log file will be reopened by the next Write.
+	err := lw.filePointer.Close()
+	lw.filePointer = nil
+	lw.idleClosed = true
+	return err
+}
+
+// ensureLogOpen reopens the log file when it was closed while idle.
+func (lw *LogWriter) ensureLogOpen() error {
+	if !lw.idleClosed {
+		return nil
+	}
+	if lw.tracing() {
+		lw.trace("ensureLogOpen", "path", lw.filePath)
+	}
+	if err := lw.openLog(); err != nil {
+		return err
+	}
+	lw.idleClosed = false
+	if !lw.rotateRetry {
+		// The replacement for a rotated log file, which could not be
+		// opened during rotation, is now open.
+		lw.rotationRecovered()
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package golw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIdleCloseAfter(t *testing.T) {
+	directory := t.TempDir()
+	clock := newTestClock()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "idle",
+		Directory:      directory,
+		IdleCloseAfter: time.Hour,
+	})
+	ensureError(t, err)
+	setClock(lw, clock)
+
+	_, err = lw.Write([]byte("first line\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("partial "))
+	ensureError(t, err)
+
+	clock.Advance(30 * time.Minute)
+	ensureError(t, lw.closeIfIdle())
+	if lw.filePointer == nil {
+		t.Fatalf("GOT: closed log file; WANT: open log file before idle duration")
+	}
+
+	clock.Advance(31 * time.Minute)
+	ensureError(t, lw.closeIfIdle())
+	if lw.filePointer != nil {
+		t.Fatalf("GOT: open log file; WANT: closed log file after idle duration")
+	}
+
+	// Completed extents were flushed prior to closing the log file,
+	// but the final extent is not complete.
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "idle.log")), []byte("first line\n"))
+
+	_, err = lw.Write([]byte("line\n"))
+	ensureError(t, err)
+	if lw.filePointer == nil {
+		t.Fatalf("GOT: closed log file; WANT: log file reopened by Write")
+	}
+	if got, want := lw.fileSizeNow, int64(len("first line\n")); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, lw.Close())
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "idle.log")), []byte("first line\npartial line\n"))
+
+	t.Run("close while idle", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "idle-close",
+			Directory:      directory,
+			IdleCloseAfter: time.Hour,
+		})
+		ensureError(t, err)
+		setClock(lw, clock)
+
+		_, err = lw.Write([]byte("only line\n"))
+		ensureError(t, err)
+
+		clock.Advance(2 * time.Hour)
+		ensureError(t, lw.closeIfIdle())
+		if lw.filePointer != nil {
+			t.Fatalf("GOT: open log file; WANT: closed log file after idle duration")
+		}
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "idle-close.log")), []byte("only line\n"))
+	})
+}
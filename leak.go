@@ -0,0 +1,29 @@
+package golw
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+// warnOnLeak arranges for a warning when lw is garbage collected
+// without being closed while its buffer holds data.
+func (lw *LogWriter) warnOnLeak() {
+	runtime.SetFinalizer(lw, (*LogWriter).warnLeaked)
+}
+
+// warnLeaked is the finalizer of a LogWriter created with WarnOnLeak.
+// It reports the buffered data that will never be written to OnError,
+// or to standard error when OnError is nil, because a finalizer has no
+// caller to which it could return an error.
+func (lw *LogWriter) warnLeaked() {
+	if len(lw.buf) == 0 {
+		return
+	}
+	err := fmt.Errorf("cannot write %d buffered bytes for %s because LogWriter was garbage collected without being closed", len(lw.buf), lw.filePath)
+	if lw.cfg.OnError != nil {
+		reportError(lw.cfg.OnError, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "golw: %s\n", err)
+}
@@ -0,0 +1,58 @@
+package golw
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestWarnOnLeak(t *testing.T) {
+	directory := t.TempDir()
+
+	warnings := make(chan error, 1)
+
+	func() {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "leak",
+			Directory:      directory,
+			OnError:        func(err error) { warnings <- err },
+			WarnOnLeak:     true,
+		})
+		ensureError(t, err)
+		_, err = lw.Write([]byte("never written\n"))
+		ensureError(t, err)
+		// Drop the reference to lw without closing it.
+	}()
+
+	// Finalizers are best effort, so give up rather than fail when the
+	// garbage collector does not run it promptly.
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case err := <-warnings:
+			ensureError(t, err, "14 buffered bytes", "without being closed")
+			return
+		case <-deadline:
+			t.Skip("finalizer did not run")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestWarnOnLeakAfterClose(t *testing.T) {
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "leak",
+		Directory:      t.TempDir(),
+		OnError:        func(err error) { t.Errorf("GOT: %v; WANT: no warning", err) },
+		WarnOnLeak:     true,
+	})
+	ensureError(t, err)
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	// Closing the LogWriter flushed its buffer and removed its
+	// finalizer, so invoking it directly reports nothing either.
+	lw.warnLeaked()
+}
@@ -0,0 +1,22 @@
+package golw
+
+// flushAfterLinger runs in its own goroutine when the linger timer
+// expires, flushing completed writes that have been held in the buffer
+// for the configured linger duration.
+func (lw *LogWriter) flushAfterLinger() {
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.lingerTimer == nil {
+		return // LogWriter closed while timer expired
+	}
+
+	if lw.tracing() {
+		lw.trace("flushAfterLinger", "buffered", len(lw.buf))
+	}
+
+	// There is no caller to return an error to. When the buffer cannot
+	// be flushed, the data remains in the buffer and the next Write or
+	// Close will encounter the error.
+	_ = lw.flush()
+}
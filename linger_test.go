@@ -0,0 +1,84 @@
+package golw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLingerDuration(t *testing.T) {
+	t.Run("coalesces writes until timer expires", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "linger",
+			BufferSizeMax:  1024,
+			Directory:      directory,
+			LingerDuration: 10 * time.Millisecond,
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for lw.Stats().BufferedBytes > 0 {
+			if time.Now().After(deadline) {
+				t.Fatalf("GOT: %v buffered bytes; WANT: buffer flushed after linger", lw.Stats().BufferedBytes)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "linger.log")), []byte("line 1\nline 2\nline 3\n"))
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("flushes when buffer full", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			AllowTinyBuffer: true,
+			BaseNamePrefix:  "linger-full",
+			BufferSizeMax:   14,
+			Directory:       directory,
+			LingerDuration:  time.Hour,
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "linger-full.log")), nil)
+
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "linger-full.log")), []byte("line 1\nline 2\n"))
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("close flushes and stops timer", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "linger-close",
+			BufferSizeMax:  1024,
+			Directory:      directory,
+			LingerDuration: time.Hour,
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "linger-close.log")), []byte("line 1\n"))
+
+		if lw.lingerTimer != nil {
+			t.Errorf("GOT: %v; WANT: linger timer released", lw.lingerTimer)
+		}
+
+		// Should the timer expire after Close, it does nothing.
+		lw.flushAfterLinger()
+	})
+}
@@ -0,0 +1,21 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package golw
+
+import (
+	"errors"
+	"os"
+)
+
+const lockSupported = false
+
+var errLockNotSupported = errors.New("file locking not supported on this operating system")
+
+func lockFile(_ *os.File) error {
+	return errLockNotSupported
+}
+
+func unlockFile(_ *os.File) error {
+	return errLockNotSupported
+}
@@ -0,0 +1,26 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package golw
+
+import (
+	"os"
+	"syscall"
+)
+
+const lockSupported = true
+
+// lockFile blocks until it acquires an exclusive advisory lock on fp.
+func lockFile(fp *os.File) error {
+	for {
+		err := syscall.Flock(int(fp.Fd()), syscall.LOCK_EX)
+		if err != syscall.EINTR {
+			return err
+		}
+	}
+}
+
+// unlockFile releases the advisory lock on fp.
+func unlockFile(fp *os.File) error {
+	return syscall.Flock(int(fp.Fd()), syscall.LOCK_UN)
+}
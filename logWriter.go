@@ -1,12 +1,20 @@
 package golw
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // NOTE: This library tracks how many bytes it writes to the open log
@@ -18,24 +26,99 @@ const (
 	// similar to time.RFC3339Nano, but with colons changed to
 	// hyphens, and only three digits of precision for fractional
 	// seconds. This is convenience constant for setting TimeFormat
-	// to.
+	// to, and is a safe choice on every platform, unlike formats such
+	// as time.RFC3339, whose colons are invalid in Windows file names.
 	DateTime = "2006-01-02T15-04-05.000Z0700"
 
-	defaultBufferSizeMax = 128
-	defaultMaxBytes      = 100 * (1 << 20) // 100 MiB
-	defaultFileMode      = 0644
+	// DefaultBufferSize is the BufferSizeMax used when it is zero.
+	DefaultBufferSize = 128
+
+	// DefaultMaxBytes is the MaxBytes used when it is zero, 100 MiB.
+	DefaultMaxBytes = 100 * (1 << 20)
+
+	// DefaultFileMode is the FileMode used when it is zero.
+	DefaultFileMode fs.FileMode = 0644
+
+	// DefaultMaxNameBytes is the MaxNameBytes used when it is zero,
+	// which is the limit of many file systems.
+	DefaultMaxNameBytes = 255
+
+	minBufferSizeMax     = 16      // minimum buffer size unless AllowTinyBuffer
+	maxMaxBytes          = 1 << 60 // 1 EiB
+	defaultNameSeparator = "."
 )
 
 // Megabytes returns the number of bytes in the specified amount of
-// megabytes.
+// megabytes, where, for compatibility, a megabyte is the binary unit of
+// 1<<20 bytes, also known as a mebibyte. It is identical to Mebibytes.
+// Use MegabytesSI for the decimal unit of 1,000,000 bytes.
 func Megabytes(megabytes int) int64 { return int64(megabytes) * (1 << 20) }
 
+// Mebibytes returns the number of bytes in the specified amount of
+// mebibytes, where a mebibyte is 1<<20 bytes.
+func Mebibytes(mebibytes int) int64 { return int64(mebibytes) * (1 << 20) }
+
+// Gibibytes returns the number of bytes in the specified amount of
+// gibibytes, where a gibibyte is 1<<30 bytes.
+func Gibibytes(gibibytes int) int64 { return int64(gibibytes) * (1 << 30) }
+
+// MegabytesSI returns the number of bytes in the specified amount of
+// decimal megabytes, where a megabyte is 1,000,000 bytes.
+func MegabytesSI(megabytes int) int64 { return int64(megabytes) * 1000 * 1000 }
+
+// GigabytesSI returns the number of bytes in the specified amount of
+// decimal gigabytes, where a gigabyte is 1,000,000,000 bytes.
+func GigabytesSI(gigabytes int) int64 { return int64(gigabytes) * 1000 * 1000 * 1000 }
+
 // Config provides fields to customize behavior of a LogWriter.
 type Config struct {
+	// AllowTinyBuffer optionally allows BufferSizeMax to be less than
+	// 16 bytes. Such a small buffer causes nearly every Write to flush
+	// the buffer, so the cost of buffering dwarfs the data it holds,
+	// and NewLogWriter rejects it as a likely mistake unless this value
+	// is true.
+	AllowTinyBuffer bool
+
+	// ArchiveDirFunc is an optional callback function that returns the
+	// directory to which each log file is moved when it is rotated,
+	// allowing rotated log files to be spread across directories, such
+	// as across several disks. It is invoked with the timestamp and
+	// the sequence number of the rotation, which starts at one, and a
+	// relative directory it returns is relative to Directory. The
+	// directory is created when it does not exist, unless
+	// RequireExisting is true. When the directory is on a different
+	// file system than Directory, the log file is copied then removed,
+	// preserving its modification time. When this value is nil, or it
+	// returns the empty string, rotated log files remain in Directory.
+	ArchiveDirFunc func(stamp string, seq int) string
+
+	// ArchiveWriter is an optional function invoked with the base name
+	// of each rotated log file, after it has been renamed and after
+	// PostRotate, which returns a writer, such as a network connection,
+	// to which the LogWriter copies the contents of the rotated log
+	// file, then closes the writer and removes the rotated log file,
+	// so that no archives are kept on local disk. When this function
+	// returns an error, or copying to or closing the writer fails, the
+	// error is reported to OnError, and the rotated log file is kept,
+	// just as when this value is nil, although the writer might have
+	// received part of its contents. A rotated log file streamed to
+	// its writer has no archive path: the RotationEvent delivered to
+	// subscribers has an empty ArchivePath, no sidecar metadata file
+	// is written, and OnArchiveComplete is not invoked. It is invoked
+	// while the LogWriter holds its lock, so it must not invoke methods
+	// of the LogWriter, and writes wait for the copy to complete.
+	ArchiveWriter func(name string) (io.WriteCloser, error)
+
 	// BaseNamePrefix is an optional prefix of the base name to use
 	// when creating new output files inside the directory specified
 	// by Directory. When this value is the empty string, the
-	// LogWriter will use base name of os.Args[0].
+	// LogWriter will use base name of os.Args[0], normalized to be
+	// safe for use in a file name: on Windows a trailing ".exe"
+	// extension is removed, and every character other than a letter,
+	// a digit, a period, a hyphen, or an underscore is replaced with
+	// an underscore. A prefix provided by the caller is used as is,
+	// except that it may neither contain a path separator, nor be "."
+	// or "..", so the LogWriter never creates files outside Directory.
 	BaseNamePrefix string
 
 	// BufferSizeMax is an optional size of a buffer to use between
@@ -45,37 +128,726 @@ type Config struct {
 	// will use a buffer with a default size of 128 bytes. When this
 	// value is greater than zero, the LogWriter will use a byte
 	// buffer of this size to reduce the number of writes to the file
-	// system.
+	// system. Values less than 16 are rejected unless AllowTinyBuffer
+	// is true.
 	//
 	// small value <-------------------------------------> large value
 	// (more interactive)                           (less interactive)
 	// (less efficient)                               (more efficient)
 	BufferSizeMax int
 
+	// ClobberPolicy is an optional policy that determines what happens
+	// when the log file is rotated to a name already used by an
+	// existing file, such as after the system clock is set backwards.
+	// By default, ClobberOverwrite, the existing file is replaced, just
+	// as os.Rename would do, losing its contents. ClobberError returns
+	// an error from the rotation instead, and ClobberSuffix rotates the
+	// log file to a unique name by appending a number to it.
+	ClobberPolicy ClobberPolicy
+
+	// CoalesceRotations optionally causes Rotate to do nothing when
+	// another rotation completes while it waits for the lock, such as
+	// one triggered by MaxBytes during a concurrent Write, so that
+	// requests to rotate made while a rotation is in progress do not
+	// rotate the log file a second time. When false, Rotate always
+	// rotates the log file once it acquires the lock, unless the log
+	// file is empty.
+	CoalesceRotations bool
+
+	// CompleteLineOnUnbuffer optionally causes Reconfigure, when it
+	// changes BufferSizeMax to -1 while the buffer holds a final write
+	// that is not newline terminated, to append a newline to complete
+	// it before writing it to the log file. When false, the incomplete
+	// line is written to the log file as is, and the next write
+	// continues it, unless that write causes a rotation.
+	CompleteLineOnUnbuffer bool
+
 	// Directory is an optional directory for creating new files. When
 	// this value is the empty string, the LogWriter will use the
 	// current working directory at the time the LogWriter was
 	// created.
 	Directory string
 
+	// DirFileMode is an optional map from a directory to the OS file
+	// mode to use when creating files in that directory, rather than
+	// FileMode, such as a stricter mode for log files written to a
+	// shared volume. It is consulted for the log files created in each
+	// MultiDestination directory, and for rotated log files archived in
+	// each ArchiveDirFunc directory, which are given the mode of that
+	// directory when it differs from the mode of the directory in which
+	// the log file was created. Directories are compared after cleaning
+	// both paths with filepath.Clean, but are otherwise compared as
+	// given, so a relative directory does not match its absolute
+	// equivalent. Files created in directories missing from the map use
+	// FileMode. Like FileMode, each mode may only have permission bits.
+	DirFileMode map[string]fs.FileMode
+
 	// FileMode is an optional OS file mode to use when creating new
 	// files. When this value is zero, the LogWriter will default to
 	// 0644, which on UNIX, is equivalent to rw-r--r--.
 	FileMode fs.FileMode
 
+	// ContentDefinedRotation is an EXPERIMENTAL option that causes the
+	// LogWriter to choose where to rotate log files based on their
+	// content, using a rolling hash of the bytes written, rather than
+	// strictly their size, so that MaxBytes becomes the desired
+	// average size of the log files. Because the rotation boundaries
+	// depend only on the content near them, inserting or removing data
+	// from a stream of logs only changes the boundaries close to the
+	// modification, improving deduplication ratios when the log files
+	// are deduplicated downstream. A log file is not rotated because
+	// of its content until it holds at least ContentDefinedMinBytes
+	// bytes, and is always rotated before it would exceed
+	// ContentDefinedMaxBytes bytes. Log files are still only rotated
+	// between writes, so writes are never split across log files.
+	ContentDefinedRotation bool
+
+	// ContentDefinedMinBytes is the minimum size of a log file before
+	// it may be rotated at a content defined boundary. When this value
+	// is zero, the LogWriter will use half of MaxBytes. This value is
+	// ignored unless ContentDefinedRotation is true.
+	ContentDefinedMinBytes int64
+
+	// ContentDefinedMaxBytes is the maximum size of a log file when
+	// using content defined rotation. When this value is zero, the
+	// LogWriter will use twice MaxBytes. This value is ignored unless
+	// ContentDefinedRotation is true.
+	ContentDefinedMaxBytes int64
+
+	// CoordinationLock is an optional path to a lock file that
+	// coordinates rotation among several processes that intentionally
+	// write to the same log files, such as a shared audit log. Before
+	// rotating the log file, the LogWriter acquires an exclusive lock
+	// on this file, then checks whether another process has already
+	// rotated the log file since this LogWriter opened it. When it has,
+	// the LogWriter merely opens the new log file rather than rotating
+	// it again. Each process only counts the bytes it has written, so
+	// in effect each process rotates the shared log file when its own
+	// writes would exceed MaxBytes. The lock file is created if it does
+	// not exist, and is never removed. Locking files is only supported
+	// on some Unix like operating systems. When this value is empty,
+	// rotation is not coordinated with other processes.
+	CoordinationLock string
+
+	// CountLines optionally causes the LogWriter to count the newline
+	// characters it writes to each log file, reported by Stats as
+	// FileLines for the open log file, and as RotatedLines for the most
+	// recently rotated one, so operators can audit that archived log
+	// files are complete. Lines are also counted when FileFooterFunc
+	// is not nil or WriteSidecarMeta is true, which report the count
+	// too. Counting lines costs a pass over the data written, so it is
+	// disabled by default.
+	CountLines bool
+
+	// CreateDirectory optionally causes the LogWriter to create
+	// Directory, along with any missing parent directories, when it
+	// does not exist. This applies both when the LogWriter is created,
+	// and when Directory is removed while the LogWriter is running, such
+	// as by an overzealous cleanup job, in which case the log file that
+	// was removed along with the directory cannot be rotated, and is
+	// replaced by a new log file in the recreated directory. Each time
+	// the directory is created, the LogWriter reports it to OnError.
+	// When false, the LogWriter returns an error when Directory does
+	// not exist.
+	CreateDirectory bool
+
+	// FileFooterFunc is an optional function that returns bytes to
+	// append to a log file immediately before it is rotated, such as
+	// a machine-parseable footer that downstream tools can use to
+	// validate each archived file is complete. It is invoked with the
+	// state of the log file prior to writing the footer. The footer
+	// counts toward the size of the log file, and because it is
+	// written after the LogWriter has determined the file must be
+	// rotated, it may cause the archived file to slightly exceed
+	// MaxBytes. The footer is not written when the LogWriter is
+	// closed, because the log file is not rotated then, but will be
+	// appended to by the next LogWriter to open it. When this value is
+	// nil, no footer is written.
+	FileFooterFunc func(state FileState) []byte
+
+	// FlushOnBufferFull optionally causes the LogWriter to flush
+	// completed writes to the log file as soon as a Write fills the
+	// buffer to or beyond BufferSizeMax bytes. When false, a full
+	// buffer is not flushed until a subsequent Write would overflow
+	// it, or the LogWriter is closed. This value is ignored when
+	// BufferSizeMax is -1.
+	FlushOnBufferFull bool
+
+	// FlushOversizeImmediately optionally causes the LogWriter to write
+	// a line that is not yet newline terminated to its own log file as
+	// soon as it grows larger than MaxBytes, rather than holding it in
+	// the buffer until its newline arrives, so an extremely long line
+	// does not grow the buffer without bound. Subsequent writes that
+	// continue the line are written directly to the same log file, so
+	// the line is never split across log files, and the log file is
+	// rotated once the line is complete. Rotate does nothing while such
+	// a line is incomplete. When false, a line that is not newline
+	// terminated is never written before its newline arrives, or the
+	// LogWriter is closed, no matter how large it grows. This value is
+	// ignored when BufferSizeMax is -1.
+	FlushOversizeImmediately bool
+
+	// FlushTerminatedWrites optionally causes the LogWriter to flush
+	// completed writes to the log file as soon as a Write ends with a
+	// newline, so programs whose writes are whole lines see nearly the
+	// latency of an unbuffered LogWriter, while writes that are not
+	// newline terminated are still held in the buffer until the rest of
+	// their line arrives, so they are never split across log files.
+	// This value is ignored when BufferSizeMax is -1.
+	FlushTerminatedWrites bool
+
+	// FlushThreshold is an optional number of bytes at which the
+	// LogWriter flushes completed writes to the log file as soon as a
+	// Write fills the buffer to or beyond it, limiting how much data
+	// waits in the buffer. Combined with MaxPendingExtents, the buffer
+	// is flushed when either limit is reached, whichever comes first.
+	// When this value is zero, or not less than BufferSizeMax, the
+	// buffer is flushed when it fills, as described by
+	// FlushOnBufferFull. This value is ignored when BufferSizeMax is
+	// -1.
+	FlushThreshold int
+
+	// FrameMode optionally causes the LogWriter to write the data from
+	// each Write as a frame, prefixed with its length as a 4-byte
+	// big-endian integer, so log files are a self-delimiting stream of
+	// binary records that may be read with ReadFrame without scanning
+	// for newlines. The frame header is counted toward the size of the
+	// log file, and is never separated from its payload. The payload
+	// of each frame includes the prefix from RecordPrefixFunc and the
+	// RecordSuffix. Each Write is a complete record, so whether it is
+	// newline terminated does not matter. Because they would corrupt
+	// the stream of frames, this option cannot be combined with
+	// FileFooterFunc, NewFileFunc, RepairOnOpen, or RotateOnMarker.
+	FrameMode bool
+
+	// IdleCloseAfter is an optional duration after which, when no
+	// Write has been invoked, the LogWriter flushes its completed
+	// writes and closes the open log file, releasing its file
+	// descriptor. The next Write transparently reopens the log file.
+	// This is useful for programs with many mostly idle LogWriters,
+	// at the cost of reopening the log file after each quiet period.
+	// The LogWriter checks for idleness in a background goroutine
+	// every IdleCloseAfter, so the log file is closed after it has
+	// been idle for between one and two times this duration. When
+	// this value is zero, the log file remains open until Close is
+	// invoked.
+	IdleCloseAfter time.Duration
+
+	// IncludeByteRange optionally causes the LogWriter to name each
+	// rotated log file for the range of bytes it covers in the stream
+	// of all log files written to the log directory, rather than for
+	// the time of its first write, as in "<prefix>.<start>-<end>.log".
+	// The range is half open, so the end offset of each rotated log
+	// file is the start offset of the next one, and the stream can be
+	// reassembled in order, or searched by offset, from the names alone.
+	// Offsets are zero padded to twelve digits, so the names sort in
+	// stream order. A new LogWriter continues from the largest end
+	// offset among the rotated log files in the log directory, but not
+	// those moved elsewhere by ArchiveDirFunc, which still receives the
+	// formatted time of the first write to each log file.
+	IncludeByteRange bool
+
+	// IncludeSequence optionally causes the LogWriter to include a
+	// sequence number in the name of each rotated log file, after its
+	// timestamp, as in "<prefix>.<timestamp>.<sequence>.log". The
+	// sequence number of the first log file rotated by a LogWriter is
+	// 1, and it is incremented for each subsequent rotation by that
+	// LogWriter. It is zero padded to six digits, so the names of
+	// files rotated with the same timestamp sort in the order they
+	// were rotated, guaranteeing rotated log files have unique names
+	// even when the timestamp format has low precision, but only
+	// among the files rotated by a single LogWriter, unless the
+	// sequence is continued across LogWriters by SequenceStateFile.
+	IncludeSequence bool
+
+	// LingerDuration is an optional duration for which buffered writes
+	// are held so that rapid small writes are coalesced before being
+	// written to the log file, reducing the number of system calls
+	// for bursty programs that emit many tiny writes, at the cost of a
+	// little latency. Each Write restarts the linger timer, and when
+	// it expires the LogWriter flushes its completed writes. A Write
+	// that fills the buffer to or beyond BufferSizeMax bytes flushes
+	// completed writes immediately, just like FlushOnBufferFull, so
+	// buffered data never waits for the timer once the buffer is full.
+	// When this value is zero, buffered writes are only flushed when
+	// the buffer fills. This value is ignored when BufferSizeMax is
+	// -1.
+	LingerDuration time.Duration
+
 	// MaxBytes is an optional maximum number of bytes to write to any
 	// particular log file. When a particular Write call sends a byte
 	// slice longer than this value, the LogWriter will create a new
 	// file to hold the contents of the entire byte slice, regardless
 	// of its size, then will create a new output file the next time
-	// its Write method is invoked.
+	// its Write method is invoked. When this value is zero, the
+	// LogWriter will default to 100 MiB. Values larger than 1 EiB are
+	// rejected, because they most likely result from a misconfigured
+	// unit, and leave no room for the arithmetic that derives other
+	// limits from this value.
 	MaxBytes int64
 
+	// MaxBytesBurst is an optional number of bytes that may be written
+	// in a burst faster than MaxBytesPerSecond allows. When this value
+	// is zero, the LogWriter allows a burst of one second worth of
+	// writes, MaxBytesPerSecond bytes. This value is ignored unless
+	// MaxBytesPerSecond is greater than zero.
+	MaxBytesBurst int64
+
+	// MaxBytesPerSecond is an optional limit on the sustained rate at
+	// which data may be written to the LogWriter, preventing a runaway
+	// logger from saturating disk I/O and starving other processes on
+	// a shared host. Write and WriteBatch block their caller as long as
+	// needed to keep the rate of writes under this limit, using a
+	// token bucket that holds up to MaxBytesBurst bytes, but do not
+	// hold the lock while blocked. When the LogWriter is closed, any
+	// blocked write returns an error that wraps fs.ErrClosed without
+	// writing its data. When this value is zero, writes are not
+	// throttled.
+	MaxBytesPerSecond int64
+
+	// MaxMemoryBytes is an optional limit on the number of bytes of
+	// log data the LogWriter holds in memory, counting both the bytes
+	// in its buffer, and the bytes retained for Tail. When a Write would
+	// exceed the limit, the LogWriter flushes completed writes in the
+	// buffer, and when that does not free enough memory, it writes the
+	// incomplete line in the buffer along with the Write directly to
+	// the log file, rotating it first when needed, just as described by
+	// FlushOversizeImmediately for lines larger than MaxBytes, so that
+	// memory remains bounded, even when a program writes an extremely
+	// long line in many pieces. In other words, the LogWriter applies
+	// backpressure by writing synchronously. When this value is zero,
+	// memory is only bounded by BufferSizeMax, which the buffer exceeds
+	// while it holds a line waiting for its newline. This value must be
+	// larger than TailBufferSize, and is ignored when BufferSizeMax is
+	// -1.
+	MaxMemoryBytes int64
+
+	// MaxNameBytes is an optional maximum length, in bytes, of the names
+	// of the log file and of the log files it is rotated to, so that a
+	// long BaseNamePrefix does not cause creating or rotating the log
+	// file to fail on file systems that limit the length of names. When
+	// a name would be longer, the end of BaseNamePrefix in that name is
+	// replaced by a tilde and a hash of the entire prefix, which keeps
+	// names unique, while the time stamp and sequence number remain
+	// intact, so rotated log files still sort in order, and
+	// ParseBackupName still recognizes them, given the entire prefix.
+	// The first time it shortens a name, the LogWriter reports it to
+	// OnError. The number appended by ClobberSuffix is not counted
+	// toward the limit, nor are the names of companion and temporary
+	// files, such as those of WriteSidecarMeta and PreallocateNext. When
+	// this value is zero, the LogWriter will default to 255 bytes. It
+	// cannot be changed by Reconfigure.
+	MaxNameBytes int
+
+	// MaxPendingExtents is an optional number of completed writes at
+	// which the LogWriter flushes them to the log file as soon as a
+	// Write completes that many, limiting how many records wait in the
+	// buffer regardless of their size, which is useful when tiny
+	// records would otherwise wait a long time for the buffer to fill.
+	// Combined with FlushThreshold, the buffer is flushed when either
+	// limit is reached, whichever comes first. When this value is zero,
+	// the number of completed writes in the buffer is not limited. This
+	// value is ignored when BufferSizeMax is -1.
+	MaxPendingExtents int
+
+	// MultiDestination is an optional list of directories across which
+	// the LogWriter stripes its log files, to spread the I/O of high
+	// volume logging across several disks. The first log file is
+	// created in the first directory, and each time the log file is
+	// rotated, it is archived in the directory it was created in, and
+	// its replacement is created in the next directory in the list,
+	// wrapping around after the last one, so successive log files land
+	// on different volumes. Each Write is still written entirely to a
+	// single log file. When this value is not empty, Directory is
+	// ignored, and relative ArchiveDirFunc directories are relative to
+	// the directory of the log file being archived.
+	MultiDestination []string
+
+	// Mmap is an EXPERIMENTAL option that causes the LogWriter to
+	// write to the open log file by copying data into a shared memory
+	// mapping of the file, rather than invoking a system call for
+	// each write, which may improve throughput for programs with
+	// extremely high write rates. The log file is extended in
+	// increments of up to 1 MiB as needed, and truncated to the number
+	// of bytes written when it is closed or rotated. Consequently,
+	// while the log file is open, other processes reading it will
+	// observe trailing zero bytes, and if the program crashes, those
+	// zero bytes will remain in the log file. Durability is the same
+	// as when not using this option: the operating system writes
+	// modified pages to storage on its own schedule. On Linux, storage
+	// for each extension is allocated when the log file is extended, so
+	// a full file system causes an error to be returned. On other
+	// platforms, the extension is sparse, and running out of space
+	// while copying data into the mapping raises SIGBUS, which crashes
+	// the program. This option is only supported on UNIX platforms, and
+	// does not support other processes concurrently appending to the
+	// same log file.
+	Mmap bool
+
+	// MeasureLockContention optionally causes the LogWriter to measure
+	// how long each of its methods holds its internal lock, reporting
+	// the total and maximum durations in Stats, which helps diagnose
+	// whether the LogWriter is a point of contention for programs
+	// writing to it from many goroutines. Measuring requires reading
+	// the clock twice per method call, so it is disabled by default.
+	MeasureLockContention bool
+
+	// NameSeparator is an optional string that separates BaseNamePrefix
+	// from the time stamp in the names of rotated log files, so they
+	// may be named, for instance, app-<stamp>.log or app_<stamp>.log.
+	// The open log file is always named with BaseNamePrefix followed by
+	// the .log extension. When this value is the empty string, the
+	// LogWriter will default to a period. It may not contain characters
+	// that are invalid in file names, including path separators.
+	NameSeparator string
+
+	// NameByWriteTime optionally causes a log file to which buffered
+	// writes are flushed to be named with the time of the Write that
+	// buffered the first of them, rather than with the time they were
+	// flushed to it. Without it, when a flush spills buffered writes
+	// into the replacement of a log file that was just rotated, the
+	// replacement is named with the time of that flush, which may well
+	// be later than when its first line was written. This option has
+	// no effect when BufferSizeMax is -1, because each Write is then
+	// written to the log file as it is made.
+	NameByWriteTime bool
+
+	// NewFileFunc is an optional function that returns bytes to write
+	// at the start of each log file opened to replace a rotated log
+	// file, such as a preamble computed from runtime state, or a line
+	// referencing the previous log file so downstream tools can follow
+	// a chain of log files. It is invoked with the final path of the
+	// rotated log file, after PostRotate has moved it. The preamble
+	// counts toward the size of the new log file. Unlike FileFooterFunc,
+	// which ends each rotated log file, it is not invoked for the log
+	// file opened by NewLogWriter, because there is no previous log
+	// file. It is invoked while the LogWriter holds its lock, so it must
+	// not invoke methods of the LogWriter. When this value is nil, new
+	// log files begin empty.
+	NewFileFunc func(prevArchivePath string) []byte
+
+	// NoFollowSymlinks optionally causes the LogWriter to refuse to
+	// open the log file when its path is a symbolic link, rather than
+	// following the link and appending to whatever file it references,
+	// which hardens programs that write logs to shared directories
+	// against symbolic link attacks. Where supported, the log file is
+	// opened with O_NOFOLLOW, so the check cannot race with the open;
+	// on other platforms the path is checked with os.Lstat immediately
+	// before it is opened. When this value is false, symbolic links
+	// are followed, as they always have been.
+	NoFollowSymlinks bool
+
+	// NoRotate optionally causes the LogWriter to never rotate the log
+	// file, regardless of its size, so it is simply a buffered appender
+	// that never splits a line, for programs whose log file is rotated
+	// by external tools such as logrotate. See Reopen for switching to
+	// a new log file after external tools rename it. MaxBytes is
+	// ignored, Rotate returns an error, and the functions invoked when
+	// rotating, such as FileFooterFunc and PostRotate, are never
+	// invoked. Because they would rotate the log file, or only apply
+	// when it is rotated, this option cannot be combined with
+	// ContentDefinedRotation, Mmap, MultiDestination, PreallocateNext,
+	// RotateOnMarker, or RotateOnSessionChange.
+	NoRotate bool
+
+	// OSBuffered optionally causes the LogWriter to wrap the open log
+	// file in a bufio.Writer of OSBufferSize bytes when BufferSizeMax
+	// is -1, so many small writes are batched into few system calls,
+	// without the line oriented semantics of the in-memory buffer. In
+	// other words, a write may be split across two system calls, and
+	// a partially written line may reach the log file before the rest
+	// of it, but never across two log files, because the bufio.Writer
+	// is flushed before the log file is rotated or closed, as well as
+	// by Flush. Data remains in the bufio.Writer until it fills, so
+	// programs should invoke Flush periodically or use IdleCloseAfter
+	// when other processes read the log file while it is written.
+	// This option cannot be combined with an in-memory buffer or Mmap.
+	OSBuffered bool
+
+	// OSBufferSize is the size of the bufio.Writer used when OSBuffered
+	// is true. When this value is zero, the LogWriter will default to
+	// 4096 bytes. This value is ignored unless OSBuffered is true.
+	OSBufferSize int
+
+	// OnArchiveComplete is an optional function invoked with the final
+	// path of each rotated log file once it reaches its terminal
+	// archived state, and will no longer be modified by the LogWriter,
+	// which is after it has been moved to its archive directory, and
+	// after its sidecar metadata file has been written when
+	// WriteSidecarMeta is true. This differs from the RotationEvent
+	// delivered to subscribers, which describes the rotation itself,
+	// and gives downstream tools a precise signal that the file is
+	// immutable. Like subscribers, it is invoked after the LogWriter
+	// releases its lock, so it may invoke methods of the LogWriter.
+	// When this value is nil, no function is invoked.
+	OnArchiveComplete func(finalPath string)
+
+	// OnError is an optional function invoked with errors the LogWriter
+	// recovered from without returning them to the caller, such as
+	// when it recreates a Directory that was removed. This includes
+	// panics raised by the other functions provided to the LogWriter,
+	// such as subscribers, FileFooterFunc, NewFileFunc, PostRotate,
+	// and RecordPrefixFunc, which the LogWriter recovers from rather
+	// than letting a buggy callback crash the program, then continues
+	// as though the function had returned its zero values, except that
+	// a panicking TimeFormatter is replaced by the default format. It
+	// may be invoked while the LogWriter holds its lock, so it must not
+	// invoke methods of the LogWriter. When this value is nil,
+	// recovered errors are not reported.
+	OnError func(err error)
+
+	// PostRotate is an optional function invoked with the path of each
+	// rotated log file after it has been renamed, which may move or
+	// rename the file into a naming scheme managed elsewhere, and
+	// returns the new path of the file. The LogWriter then uses the
+	// new path as the location of the archived log file, such as in
+	// the RotationEvent delivered to subscribers, for the sidecar
+	// metadata file, and for OnArchiveComplete. Returning the path it
+	// was invoked with, or the empty string, leaves the file where it
+	// is. When it returns an error, the replacement log file is still
+	// opened, and the error is returned by the method that caused the
+	// rotation. It is invoked while the LogWriter holds its lock, so it
+	// must not invoke methods of the LogWriter. When this value is nil,
+	// rotated log files remain where they were renamed.
+	PostRotate func(path string) (newPath string, err error)
+
+	// PreallocateNext optionally causes the LogWriter to create the
+	// replacement for the log file ahead of time, in the background,
+	// so rotating the log file only renames the files rather than also
+	// creating a file, reducing the latency of the Write that causes
+	// the rotation. The prepared file is hidden in Directory, named
+	// with a period, BaseNamePrefix, and a .next extension, and is
+	// removed by Close. When the prepared file is not ready, or no
+	// longer matches the configuration, the log file is created during
+	// rotation as usual. Renaming a file while it is open is not
+	// supported on all operating systems, in which case the prepared
+	// file is never used. Because every process writing the same log
+	// file would prepare the same file, this option cannot be combined
+	// with SharedAppend.
+	PreallocateNext bool
+
+	// RecordPrefixFunc is an optional function that returns bytes to
+	// prepend to the data from each Write, such as a timestamp or
+	// sequence number, so every record written to the log files has a
+	// consistent envelope. The prefix is counted toward the size of
+	// the log file, and is never separated from the data it precedes.
+	// Unlike FileFooterFunc, which is invoked once per log file, this
+	// is invoked once per Write. RotateOnMarker is compared against
+	// the data with its prefix. When this value is nil, no prefix is
+	// written.
+	RecordPrefixFunc func() []byte
+
+	// RecordSuffix is an optional sequence of bytes to append to the
+	// data from each Write, such as a record separator. Like the
+	// prefix from RecordPrefixFunc, it is counted toward the size of
+	// the log file, and is written along with the data it follows.
+	// When this value is empty, no suffix is written.
+	RecordSuffix []byte
+
+	// RecoverOnFlushError optionally causes the LogWriter, when it
+	// fails to flush its buffer to the log file, to append the
+	// completed writes remaining in the buffer to a recovery file in
+	// the log directory, named with BaseNamePrefix and a .recovery
+	// extension, and discard them from the buffer, so they are not lost
+	// when the program exits before a later flush succeeds. This is a
+	// last resort safety net: the recovered writes never reach the log
+	// files, so operators must inspect the recovery file, and the
+	// recovery file is likely to be unwritable as well when the log
+	// directory is the cause of the failure. Each recovery is reported
+	// to OnError, and the flush still returns its error. A final write
+	// that is not newline terminated remains in the buffer. When false,
+	// writes the LogWriter fails to flush remain in the buffer, and a
+	// subsequent flush tries to write them again.
+	RecoverOnFlushError bool
+
+	// RemoveOnClose optionally causes Close to remove the log file,
+	// along with every log file this LogWriter rotated, and their
+	// sidecar metadata files, turning the LogWriter into a scratch
+	// logger for short lived programs whose logs are only useful while
+	// they run. THIS DELIBERATELY DISCARDS ALL LOGS WRITTEN BY THE
+	// LOGWRITER. As a safeguard, rotated log files are only removed
+	// when their names begin with BaseNamePrefix and end with the .log
+	// extension, so a file moved elsewhere and renamed by PostRotate is
+	// left alone. Log files rotated by other processes or previous runs
+	// of the program are not removed.
+	RemoveOnClose bool
+
+	// RemoveEmptyOnClose optionally causes Close to remove the log
+	// file when it is empty, rather than leaving a zero byte file
+	// behind, which keeps the directory clean for short lived
+	// programs that do not write any logs.
+	RemoveEmptyOnClose bool
+
+	// RepairOnOpen optionally causes NewLogWriter to repair an existing
+	// log file that does not end with a newline, such as one left by a
+	// program that crashed in the middle of writing a line, so the
+	// first write does not append to a corrupt partial line. By
+	// default the partial line is completed by appending a newline to
+	// the log file, which preserves all of its data. When
+	// RepairTruncate is true the partial line is instead removed from
+	// the log file, which discards its data but guarantees every line
+	// in the log file is exactly as it was written. Only the log file
+	// opened by NewLogWriter is repaired.
+	RepairOnOpen bool
+
+	// RepairTruncate optionally causes RepairOnOpen to remove a partial
+	// final line from an existing log file rather than completing it.
+	// This value is ignored unless RepairOnOpen is true.
+	RepairTruncate bool
+
+	// ReplaceInvalidUTF8 optionally causes ValidateUTF8 to replace each
+	// sequence of bytes that are not valid UTF-8 with the Unicode
+	// replacement character, U+FFFD, rather than rejecting the write.
+	// This value is ignored unless ValidateUTF8 is true.
+	ReplaceInvalidUTF8 bool
+
+	// RequireExisting optionally causes NewLogWriter to open the log
+	// file without creating it, returning an error when it does not
+	// exist, for programs whose log directory and log file are
+	// provisioned externally and must not be created by the program.
+	// Once the provisioned log file is rotated, its replacement is
+	// created in the same directory. Directories returned by
+	// ArchiveDirFunc are not created either, so they must also be
+	// provisioned.
+	RequireExisting bool
+
+	// RotateOnMarker is an optional sequence of bytes that causes the
+	// LogWriter to rotate the log file when the data from a Write
+	// begins with it, so the application can choose where one log
+	// file ends and the next begins, for instance, by writing a line
+	// such as "=== RESTART ===". All data from prior Writes is written
+	// to the previous log file, and the data beginning with the marker
+	// is the first data written to the new log file. The LogWriter
+	// does not rotate an empty log file. Because the marker is only
+	// compared against the beginning of the data from each Write,
+	// this is only meaningful when each Write is a single line. When
+	// this value is empty, the log file is only rotated based on its
+	// size.
+	RotateOnMarker []byte
+
+	// RotateOnSessionChange optionally causes the LogWriter to rotate
+	// the log file when WriteSession is invoked with a session ID that
+	// differs from the one it was most recently invoked with, so each
+	// log file holds the writes of a single session, such as a request
+	// or a batch job. Data from a session that does not fit in one log
+	// file is still rotated based on its size. Writes made with Write
+	// rather than WriteSession do not change the session. The LogWriter
+	// does not rotate an empty log file, and a final write of the
+	// previous session that is not newline terminated is written to
+	// the log file of the new session once its line is completed.
+	RotateOnSessionChange bool
+
+	// SanitizeTimestamp optionally causes the LogWriter to replace
+	// each character that is invalid in file names on the host
+	// platform with a hyphen when formatting timestamps for rotated
+	// log file names. On Windows, the invalid characters include
+	// colons, which appear in formats such as time.RFC3339. When
+	// false, NewLogWriter formats the current time and returns an
+	// error when the result includes any invalid characters. See
+	// DateTime for a format that is valid on every platform.
+	SanitizeTimestamp bool
+
+	// SequenceStateFile is an optional path to a file in which the
+	// LogWriter persists the most recent rotation sequence number, so
+	// that a new LogWriter continues the sequence from where the
+	// previous one stopped, rather than starting over at 1, which keeps
+	// sequence numbers monotonic across restarts, even when rotated log
+	// files are removed from the log directory, or archived elsewhere.
+	// The file is read by NewLogWriter, and replaced by renaming a
+	// temporary file over it each time the log file is rotated, before
+	// the sequence number is used, so a rotation fails when the file
+	// cannot be written. See IncludeSequence. When this value is empty,
+	// each LogWriter begins its sequence at 1.
+	SequenceStateFile string
+
+	// SharedAppend optionally causes the LogWriter to tolerate other
+	// processes appending to the same log file, by checking the size
+	// of the file at the log file path before each decision whether to
+	// rotate it, rather than only counting the bytes it has written
+	// itself, at the cost of an additional stat system call for each
+	// write. When another process has replaced the log file, the
+	// LogWriter switches to its replacement. Because the file is opened
+	// with O_APPEND, each write is appended atomically as long as it is
+	// not larger than the pipe buffer on Unix like operating systems,
+	// so buffering must be disabled by setting BufferSizeMax to -1, and
+	// this option cannot be combined with ContentDefinedRotation, Mmap,
+	// OSBuffered, or PreallocateNext, whose prepared file every process
+	// would share. Combine it with CoordinationLock so that processes
+	// which decide to rotate the log file at the same time do not both
+	// rotate it.
+	SharedAppend bool
+
+	// SplitOversizeBackups optionally causes EnforceSizePolicy to split
+	// each rotated log file larger than MaxBytes into several that are
+	// not, without splitting any line. When false, EnforceSizePolicy
+	// only reports them.
+	SplitOversizeBackups bool
+
+	// StageActive optionally causes the LogWriter to write the open log
+	// file under a hidden staging name, a period followed by
+	// BaseNamePrefix and .log.partial, rather than BaseNamePrefix and
+	// .log, so that it never matches the names of rotated log files,
+	// nor the name downstream processors watch for. When the log file
+	// is rotated, it is renamed to its rotated log file name in a
+	// single rename, so watchers only observe complete log files. A log
+	// file left under the staging name when the program exits is
+	// appended to by the next LogWriter to open it. Because the open
+	// log file is never published under its usual name, this option
+	// cannot be combined with NoRotate.
+	StageActive bool
+
+	// SuppressRotationDuring is an optional function that returns true
+	// while the log file must not be rotated because it is full, such
+	// as during a nightly backup that scans the log directory. While it
+	// returns true, writes continue to be appended to the log file,
+	// which may grow beyond MaxBytes, and once it returns false, the
+	// next write or flush rotates the log file when it is full. It does
+	// not suppress rotations requested by Rotate, RotateOnMarker, or
+	// RotateOnSessionChange. It is invoked with the current time, as
+	// reported by the clock of the LogWriter, before each write and
+	// flush, while the LogWriter holds its lock, so it must be quick,
+	// and must not invoke methods of the LogWriter. When it panics, the
+	// panic is reported to OnError, and rotation is not suppressed.
+	// This option cannot be combined with Mmap, which sizes each
+	// mapping to hold exactly MaxBytes.
+	SuppressRotationDuring func(now time.Time) bool
+
+	// TailBufferSize is an optional number of bytes most recently
+	// written to the LogWriter to retain in memory, regardless of
+	// which log files they were written to, so that a program can
+	// show recent logs, such as from a debugging endpoint, without
+	// reading the log files. See Tail. The retained bytes include any
+	// record prefix and suffix, and any data still held in the
+	// in-memory buffer. When this value is zero, no bytes are
+	// retained.
+	TailBufferSize int
+
+	// TamperEvident optionally causes the LogWriter to chain the lines
+	// it writes together, so that modifying, inserting, removing, or
+	// reordering lines can be detected by VerifyChain. Before the
+	// newline of each line, the LogWriter appends a trailer made of a
+	// space, "chain=", the link of the previous line, a colon, and the
+	// link of the line, where a link is the hexadecimal truncated
+	// SHA-256 hash of the link of the previous line followed by the
+	// content of the line, including any record prefix and suffix. The
+	// first line links to a zero link, and the chain continues across
+	// rotations, and from the last line of an existing log file when
+	// the LogWriter is created. Trailers add 72 bytes to each line, and
+	// are counted toward the size of the log file. A line split across
+	// log files, such as by FlushOversizeImmediately, cannot be
+	// verified. TamperEvident cannot be used with FileFooterFunc,
+	// FrameMode, NewFileFunc, or SharedAppend, and cannot be changed by
+	// Reconfigure.
+	TamperEvident bool
+
 	// TimeFormatter is an optional function that will format a given
 	// time.Time value to a string in the desired time format for the
 	// purpose of creating filenames with a timestamp. When this value
 	// is the empty string, the value of TimeFormat is checked, and if
-	// itself not empty, used to format the time.
+	// itself not empty, used to format the time. When this function
+	// panics, or returns the empty string, the problem is reported to
+	// OnError, and the time is formatted using UnixNano instead, as
+	// described by TimeFormat.
 	TimeFormatter func(time.Time) string
 
 	// TimeFormat is an optional format to pass to time.Time's Format
@@ -83,8 +855,134 @@ type Config struct {
 	// empty. This value is ignored when TimeFormatter is not
 	// nil. When TimeFormatter is nil and TimeFormat is the empty
 	// string, the LogWriter uses UnixNano to format the time string
-	// used to name rotated log files.
+	// used to name rotated log files. See SanitizeTimestamp for how
+	// formats that produce characters invalid in file names are
+	// handled.
 	TimeFormat string
+
+	// TraceFunc is an optional function invoked with a structured
+	// trace event for each significant operation of the LogWriter,
+	// such as opening, rotating, renaming, and closing log files, for
+	// diagnosing its behavior in production without rebuilding the
+	// program with the golw_debug build tag. Each event has the name of
+	// its operation, and fields as alternating keys and values, such as
+	// "path" and the path of a log file. The events and their fields
+	// are not part of the compatibility promise of this package, and
+	// may change between releases. It is invoked while the LogWriter
+	// holds its lock, so it must not invoke methods of the LogWriter.
+	// When this value is nil, no trace events are produced, and they
+	// cost nothing.
+	TraceFunc func(op string, kv ...interface{})
+
+	// ValidateUTF8 optionally causes the LogWriter to verify the data of
+	// each Write is valid UTF-8, catching binary data accidentally sent
+	// to a text log. By default, a Write with invalid data returns an
+	// error without writing any of its data, but when
+	// ReplaceInvalidUTF8 is true, the invalid bytes are replaced and the
+	// Write succeeds. Each Write is validated on its own, so a Write
+	// that ends in the middle of a multibyte character is invalid, even
+	// when the next Write would complete it. Validating writes costs a
+	// pass over the data, so it is disabled by default.
+	ValidateUTF8 bool
+
+	// VerifyOnStart optionally causes NewLogWriter to decompress each
+	// compressed rotated log file in the log directory, those that
+	// ParseBackupName reports as Compressed, and to rename any that
+	// cannot be decompressed in full, such as one truncated by a crash
+	// while it was being compressed by PostRotate, by appending
+	// ".corrupt" to its name, reporting each to OnError, so that
+	// consumers of FS and ParseBackupName are not handed a corrupt file.
+	// Verifying reads every compressed rotated log file, which may delay
+	// creating the LogWriter when there are many of them.
+	VerifyOnStart bool
+
+	// WarnOnLeak optionally causes the LogWriter to report when it is
+	// garbage collected without having been closed while its buffer
+	// still holds data, which is then lost, a common programming error
+	// in long running services. The warning is reported to OnError, or
+	// written to standard error when OnError is nil. Detection relies on
+	// a finalizer, so it is best effort: finalizers might run long
+	// after the LogWriter becomes unreachable, or not at all before the
+	// program exits, and a LogWriter with IdleCloseAfter or
+	// LingerDuration remains reachable from its background goroutine or
+	// timer, so it is never garbage collected. This value is ignored
+	// when BufferSizeMax is -1.
+	WarnOnLeak bool
+
+	// WriteTimeout is an optional duration limiting how long each
+	// write to the log file may block. When a write does not complete
+	// in time, the LogWriter returns an error for which
+	// errors.Is(err, os.ErrDeadlineExceeded) is true, rather than
+	// blocking the caller indefinitely. Regular files on most
+	// operating systems do not support write deadlines, in which case
+	// this value is ignored, so it is primarily useful when the log
+	// file path refers to a named pipe, or some other file that
+	// supports deadlines. When this value is zero, writes may block
+	// indefinitely.
+	WriteTimeout time.Duration
+
+	// WriteSidecarMeta optionally causes the LogWriter to write a
+	// companion JSON file next to each rotated log file, named like the
+	// rotated log file with an additional .meta.json extension, which
+	// describes the rotated log file with a SidecarMeta, so each
+	// rotated log file is self-describing for downstream ingestion.
+	WriteSidecarMeta bool
+
+	// WriteSyncFsync optionally causes WriteSync to commit the log file
+	// to stable storage after writing to it, so data written with
+	// WriteSync survives a crash of the operating system, at the cost
+	// of waiting for the storage device.
+	WriteSyncFsync bool
+}
+
+// FileState describes a log file written to by a LogWriter.
+type FileState struct {
+	// Path is the path of the log file.
+	Path string
+
+	// Bytes is the size of the log file in bytes, including any
+	// contents the file had before the LogWriter opened it.
+	Bytes int64
+
+	// Lines is the number of newline characters the LogWriter has
+	// written to the log file since it opened the file. It does not
+	// include any lines the file had before the LogWriter opened it.
+	Lines int64
+}
+
+// programBaseNamePrefix returns a base name prefix derived from the
+// program name, with characters that are unsafe or awkward in file
+// names replaced, and on Windows, with the executable extension
+// removed.
+func programBaseNamePrefix(programName, goos string) string {
+	if goos == "windows" {
+		// Windows accepts both forward and back slashes as path
+		// separators, regardless of which one the host uses.
+		if i := strings.LastIndexAny(programName, `/\`); i >= 0 {
+			programName = programName[i+1:]
+		}
+		if ext := filepath.Ext(programName); strings.EqualFold(ext, ".exe") {
+			programName = programName[:len(programName)-len(ext)]
+		}
+	} else {
+		programName = filepath.Base(programName)
+	}
+
+	prefix := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, programName)
+
+	if strings.Trim(prefix, ".") == "" {
+		// Names composed entirely of periods, such as the "." that
+		// filepath.Base returns for an empty path, would create
+		// hidden or confusing file names.
+		return "golw"
+	}
+
+	return prefix
 }
 
 func makeDateTimeFormatter(format string) func(time.Time) string {
@@ -100,7 +998,8 @@ func nanoDateTimeFormatter(t time.Time) string {
 // LogWriter is a io.WriteCloser that can act as the recipient of many
 // logging libraries, and is designed to rotate log files at a
 // specified size, and optionally buffer writes to reduce file system
-// calls.
+// calls. A LogWriter is safe for concurrent use by multiple
+// goroutines.
 //
 // NOTE: When LogWriter opens a previously created log file, it does
 // not inspect its contents to determine the time of its first
@@ -112,54 +1011,392 @@ type LogWriter struct {
 	buf     []byte // buf stores all data to be written to file
 	extents []int  // extents stores length of each newline terminated write
 
-	// TODO: need to track write time for each extent, other wise new
-	// files will be renamed with names of previously rotated files.
+	// writeTimes stores the time of the Write that started each extent,
+	// so that with NameByWriteTime, a log file is named with the time
+	// of the first extent flushed to it.
 	writeTimes []time.Time
 
-	timeOfFirstWrite  string
+	timeOfLastWrite   time.Time
+	fileFirstWrite    time.Time // fileFirstWrite is time data first written to open log file
+	fileLastWrite     time.Time // fileLastWrite is time data last written to open log file
 	filePath          string
+	destination       int // destination is index of MultiDestination directory of open log file
 	fileSizeNow       int64
-	filePointer       *os.File
+	fileLinesNow      int64
+	record            []byte              // record is reused to wrap each write with prefix and suffix
+	chained           []byte              // chained is reused to add TamperEvident trailers to each record
+	chainLine         []byte              // chainLine holds the content of a line TamperEvident has yet to chain
+	chainPrev         [chainLinkSize]byte // chainPrev is the link of the line TamperEvident chained most recently
+	chainNextLine     []byte              // chainNextLine is chainLine once the record being chained is written
+	chainNextPrev     [chainLinkSize]byte // chainNextPrev is chainPrev once the record being chained is written
+	stats             Stats               // stats holds the cumulative counters reported by Stats
+	barrier           bool                // barrier is true while Barrier writes the buffer to the log file
+	rotationCount     uint32              // rotationCount counts completed rotations, and is accessed atomically so Rotate may read it before acquiring the lock
+	sequence          uint64              // sequence is the most recent rotation sequence number
+	byteOffset        int64               // byteOffset is the offset of the first byte of the open log file in the stream of all log files
+	filePointer       logFile
+	fileInfo          fs.FileInfo // fileInfo identifies open log file
+	mustExist         bool        // mustExist is true while log file must be opened without creating it
+	idleClosed        bool        // idleClosed is true after closing idle log file, or failing to reopen it
+	closed            bool        // closed is true once Close has closed the log file
+	rotateRetry       bool        // rotateRetry is true when log file must be rotated after a failed rotation
+	degradedErr       error       // degradedErr is why most recent rotation failed, until it recovers
 	waitingForNewline bool
+	suppressed        bool   // suppressed is true while SuppressRotationDuring defers rotation
+	oversizeOpen      bool   // oversizeOpen is true while open log file ends with start of line too large to buffer
+	holdArchive       bool   // holdArchive is true while RotateAndOpen rotates log file
+	heldArchive       string // heldArchive is path of log file rotated while holdArchive is true
+	session           string // session is ID passed to most recent WriteSession
+
+	contentDefinedMask uint64 // contentDefinedMask selects hash bits that must be zero for boundary
+	contentDefinedHash uint64 // contentDefinedHash is rolling hash of bytes written to log file
+	contentDefinedCut  bool   // contentDefinedCut is true when log file must be rotated
+
+	subscribers      []subscriber    // subscribers are notified of rotations
+	subscriberLast   uint64          // subscriberLast is the most recent subscriber ID
+	rotationsPending []RotationEvent // rotationsPending are events not yet delivered
+	archivesPending  []string        // archivesPending are archived paths not yet passed to OnArchiveComplete
+	produced         []string        // produced are paths of rotated log files, when RemoveOnClose
+
+	tail        []byte // tail is ring buffer of most recently written bytes
+	tailNext    int    // tailNext is index in tail where next byte is stored
+	tailWrapped bool   // tailWrapped is true after tail has been filled
+
+	// mu guards all fields above, because a background goroutine may
+	// access them to close an idle log file.
+	mu           sync.Mutex
+	lockAcquired time.Time // lockAcquired is when mu was acquired, when measuring contention
+
+	now      func() time.Time // now returns the current time
+	idleDone chan struct{}    // idleDone is closed to stop idle goroutine
+	idleWait sync.WaitGroup   // idleWait waits for idle goroutine to exit
+
+	customTimeFormatter bool // customTimeFormatter is true when TimeFormatter was provided rather than chosen
+	nameShortened       bool // nameShortened is true once MaxNameBytes caused a name to be shortened
+
+	lingerTimer *time.Timer // lingerTimer flushes buffer after writes linger
+
+	nextLog chan preparedLog // nextLog receives the prepared next log file, when one is being prepared
+
+	// throttleMu guards the token bucket used to throttle writes,
+	// separately from mu so that writers blocked by the throttle do
+	// not hold mu.
+	throttleMu     sync.Mutex
+	throttleRate   float64       // throttleRate is MaxBytesPerSecond, copied so it is read without mu
+	throttleBurst  float64       // throttleBurst is MaxBytesBurst, copied so it is read without mu
+	throttleTokens float64       // throttleTokens is bytes that may be written without waiting
+	throttleLast   time.Time     // throttleLast is when the token bucket was last refilled
+	throttleDone   chan struct{} // throttleDone is closed to release throttled writers
+	throttleClose  sync.Once     // throttleClose closes throttleDone once
 }
 
 // NewLogWriter returns a new LogWriter, or an error when the provided
 // Config specifies disallowed argument values.
 func NewLogWriter(cfg *Config) (*LogWriter, error) {
-	var err error
-
 	if cfg == nil {
 		cfg = new(Config)
 	}
 
+	customTimeFormatter := cfg.TimeFormatter != nil
+	timeFormatter, contentDefinedMask, err := resolveConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only file path and mode are needed prior to attempting to
+	// create log file.
+	lw := &LogWriter{
+		cfg:                (*cfg),
+		filePath:           filepath.Join(cfg.Directory, activeName(cfg)),
+		now:                time.Now,
+		contentDefinedMask: contentDefinedMask,
+		mustExist:          cfg.RequireExisting,
+	}
+	lw.cfg.TimeFormatter = timeFormatter
+	lw.customTimeFormatter = customTimeFormatter
+	if name, shortened := fitActiveName(cfg); shortened {
+		lw.reportShortenedName(name)
+	}
+	if cfg.RepairOnOpen {
+		if err = repairLog(lw.filePath, cfg.RepairTruncate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SequenceStateFile != "" {
+		if lw.sequence, err = readSequenceState(cfg.SequenceStateFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.IncludeByteRange {
+		if err = lw.resumeByteOffset(); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.VerifyOnStart {
+		if err = lw.verifyArchives(); err != nil {
+			return nil, err
+		}
+	}
+	if err = lw.openLog(); err != nil {
+		return nil, explainOpenError(lw.logDirectory(), err)
+	}
+	if cfg.TamperEvident {
+		if err = lw.resumeChain(); err != nil {
+			_ = lw.closeLog()
+			return nil, err
+		}
+	}
+
+	// The log file is open for writing in append mode. Populate
+	// remainder of structure fields.
+	if cfg.BufferSizeMax > 0 {
+		lw.buf = make([]byte, 0, cfg.BufferSizeMax)
+	}
+
+	if cfg.TailBufferSize > 0 {
+		lw.tail = make([]byte, cfg.TailBufferSize)
+	}
+
+	if cfg.PreallocateNext {
+		lw.prepareNextLog()
+	}
+
+	if cfg.LingerDuration > 0 && cfg.BufferSizeMax > 0 {
+		lw.lingerTimer = time.AfterFunc(cfg.LingerDuration, lw.flushAfterLinger)
+		lw.lingerTimer.Stop() // started by first Write
+	}
+
+	if cfg.MaxBytesPerSecond > 0 {
+		lw.throttleRate = float64(cfg.MaxBytesPerSecond)
+		lw.throttleBurst = float64(cfg.MaxBytesBurst)
+		lw.throttleTokens = lw.throttleBurst
+		lw.throttleLast = time.Now()
+		lw.throttleDone = make(chan struct{})
+	}
+
+	if cfg.WarnOnLeak && cfg.BufferSizeMax > 0 {
+		lw.warnOnLeak()
+	}
+
+	if cfg.IdleCloseAfter > 0 {
+		lw.idleDone = make(chan struct{})
+		lw.idleWait.Add(1)
+		go lw.closeWhenIdle()
+	}
+
+	return lw, nil
+}
+
+// resolveConfig validates cfg, and stores the default value of each
+// field for which NewLogWriter chooses a default. It returns the time
+// formatter to use for rotated log file names, which sanitizes the
+// formatted time when requested, and the mask for content defined
+// rotation.
+func resolveConfig(cfg *Config) (timeFormatter func(time.Time) string, contentDefinedMask uint64, err error) {
 	switch cfg.BufferSizeMax {
 	case -1:
 		cfg.BufferSizeMax = 0 // do not use in-memory buffering
 	case 0:
-		cfg.BufferSizeMax = defaultBufferSizeMax // default buffer size
+		cfg.BufferSizeMax = DefaultBufferSize // default buffer size
 	default:
 		if cfg.BufferSizeMax < 0 {
-			return nil, fmt.Errorf("cannot use negative flush threshold: %d", cfg.BufferSizeMax)
+			return nil, 0, fmt.Errorf("cannot use negative flush threshold: %d", cfg.BufferSizeMax)
+		}
+		if cfg.BufferSizeMax < minBufferSizeMax && !cfg.AllowTinyBuffer {
+			return nil, 0, fmt.Errorf("cannot use buffer size less than %d bytes unless AllowTinyBuffer: %d", minBufferSizeMax, cfg.BufferSizeMax)
+		}
+	}
+
+	if len(cfg.MultiDestination) > 0 {
+		for _, directory := range cfg.MultiDestination {
+			if directory == "" {
+				return nil, 0, errors.New("cannot use empty MultiDestination directory")
+			}
 		}
+		cfg.Directory = cfg.MultiDestination[0]
 	}
 
 	if cfg.Directory == "" {
 		cfg.Directory, err = os.Getwd()
 		if err != nil {
-			return nil, fmt.Errorf("cannot determine working directory: %w", err)
+			return nil, 0, fmt.Errorf("cannot determine working directory: %w", err)
 		}
 	}
 
 	if cfg.BaseNamePrefix == "" {
-		cfg.BaseNamePrefix = filepath.Base(os.Args[0])
+		cfg.BaseNamePrefix = programBaseNamePrefix(os.Args[0], runtime.GOOS)
+	}
+	if strings.ContainsAny(cfg.BaseNamePrefix, `/\`) || cfg.BaseNamePrefix == "." || cfg.BaseNamePrefix == ".." {
+		return nil, 0, fmt.Errorf("cannot use base name prefix that refers outside the directory: %q", cfg.BaseNamePrefix)
+	}
+
+	if cfg.NameSeparator == "" {
+		cfg.NameSeparator = defaultNameSeparator
+	}
+	if invalid := invalidTimestampRunes(cfg.NameSeparator, runtime.GOOS); invalid != "" || strings.ContainsAny(cfg.NameSeparator, `/\`) {
+		return nil, 0, fmt.Errorf("cannot use name separator with characters invalid in file names: %q", cfg.NameSeparator)
+	}
+
+	if cfg.IdleCloseAfter < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative idle close duration: %s", cfg.IdleCloseAfter)
+	}
+
+	if cfg.WriteTimeout < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative write timeout: %s", cfg.WriteTimeout)
+	}
+
+	if cfg.Mmap && cfg.SuppressRotationDuring != nil {
+		return nil, 0, errors.New("cannot use SuppressRotationDuring with Mmap")
+	}
+	if cfg.Mmap && !mmapSupported {
+		return nil, 0, fmt.Errorf("cannot use memory mapped log files on %s", runtime.GOOS)
+	}
+
+	if cfg.OSBuffered {
+		if cfg.BufferSizeMax > 0 {
+			return nil, 0, errors.New("cannot use OSBuffered unless BufferSizeMax is -1")
+		}
+		if cfg.Mmap {
+			return nil, 0, errors.New("cannot use OSBuffered with Mmap")
+		}
+		if cfg.OSBufferSize < 0 {
+			return nil, 0, fmt.Errorf("cannot use negative OS buffer size: %d", cfg.OSBufferSize)
+		}
+		if cfg.OSBufferSize == 0 {
+			cfg.OSBufferSize = defaultOSBufferSize
+		}
+	}
+
+	if cfg.SharedAppend {
+		switch {
+		case cfg.BufferSizeMax > 0:
+			return nil, 0, errors.New("cannot use SharedAppend unless BufferSizeMax is -1")
+		case cfg.ContentDefinedRotation:
+			return nil, 0, errors.New("cannot use SharedAppend with ContentDefinedRotation")
+		case cfg.Mmap:
+			return nil, 0, errors.New("cannot use SharedAppend with Mmap")
+		case cfg.OSBuffered:
+			return nil, 0, errors.New("cannot use SharedAppend with OSBuffered")
+		case cfg.PreallocateNext:
+			return nil, 0, errors.New("cannot use SharedAppend with PreallocateNext")
+		}
+	}
+
+	if cfg.NoRotate {
+		switch {
+		case cfg.ContentDefinedRotation:
+			return nil, 0, errors.New("cannot use NoRotate with ContentDefinedRotation")
+		case cfg.Mmap:
+			return nil, 0, errors.New("cannot use NoRotate with Mmap")
+		case len(cfg.MultiDestination) > 0:
+			return nil, 0, errors.New("cannot use NoRotate with MultiDestination")
+		case cfg.PreallocateNext:
+			return nil, 0, errors.New("cannot use NoRotate with PreallocateNext")
+		case len(cfg.RotateOnMarker) > 0:
+			return nil, 0, errors.New("cannot use NoRotate with RotateOnMarker")
+		case cfg.RotateOnSessionChange:
+			return nil, 0, errors.New("cannot use NoRotate with RotateOnSessionChange")
+		case cfg.StageActive:
+			return nil, 0, errors.New("cannot use NoRotate with StageActive")
+		}
+	}
+
+	if cfg.FrameMode {
+		switch {
+		case cfg.FileFooterFunc != nil:
+			return nil, 0, errors.New("cannot use FrameMode with FileFooterFunc")
+		case cfg.NewFileFunc != nil:
+			return nil, 0, errors.New("cannot use FrameMode with NewFileFunc")
+		case cfg.RepairOnOpen:
+			return nil, 0, errors.New("cannot use FrameMode with RepairOnOpen")
+		case len(cfg.RotateOnMarker) > 0:
+			return nil, 0, errors.New("cannot use FrameMode with RotateOnMarker")
+		}
+	}
+
+	if cfg.TamperEvident {
+		switch {
+		case cfg.FileFooterFunc != nil:
+			return nil, 0, errors.New("cannot use TamperEvident with FileFooterFunc")
+		case cfg.FrameMode:
+			return nil, 0, errors.New("cannot use TamperEvident with FrameMode")
+		case cfg.NewFileFunc != nil:
+			return nil, 0, errors.New("cannot use TamperEvident with NewFileFunc")
+		case cfg.SharedAppend:
+			return nil, 0, errors.New("cannot use TamperEvident with SharedAppend")
+		}
+	}
+
+	if cfg.FlushThreshold < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative flush threshold: %d", cfg.FlushThreshold)
+	}
+	if cfg.MaxPendingExtents < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative max pending extents: %d", cfg.MaxPendingExtents)
+	}
+
+	if cfg.TailBufferSize < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative tail buffer size: %d", cfg.TailBufferSize)
+	}
+
+	if cfg.MaxNameBytes < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative max name bytes: %d", cfg.MaxNameBytes)
+	}
+	if cfg.MaxNameBytes == 0 {
+		cfg.MaxNameBytes = DefaultMaxNameBytes
+	}
+	if cfg.MaxMemoryBytes < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative max memory bytes: %d", cfg.MaxMemoryBytes)
+	}
+	if cfg.MaxMemoryBytes > 0 && cfg.MaxMemoryBytes <= int64(cfg.TailBufferSize) {
+		return nil, 0, fmt.Errorf("cannot use max memory bytes not larger than tail buffer size: %d", cfg.MaxMemoryBytes)
+	}
+
+	if cfg.MaxBytesPerSecond < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative max bytes per second: %d", cfg.MaxBytesPerSecond)
+	}
+	if cfg.MaxBytesPerSecond > 0 {
+		if cfg.MaxBytesBurst < 0 {
+			return nil, 0, fmt.Errorf("cannot use negative max bytes burst: %d", cfg.MaxBytesBurst)
+		}
+		if cfg.MaxBytesBurst == 0 {
+			cfg.MaxBytesBurst = cfg.MaxBytesPerSecond
+		}
+	}
+
+	if cfg.CoordinationLock != "" && !lockSupported {
+		return nil, 0, fmt.Errorf("cannot use coordination lock on %s", runtime.GOOS)
 	}
 
 	if cfg.FileMode == 0 {
-		cfg.FileMode = defaultFileMode
+		cfg.FileMode = DefaultFileMode
+	}
+	for directory, mode := range cfg.DirFileMode {
+		if mode&^fs.ModePerm != 0 {
+			return nil, 0, fmt.Errorf("cannot use file mode for %q with bits other than permission bits: %#o", directory, uint32(mode))
+		}
 	}
 
 	if cfg.MaxBytes == 0 {
-		cfg.MaxBytes = defaultMaxBytes // default buffer size
+		cfg.MaxBytes = DefaultMaxBytes // default buffer size
+	}
+	if cfg.MaxBytes < 0 {
+		return nil, 0, fmt.Errorf("cannot use negative max bytes: %d", cfg.MaxBytes)
+	}
+	if cfg.MaxBytes > maxMaxBytes {
+		return nil, 0, fmt.Errorf("cannot use max bytes larger than %d: %d", int64(maxMaxBytes), cfg.MaxBytes)
+	}
+
+	if cfg.ContentDefinedRotation {
+		if cfg.ContentDefinedMinBytes == 0 {
+			cfg.ContentDefinedMinBytes = cfg.MaxBytes / 2
+		}
+		if cfg.ContentDefinedMaxBytes == 0 {
+			cfg.ContentDefinedMaxBytes = cfg.MaxBytes * 2
+		}
+		if cfg.ContentDefinedMinBytes <= 0 || cfg.ContentDefinedMinBytes >= cfg.MaxBytes || cfg.MaxBytes >= cfg.ContentDefinedMaxBytes {
+			return nil, 0, fmt.Errorf("cannot use content defined rotation unless 0 < min bytes < max bytes < content defined max bytes: %d, %d, %d", cfg.ContentDefinedMinBytes, cfg.MaxBytes, cfg.ContentDefinedMaxBytes)
+		}
+		contentDefinedMask = makeContentDefinedMask(cfg.MaxBytes - cfg.ContentDefinedMinBytes)
 	}
 
 	if cfg.TimeFormatter == nil {
@@ -170,23 +1407,19 @@ func NewLogWriter(cfg *Config) (*LogWriter, error) {
 		}
 	}
 
-	// Only file path and mode are needed prior to attempting to
-	// create log file.
-	lw := &LogWriter{
-		cfg:      (*cfg),
-		filePath: filepath.Join(cfg.Directory, cfg.BaseNamePrefix+".log"),
-	}
-	if err = lw.openLog(); err != nil {
-		return nil, err
+	timeFormatter = cfg.TimeFormatter
+	var sample string
+	if err = callSafely("TimeFormatter", func() { sample = timeFormatter(time.Now()) }); err != nil {
+		return nil, 0, err
 	}
-
-	// The log file is open for writing in append mode. Populate
-	// remainder of structure fields.
-	if cfg.BufferSizeMax > 0 {
-		lw.buf = make([]byte, 0, cfg.BufferSizeMax)
+	if invalid := invalidTimestampRunes(sample, runtime.GOOS); invalid != "" {
+		if !cfg.SanitizeTimestamp {
+			return nil, 0, fmt.Errorf("cannot use time format that produces characters invalid in file names: %q", invalid)
+		}
+		timeFormatter = makeSanitizingFormatter(timeFormatter, runtime.GOOS)
 	}
 
-	return lw, nil
+	return timeFormatter, contentDefinedMask, nil
 }
 
 // Close satisfies the io.Closer interface, and will flush and close
@@ -196,44 +1429,192 @@ func NewLogWriter(cfg *Config) (*LogWriter, error) {
 // terminated, it will be flushed as well, along with an appended
 // newline character. This is done to prevent the next use of the log
 // file from appending its first line to the middle of the previously
-// written unterminated line.
+// written unterminated line. When Close returns nil, all buffered data
+// has been written to the log file. When it returns an error from
+// writing the buffer, the unwritten data remains in the buffer, and
+// Close may be invoked again to try writing it again. Once Close has
+// closed the log file, invoking it again, or writing to the LogWriter,
+// returns an error that wraps fs.ErrClosed.
 func (lw *LogWriter) Close() error {
-	debug("Close: buffer size: %d bytes\n", len(lw.buf))
+	if lw.throttleDone != nil {
+		// Release writers blocked by the throttle before acquiring
+		// the lock, so they do not write after the log file closed.
+		lw.throttleClose.Do(func() { close(lw.throttleDone) })
+	}
+
+	if lw.idleDone != nil {
+		// Stop the idle goroutine before acquiring the lock so it
+		// cannot close the log file after this method closes it.
+		close(lw.idleDone)
+		lw.idleWait.Wait()
+		lw.idleDone = nil
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.tracing() {
+		lw.trace("Close", "path", lw.filePath, "buffered", len(lw.buf))
+	}
+
+	if lw.lingerTimer != nil {
+		// The buffer is flushed below, and releasing the timer
+		// prevents it from flushing after the log file is closed.
+		lw.lingerTimer.Stop()
+		lw.lingerTimer = nil
+	}
+
+	// Remove the prepared next log file, which will never be used.
+	lw.discardNextLog()
+
+	if lw.oversizeOpen {
+		if err := lw.ensureLogOpen(); err != nil {
+			return err
+		}
+		debug("Close: appending newline to complete the oversize line\n")
+		if _, err := lw.writeOversizeContinuation(lw.lineEnding()); err != nil {
+			return err
+		}
+		lw.commitChain()
+	}
 
 	if len(lw.buf) > 0 {
+		if err := lw.ensureLogOpen(); err != nil {
+			return err
+		}
 		// Flush in-memory buffer before we close file.
 		if lw.waitingForNewline {
 			debug("Close: appending newline to complete the final extent\n")
-			lw.buf = append(lw.buf, '\n')
-			lw.extents[len(lw.extents)-1]++
+			ending := lw.lineEnding()
+			lw.buf = append(lw.buf, ending...)
+			lw.extents[len(lw.extents)-1] += len(ending)
+			lw.commitChain()
 			lw.waitingForNewline = false
 		}
 		if err := lw.flushCompletedExtents(); err != nil {
-			// There is loss of data when cannot write everything.
+			// Close the log file as though it were idle, leaving the
+			// unwritten data in the buffer, so invoking Close again
+			// reopens the log file and tries to write it again.
+			_ = lw.closeLog()
+			lw.idleClosed = true
+			return err
+		}
+		if len(lw.buf) > 0 || len(lw.extents) > 0 {
+			// Every extent is newline terminated, so nothing ought to
+			// remain in the buffer after a successful flush.
 			_ = lw.closeLog()
+			lw.idleClosed = true
+			return fmt.Errorf("cannot flush entire buffer: %d bytes in %d extents remain", len(lw.buf), len(lw.extents))
+		}
+	}
+
+	if lw.cfg.TamperEvident && len(lw.chainLine) > 0 {
+		// A final unbuffered write was not newline terminated, and
+		// VerifyChain cannot verify a line without its trailer.
+		if err := lw.ensureLogOpen(); err != nil {
+			return err
+		}
+		debug("Close: appending trailer to complete the final line\n")
+		if _, err := lw.writeBytes(lw.lineEnding()); err != nil {
 			return err
 		}
+		lw.commitChain()
+	}
+
+	if lw.cfg.WarnOnLeak {
+		// No buffered data remains to be lost.
+		runtime.SetFinalizer(lw, nil)
+	}
+
+	// All data has been flushed, so the log file is empty only when
+	// nothing has ever been written to it.
+	removeLog := lw.cfg.RemoveEmptyOnClose && lw.fileSizeNow == 0
+
+	var err error
+
+	if lw.idleClosed {
+		// The log file was closed while idle, and there is nothing
+		// more to write to it.
+		debug("Close: log file already closed while idle\n")
+		lw.idleClosed = false
+		lw.fileSizeNow = 0
+		lw.fileLinesNow = 0
+	} else if err = lw.closeLog(); err != nil {
+		return err
+	}
+	lw.closed = true
+
+	if removeLog {
+		if lw.tracing() {
+			lw.trace("Close", "path", lw.filePath, "removeEmpty", true)
+		}
+		if err = os.Remove(lw.filePath); errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
 	}
 
-	return lw.closeLog()
+	if lw.cfg.RemoveOnClose {
+		if rerr := lw.removeProduced(); err == nil {
+			err = rerr
+		}
+	}
+
+	return err
 }
 
-// TODO: Consider exporting this method, or one similar to it.
+// flushCompletedExtents writes all completed extents in the buffer to
+// one or more log files, rotating the log file as needed. When it fails,
+// and RecoverOnFlushError is true, the completed extents that remain in
+// the buffer are written to the recovery file instead. When it leaves
+// the buffer empty, it releases memory retained from earlier bursts of
+// writes.
 func (lw *LogWriter) flushCompletedExtents() error {
-	debug("flushCompletedExtents: extents: %d; bytes: %d\n", len(lw.extents), len(lw.buf))
+	err := lw.writeCompletedExtents()
+	if err != nil {
+		if lw.cfg.RecoverOnFlushError {
+			lw.recoverBuffer(err)
+		}
+		return err
+	}
+	lw.compactBuffers()
+	return nil
+}
+
+// writeCompletedExtents writes all completed extents in the buffer to
+// the log file, as described by flushCompletedExtents.
+func (lw *LogWriter) writeCompletedExtents() error {
+	debug("writeCompletedExtents: extents: %d; bytes: %d\n", len(lw.extents), len(lw.buf))
 	var err error
 
+	lw.suppressRotation(lw.now())
+
+	if err = lw.retryRotation(); err != nil {
+		return err
+	}
+
 	// Loop through all of the completed extents waiting to be
 	// written.
 	for len(lw.extents) > 0 {
 		if len(lw.extents) == 1 && lw.waitingForNewline {
-			debug("flushCompletedExtents: single non terminated extent remains\n")
+			debug("writeCompletedExtents: single non terminated extent remains\n")
 			// Nothing more can be written when a single incomplete
-			// extent remains.
+			// extent remains, because it may still be growing, unless
+			// it has already outgrown a log file of its own.
+			if lw.cfg.FlushOversizeImmediately && int64(lw.extents[0]) > lw.maxFileBytes() {
+				return lw.flushOversizeFragment()
+			}
 			break
 		}
-		if int64(lw.extents[0])+lw.fileSizeNow > lw.cfg.MaxBytes {
-			debug("flushCompletedExtents: first extent too large for this log file\n")
+		if lw.fileSizeNow > 0 && (lw.contentDefinedCut || lw.isRotationMarker(lw.buf[:lw.extents[0]])) {
+			debug("writeCompletedExtents: content defined boundary, or first extent is rotation marker\n")
+			// Rotate the log file so the first extent begins the new
+			// log file.
+			if err = lw.rotateLog(); err != nil {
+				return err
+			}
+		}
+		if lw.wouldExceedMaxFileBytes(int64(lw.extents[0])) {
+			debug("writeCompletedExtents: first extent too large for this log file\n")
 			// Rotate the log file when the next extent will not fit
 			// in the open log file.
 			if lw.fileSizeNow > 0 {
@@ -241,8 +1622,8 @@ func (lw *LogWriter) flushCompletedExtents() error {
 					return err
 				}
 			}
-			if int64(lw.extents[0]) > lw.cfg.MaxBytes {
-				debug("flushCompletedExtents: first extent too large for empty log file\n")
+			if int64(lw.extents[0]) > lw.maxFileBytes() {
+				debug("writeCompletedExtents: first extent too large for empty log file\n")
 				// This particular extent is too large to fit even in
 				// its own log file. When this happens, put the data
 				// in its own file, even if that file is larger than
@@ -258,12 +1639,72 @@ func (lw *LogWriter) flushCompletedExtents() error {
 		if err = lw.flushAsMuchAsPossible(); err != nil {
 			return err
 		}
-		debug("flushCompletedExtents: after flush, extents: %d; bytes: %d remains\n", len(lw.extents), len(lw.buf))
+		debug("writeCompletedExtents: after flush, extents: %d; bytes: %d remains\n", len(lw.extents), len(lw.buf))
 	}
 
 	return nil
 }
 
+// flushOversizeFragment writes the single extent in the buffer, which
+// is not newline terminated, and is too large to fit in a log file, to
+// its own log file, where the remainder of its line will be written.
+func (lw *LogWriter) flushOversizeFragment() error {
+	debug("flushOversizeFragment: %d bytes\n", lw.extents[0])
+	if lw.fileSizeNow > 0 {
+		if err := lw.rotateLog(); err != nil {
+			return err
+		}
+	}
+	if _, err := lw.writeExtents(1, lw.extents[0]); err != nil {
+		return err
+	}
+	lw.oversizeOpen = true
+	return nil
+}
+
+// writeOversizeContinuation writes p, which continues the oversize line
+// written by flushOversizeFragment, directly to the open log file, so
+// the line is not split across log files.
+func (lw *LogWriter) writeOversizeContinuation(p []byte) (int, error) {
+	written, err := lw.writeBytes(p)
+	if written > 0 {
+		lw.stats.BytesLogged += int64(written)
+		lw.appendTail(p[:written])
+	}
+	if err == nil {
+		lw.waitingForNewline = !lw.cfg.FrameMode && p[len(p)-1] != '\n'
+		lw.oversizeOpen = lw.waitingForNewline
+	}
+	return written, err
+}
+
+// overMemoryBudget returns true when appending n more bytes to the
+// buffer would exceed MaxMemoryBytes.
+func (lw *LogWriter) overMemoryBudget(n int) bool {
+	return lw.cfg.MaxMemoryBytes > 0 && int64(len(lw.buf)+len(lw.tail)+n) > lw.cfg.MaxMemoryBytes
+}
+
+// writeOverBudget writes p, which cannot be appended to the buffer
+// without exceeding MaxMemoryBytes, directly to the log file, after the
+// buffer, which is either empty, or holds the beginning of the line p
+// continues. When p is not newline terminated, the remainder of its
+// line is written directly to the same log file as well, so the line
+// is not split across log files.
+func (lw *LogWriter) writeOverBudget(p []byte) (int, error) {
+	if lw.fileSizeNow > 0 && lw.wouldExceedMaxFileBytes(int64(len(lw.buf)+len(p))) {
+		if err := lw.rotateLog(); err != nil {
+			return 0, err
+		}
+	}
+	if len(lw.buf) > 0 {
+		if _, err := lw.writeExtents(1, len(lw.buf)); err != nil {
+			return 0, err
+		}
+	}
+	lw.oversizeOpen = true
+	return lw.writeOversizeContinuation(p)
+}
+
 // flushAsMuchAsPossible will flush as many of the completed write
 // extents as possible to the open log file without exceeding the
 // configured maximum log file size, and without writing an extent
@@ -290,17 +1731,29 @@ func (lw *LogWriter) flushAsMuchAsPossible() error {
 	// Determine how many extents may be flushed to the open log file
 	// before rotation based on configured file size limit and the
 	// size of each successive extent.
-	bytesRemaining := lw.cfg.MaxBytes - lw.fileSizeNow
+	bytesRemaining := lw.maxFileBytes() - lw.fileSizeNow
 
 	var flushByteCount int64
 	var flushExtentCount int
+	var cut bool
+	hash := lw.contentDefinedHash
 
 	for flushExtentCount = 0; flushExtentCount < flushExtentCountMax; flushExtentCount++ {
 		fbc := flushByteCount + int64(lw.extents[flushExtentCount])
 		if fbc > bytesRemaining {
 			break // this extent will not fit in the open log file
 		}
+		if flushExtentCount > 0 && lw.isRotationMarker(lw.buf[flushByteCount:fbc]) {
+			break // this extent must begin a new log file
+		}
+		if lw.cfg.ContentDefinedRotation {
+			hash, cut = lw.scanContentDefined(hash, lw.fileSizeNow+flushByteCount, lw.buf[flushByteCount:fbc])
+		}
 		flushByteCount = fbc
+		if cut {
+			flushExtentCount++
+			break // this extent ends at a content defined boundary
+		}
 	}
 
 	// All extents strictly less than flushExtentCount may be flushed
@@ -319,9 +1772,35 @@ func (lw *LogWriter) flushAsMuchAsPossible() error {
 	// Flush as much as the buffer as possible to open log file such
 	// that it will not exceed max bytes.
 	_, err := lw.writeExtents(flushExtentCount, int(flushByteCount))
+	if err == nil && lw.cfg.ContentDefinedRotation {
+		lw.contentDefinedHash, lw.contentDefinedCut = hash, cut
+	}
 	return err
 }
 
+// isRotationMarker returns true when the configured rotation marker
+// is a prefix of extent.
+func (lw *LogWriter) isRotationMarker(extent []byte) bool {
+	return len(lw.cfg.RotateOnMarker) > 0 && bytes.HasPrefix(extent, lw.cfg.RotateOnMarker)
+}
+
+// String returns a summary of the state of the LogWriter, including
+// the path and size of its open log file, how full its buffer is, and
+// how many writes are buffered waiting to be flushed, suitable for
+// logging or debugging.
+func (lw *LogWriter) String() string {
+	lw.lock()
+	defer lw.unlock()
+
+	buffer := "unbuffered"
+	if lw.cfg.BufferSizeMax > 0 {
+		buffer = fmt.Sprintf("%d/%d bytes", len(lw.buf), lw.cfg.BufferSizeMax)
+	}
+
+	return fmt.Sprintf("LogWriter{Path: %q, Size: %d, Buffer: %s, PendingExtents: %d, WaitingForNewline: %t, Open: %t}",
+		lw.filePath, lw.fileSizeNow, buffer, len(lw.extents), lw.waitingForNewline, lw.filePointer != nil)
+}
+
 // Write satisfies the io.Writer interface, allowing a program to
 // write byte slices to the LogWriter. When the combined size of the
 // current log file and the size of the provided byte slice is larger
@@ -333,33 +1812,204 @@ func (lw *LogWriter) flushAsMuchAsPossible() error {
 // time the LogWriter receives an error while attempting to roll the
 // underlying output file, it simply writes the byte slice to the
 // existing underlying file.
-func (lw *LogWriter) Write(p []byte) (written int, err error) {
-	if lw.timeOfFirstWrite == "" {
-		// Store the current time when this particular log file has
-		// yet to be written to. Later, when renaming the log file
-		// with a timestamp, will use this recorded time in the file
-		// name for the renamed log file.
-		lw.timeOfFirstWrite = lw.cfg.TimeFormatter(time.Now())
-		debug("time of first write: %q\n", lw.timeOfFirstWrite)
+func (lw *LogWriter) Write(p []byte) (int, error) {
+	if err := lw.throttle(len(p)); err != nil {
+		return 0, err
+	}
+	lw.lock()
+	defer lw.unlock()
+	return lw.write(p)
+}
+
+// WriteBatch writes each record to the LogWriter as if by a separate
+// invocation of Write, so each record is a separate extent that will
+// not be split across log files, but acquires the lock only once for
+// the entire batch, reducing lock contention for programs writing
+// many records at once. It stops at the first error, returning the
+// total number of bytes written from all records.
+func (lw *LogWriter) WriteBatch(records [][]byte) (int, error) {
+	if lw.throttleDone != nil {
+		var size int
+		for _, record := range records {
+			size += len(record)
+		}
+		if err := lw.throttle(size); err != nil {
+			return 0, err
+		}
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	var total int
+	for _, record := range records {
+		nw, err := lw.write(record)
+		total += nw
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// hasCompletedExtent returns true when the buffer holds at least one
+// newline terminated extent that may be flushed to the log file. Every
+// extent but the final one is newline terminated, and the final one is
+// too unless waitingForNewline is set, so the buffer holds a completed
+// extent unless it is empty, or its only extent is waiting for a
+// newline.
+func (lw *LogWriter) hasCompletedExtent() bool {
+	return len(lw.extents) > 1 || (len(lw.extents) == 1 && !lw.waitingForNewline)
+}
+
+// flushDue returns true when a Write has just filled the buffer to the
+// point that its completed extents ought to be flushed, rather than
+// waiting for a subsequent Write to overflow the buffer.
+func (lw *LogWriter) flushDue() bool {
+	if (lw.cfg.FlushOnBufferFull || lw.lingerTimer != nil) && len(lw.buf) >= lw.cfg.BufferSizeMax {
+		return true
+	}
+	if lw.cfg.FlushTerminatedWrites && !lw.waitingForNewline {
+		return true
+	}
+	if lw.cfg.FlushOversizeImmediately && lw.waitingForNewline && int64(lw.extents[len(lw.extents)-1]) > lw.maxFileBytes() {
+		return true
+	}
+	if lw.cfg.FlushThreshold > 0 && len(lw.buf) >= lw.cfg.FlushThreshold {
+		return true
+	}
+	if lw.cfg.MaxPendingExtents > 0 {
+		completed := len(lw.extents)
+		if lw.waitingForNewline {
+			completed--
+		}
+		return completed >= lw.cfg.MaxPendingExtents
+	}
+	return false
+}
+
+// write writes p to the LogWriter while the lock is held, first
+// wrapping it with the configured record prefix and suffix.
+func (lw *LogWriter) write(p []byte) (int, error) {
+	if lw.closed {
+		return 0, fs.ErrClosed
+	}
+	if len(p) == 0 {
+		// Nothing to write, and an empty write neither creates an
+		// extent nor terminates one.
+		return 0, nil
+	}
+
+	if lw.cfg.ValidateUTF8 && !utf8.Valid(p) {
+		if !lw.cfg.ReplaceInvalidUTF8 {
+			return 0, fmt.Errorf("cannot write invalid UTF-8 at byte offset %d", invalidUTF8Offset(p))
+		}
+		debug("write: replacing invalid UTF-8\n")
+		if _, err := lw.write(bytes.ToValidUTF8(p, replacementCharacter)); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if lw.cfg.RecordPrefixFunc == nil && len(lw.cfg.RecordSuffix) == 0 && !lw.cfg.FrameMode && !lw.cfg.TamperEvident {
+		return lw.writeRecord(p)
+	}
+
+	// Reuse the record buffer for each write, because the record is
+	// either copied to the in-memory buffer or written to the log file
+	// before this returns.
+	var prefix []byte
+	if lw.cfg.RecordPrefixFunc != nil {
+		if err := callSafely("RecordPrefixFunc", func() { prefix = lw.cfg.RecordPrefixFunc() }); err != nil {
+			lw.reportError(err)
+		}
+	}
+	lw.record = lw.record[:0]
+	if lw.cfg.FrameMode {
+		lw.record = appendFrameHeader(lw.record)
+	}
+	lw.record = append(append(append(lw.record, prefix...), p...), lw.cfg.RecordSuffix...)
+	if lw.cfg.FrameMode {
+		if err := putFrameLength(lw.record); err != nil {
+			return 0, err
+		}
+	}
+	lead := len(lw.record) - len(p) - len(lw.cfg.RecordSuffix)
+
+	if lw.cfg.TamperEvident {
+		// The trailers are interspersed with the data, so the number
+		// of bytes of p written cannot be determined from a partial
+		// write, which breaks the chain regardless.
+		lw.chained = lw.appendChained(lw.chained[:0], lw.record)
+		if _, err := lw.writeRecord(lw.chained); err != nil {
+			return 0, err
+		}
+		lw.commitChain()
+		return len(p), nil
+	}
+
+	nw, err := lw.writeRecord(lw.record)
+
+	// Report how many bytes of p were written, not counting the
+	// frame header, prefix, and suffix.
+	nw -= lead
+	if nw < 0 {
+		nw = 0
+	} else if nw > len(p) {
+		nw = len(p)
 	}
+	return nw, err
+}
+
+// writeRecord writes the non-empty record p to the LogWriter while the
+// lock is held.
+func (lw *LogWriter) writeRecord(p []byte) (written int, err error) {
+	if err = lw.ensureLogOpen(); err != nil {
+		return 0, err
+	}
+
+	lw.stats.Writes++
+
+	lw.timeOfLastWrite = lw.now()
+	lw.suppressRotation(lw.timeOfLastWrite)
 
 	if lw.cfg.BufferSizeMax > 0 {
 		// Buffer the writes through the in-memory buffer when
 		// configured.
 		debug("Write(%d bytes): buffer has %d out of %d filled\n", len(p), len(lw.buf), lw.cfg.BufferSizeMax)
 
+		if lw.oversizeOpen {
+			debug("Write: continuing oversize line in open log file\n")
+			return lw.writeOversizeContinuation(p)
+		}
+
 		if len(lw.buf) > 0 && len(lw.buf)+len(p) > lw.cfg.BufferSizeMax {
 			debug("Write: p will not fit in non-empty buffer\n")
 			// Once a Write triggers having to flush the buffer, might
 			// as well flush as much as possible to one or more files.
-
-			if len(lw.extents) > 1 || !lw.waitingForNewline {
+			//
+			// When the buffer holds only an extent waiting for its
+			// newline there is nothing that can be flushed, and p is
+			// appended to that extent below, growing the buffer
+			// beyond BufferSizeMax until the extent is completed.
+			if lw.hasCompletedExtent() {
 				if err = lw.flushCompletedExtents(); err != nil {
 					return 0, err
 				}
 			}
 		}
 
+		if lw.overMemoryBudget(len(p)) && lw.hasCompletedExtent() {
+			debug("Write: p would exceed memory budget\n")
+			if err = lw.flushCompletedExtents(); err != nil {
+				return 0, err
+			}
+		}
+		if lw.overMemoryBudget(len(p)) {
+			debug("Write: p would exceed memory budget after flush\n")
+			return lw.writeOverBudget(p)
+		}
+
 		// The in-memory buffer is as empty as it can get before we
 		// write p to it.
 
@@ -374,29 +2024,67 @@ func (lw *LogWriter) Write(p []byte) (written int, err error) {
 			// Create and append a new write extent when previous
 			// write was terminated with newline.
 			lw.extents = append(lw.extents, len(p))
+			lw.writeTimes = append(lw.writeTimes, lw.timeOfLastWrite)
 		}
 
 		// Append p to the buffer, and remember whether this write was
 		// terminated with a newline for use during next write.
 		debug("Write: appended %d bytes to buffer\n", len(p))
 		lw.buf = append(lw.buf, p...)
-		lw.waitingForNewline = p[len(p)-1] != '\n'
+		lw.stats.BytesLogged += int64(len(p))
+		lw.stats.BytesMoved += int64(len(p))
+		lw.appendTail(p)
+		lw.waitingForNewline = !lw.cfg.FrameMode && p[len(p)-1] != '\n'
 		debug("Write: final byte is newline: %t\n", !lw.waitingForNewline)
 
+		if lw.flushDue() {
+			debug("Write: buffer full, or flush threshold reached\n")
+			// Rather than waiting for the next Write to discover the
+			// buffer is full, flush completed extents now to reduce
+			// the latency of the data reaching the log file.
+			if err = lw.flushCompletedExtents(); err != nil {
+				return len(p), err
+			}
+		}
+
+		if lw.lingerTimer != nil {
+			lw.lingerTimer.Reset(lw.cfg.LingerDuration)
+		}
+
 		return len(p), nil
 	}
 
 	// Write p to disk when not configured for in-memory buffering.
 	debug("Write(%d bytes): not using buffer\n", len(p))
 
-	if lw.fileSizeNow > 0 && lw.fileSizeNow+int64(len(p)) > lw.cfg.MaxBytes {
-		debug("Write: p will not fit in open log file\n")
+	if err = lw.retryRotation(); err != nil {
+		return 0, err
+	}
+
+	if lw.cfg.SharedAppend {
+		if err = lw.refreshSharedSize(); err != nil {
+			return 0, err
+		}
+	}
+
+	if lw.fileSizeNow > 0 && (lw.wouldExceedMaxFileBytes(int64(len(p))) || lw.contentDefinedCut || lw.isRotationMarker(p)) {
+		debug("Write: p will not fit in open log file, content defined boundary, or is rotation marker\n")
 		// Rotate the open log file when it does not have enough room
-		// to hold the contents of p.
+		// to hold the contents of p, or when p must begin a new log
+		// file.
 		if err = lw.rotateLog(); err != nil {
 			return 0, err
 		}
 	}
 
-	return lw.writeBytes(p)
+	size := lw.fileSizeNow
+	written, err = lw.writeBytes(p)
+	if written > 0 {
+		lw.stats.BytesLogged += int64(written)
+		lw.appendTail(p[:written])
+		if lw.cfg.ContentDefinedRotation {
+			lw.contentDefinedHash, lw.contentDefinedCut = lw.scanContentDefined(lw.contentDefinedHash, size, p[:written])
+		}
+	}
+	return written, err
 }
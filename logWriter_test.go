@@ -3,8 +3,18 @@ package golw
 import (
 	"bytes"
 	_ "embed"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 //go:embed 2600-0.txt
@@ -103,3 +113,1454 @@ func TestNewLogWriter(t *testing.T) {
 		ensureError(t, lw.Close())
 	})
 }
+
+func TestFileFooterFunc(t *testing.T) {
+	directory := t.TempDir()
+
+	footer := func(state FileState) []byte {
+		return []byte(fmt.Sprintf("# bytes=%d lines=%d\n", state.Bytes, state.Lines))
+	}
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "footer",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		FileFooterFunc: footer,
+		MaxBytes:       64,
+	})
+	ensureError(t, err)
+
+	for i := 0; i < 20; i++ {
+		_, err = fmt.Fprintf(lw, "line %04d\n", i)
+		ensureError(t, err)
+	}
+
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "footer")
+	if got, want := len(archives), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	for _, archive := range archives {
+		buf := readFile(t, archive)
+		lines := bytes.SplitAfter(buf, newline)
+		// The final element is empty because the footer ends with a
+		// newline.
+		last := lines[len(lines)-2]
+		content := buf[:len(buf)-len(last)]
+		ensureBuffer(t, last, footer(FileState{
+			Bytes: int64(len(content)),
+			Lines: int64(bytes.Count(content, newline)),
+		}))
+	}
+
+	// The active log file is not rotated upon Close, so it must not
+	// have a footer.
+	active := readFile(t, filepath.Join(directory, "footer.log"))
+	if bytes.Contains(active, []byte("#")) {
+		t.Errorf("GOT: %q; WANT: no footer", active)
+	}
+}
+
+func TestNewFileFunc(t *testing.T) {
+	directory := t.TempDir()
+
+	preamble := func(prevArchivePath string) []byte {
+		return []byte(fmt.Sprintf("# previous=%s\n", filepath.Base(prevArchivePath)))
+	}
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:  "preamble",
+		BufferSizeMax:   -1,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        75,
+		NewFileFunc:     preamble,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	var events []RotationEvent
+	lw.Subscribe(func(event RotationEvent) { events = append(events, event) })
+
+	for i := 0; i < 10; i++ {
+		_, err = fmt.Fprintf(lw, "line %04d\n", i)
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "preamble")
+	if got, want := len(archives), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// The first log file was opened by NewLogWriter, so it has no
+	// preamble, while each of its replacements references the log
+	// file it replaced, and the preamble counts toward its size.
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 0000\nline 0001\nline 0002\nline 0003\nline 0004\nline 0005\nline 0006\n"))
+	ensureBuffer(t, readFile(t, archives[1]), append(preamble(archives[0]), "line 0007\nline 0008\n"...))
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "preamble.log")), append(preamble(archives[1]), "line 0009\n"...))
+
+	if got, want := len(events), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := events[1].Bytes, int64(len(preamble(archives[0]))+20); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestProgramBaseNamePrefix(t *testing.T) {
+	cases := []struct {
+		programName, goos, want string
+	}{
+		{"prog.exe", "windows", "prog"},
+		{`C:\Program Files\Tools\prog.EXE`, "windows", "prog"},
+		{"prog.exe", "linux", "prog.exe"},
+		{"/usr/local/bin/my prog", "linux", "my_prog"},
+		{"/usr/local/bin/web-server_v2.1", "darwin", "web-server_v2.1"},
+		{"/tmp/go-build123/b001/exe/main:debug", "linux", "main_debug"},
+		{"", "linux", "golw"},
+	}
+
+	for _, tc := range cases {
+		if got := programBaseNamePrefix(tc.programName, tc.goos); got != tc.want {
+			t.Errorf("%q on %s: GOT: %q; WANT: %q", tc.programName, tc.goos, got, tc.want)
+		}
+	}
+
+	t.Run("explicit prefix untouched", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "my prog",
+			Directory:      directory,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		_, err = os.Stat(filepath.Join(directory, "my prog.log"))
+		ensureError(t, err)
+	})
+
+	t.Run("explicit prefix outside directory", func(t *testing.T) {
+		for _, prefix := range []string{"../x", "sub/x", `sub\x`, ".", ".."} {
+			_, err := NewLogWriter(&Config{
+				BaseNamePrefix: prefix,
+				Directory:      t.TempDir(),
+			})
+			ensureError(t, err, "base name prefix")
+		}
+	})
+}
+
+func TestFlushOnBufferFull(t *testing.T) {
+	const line = "0123456789abcde\n" // exactly fills the buffer
+
+	run := func(t *testing.T, flushOnBufferFull bool, want string) {
+		t.Helper()
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "buffer-full",
+			BufferSizeMax:     len(line),
+			Directory:         directory,
+			FlushOnBufferFull: flushOnBufferFull,
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "buffer-full.log")), []byte(want))
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "buffer-full.log")), []byte(line))
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		run(t, false, "")
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		run(t, true, line)
+	})
+
+	t.Run("enabled with unterminated write", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "buffer-full",
+			BufferSizeMax:     len(line),
+			Directory:         directory,
+			FlushOnBufferFull: true,
+		})
+		ensureError(t, err)
+
+		// A full buffer holding only an incomplete line cannot be
+		// flushed.
+		_, err = lw.Write([]byte(line[:len(line)-1] + "f"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "buffer-full.log")), nil)
+
+		_, err = lw.Write([]byte("\n"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "buffer-full.log")), []byte(line[:len(line)-1]+"f\n"))
+
+		ensureError(t, lw.Close())
+	})
+}
+
+func TestFlushPolicy(t *testing.T) {
+	newLogWriter := func(t *testing.T) *LogWriter {
+		t.Helper()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "policy",
+			BufferSizeMax:     4096,
+			Directory:         t.TempDir(),
+			FlushThreshold:    64,
+			MaxPendingExtents: 3,
+		})
+		ensureError(t, err)
+		return lw
+	}
+
+	t.Run("extent count before bytes", func(t *testing.T) {
+		lw := newLogWriter(t)
+
+		// Tiny records reach the extent count long before the byte
+		// threshold.
+		for _, line := range []string{"a\n", "b\n"} {
+			_, err := lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), nil)
+
+		// An unterminated write is not a completed extent.
+		_, err := lw.Write([]byte("c"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), nil)
+
+		_, err = lw.Write([]byte("\n"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("a\nb\nc\n"))
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("bytes before extent count", func(t *testing.T) {
+		lw := newLogWriter(t)
+
+		large := strings.Repeat("x", 40) + "\n"
+		_, err := lw.Write([]byte(large))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), nil)
+
+		// The second large record reaches the byte threshold with
+		// only two extents in the buffer.
+		_, err = lw.Write([]byte(large))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(large+large))
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{Directory: t.TempDir(), FlushThreshold: -1})
+		ensureError(t, err, "negative flush threshold")
+		_, err = NewLogWriter(&Config{Directory: t.TempDir(), MaxPendingExtents: -1})
+		ensureError(t, err, "negative max pending extents")
+	})
+}
+
+func TestFlushTerminatedWrites(t *testing.T) {
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:        "terminated",
+		BufferSizeMax:         4096,
+		Directory:             t.TempDir(),
+		FlushTerminatedWrites: true,
+	})
+	ensureError(t, err)
+
+	// A terminated write reaches the log file without waiting for the
+	// buffer to fill.
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\n"))
+
+	// Fragments are batched until the write that terminates their line.
+	for _, fragment := range []string{"line", " 2"} {
+		_, err = lw.Write([]byte(fragment))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\n"))
+	}
+	if got, want := lw.Stats().BufferedBytes, 6; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = lw.Write([]byte("\nline 3\n"))
+	ensureError(t, err)
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nline 2\nline 3\n"))
+	if got, want := lw.Stats().BufferedBytes, 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, lw.Close())
+}
+
+func TestTimeOfFirstWrite(t *testing.T) {
+	formatter := func(t time.Time) string { return t.Format("150405") }
+
+	newLogWriter := func(t *testing.T, cfg *Config) (*LogWriter, *testClock) {
+		t.Helper()
+		clock := newTestClock()
+		cfg.BaseNamePrefix = "first"
+		cfg.TimeFormatter = formatter
+		lw, err := NewLogWriter(cfg)
+		ensureError(t, err)
+		setClock(lw, clock)
+		return lw, clock
+	}
+
+	write := func(t *testing.T, lw *LogWriter, s string) {
+		t.Helper()
+		_, err := lw.Write([]byte(s))
+		ensureError(t, err)
+	}
+
+	ensureArchives := func(t *testing.T, directory string, want ...string) {
+		t.Helper()
+		got := archivedLogs(t, directory, "first")
+		for i := range got {
+			got[i] = filepath.Base(got[i])
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	t.Run("rotate then write", func(t *testing.T) {
+		directory := t.TempDir()
+		lw, clock := newLogWriter(t, &Config{
+			BufferSizeMax: -1,
+			Directory:     directory,
+			MaxBytes:      10,
+		})
+
+		write(t, lw, "line 1\n") // 12:00:00
+		clock.Advance(time.Minute)
+		write(t, lw, "line 2\n") // 12:01:00 rotates first file
+		clock.Advance(time.Minute)
+		write(t, lw, "line 3\n") // 12:02:00 rotates second file
+		ensureError(t, lw.Close())
+
+		ensureArchives(t, directory, "first.120000.log", "first.120100.log")
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120100.log")), []byte("line 2\n"))
+	})
+
+	t.Run("buffered data flushed to new file", func(t *testing.T) {
+		directory := t.TempDir()
+		lw, clock := newLogWriter(t, &Config{
+			AllowTinyBuffer: true,
+			BufferSizeMax:   8,
+			Directory:       directory,
+			MaxBytes:        10,
+		})
+
+		write(t, lw, "line 1\n") // 12:00:00 buffered
+		clock.Advance(time.Minute)
+		write(t, lw, "line 2\n") // 12:01:00 flushes line 1 to first file
+		clock.Advance(time.Minute)
+		write(t, lw, "line 3\n") // 12:02:00 flushes line 2 to second file
+		clock.Advance(time.Minute)
+		ensureError(t, lw.Close()) // 12:03:00 flushes line 3 to third file
+
+		// Each file is named for the time buffered data was first
+		// written to it, rather than the time it was rotated.
+		ensureArchives(t, directory, "first.120100.log", "first.120200.log")
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120100.log")), []byte("line 1\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120200.log")), []byte("line 2\n"))
+	})
+
+	t.Run("buffered data spans rotation", func(t *testing.T) {
+		directory := t.TempDir()
+		lw, clock := newLogWriter(t, &Config{
+			BufferSizeMax:   64,
+			Directory:       directory,
+			MaxBytes:        14,
+			NameByWriteTime: true,
+		})
+
+		for i := 1; i <= 4; i++ {
+			write(t, lw, fmt.Sprintf("line %d\n", i)) // 12:00:00 through 12:03:00 buffered
+			clock.Advance(time.Minute)
+		}
+		ensureError(t, lw.Rotate()) // 12:04:00 flushes lines 1 and 2 to first file, and lines 3 and 4 to second file
+		ensureError(t, lw.Close())
+
+		// Each file is named for the time its first line was written
+		// to the buffer, rather than the time it was flushed.
+		ensureArchives(t, directory, "first.120000.log", "first.120200.log")
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120000.log")), []byte("line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120200.log")), []byte("line 3\nline 4\n"))
+	})
+
+	t.Run("idle close then write", func(t *testing.T) {
+		directory := t.TempDir()
+		lw, clock := newLogWriter(t, &Config{
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			IdleCloseAfter: time.Hour,
+			MaxBytes:       16,
+		})
+
+		write(t, lw, "line 1\n") // 12:00:00
+		clock.Advance(2 * time.Hour)
+		ensureError(t, lw.closeIfIdle())
+		write(t, lw, "line 2\n") // 14:00:00 reopens same file
+		clock.Advance(time.Minute)
+		write(t, lw, "line 3\n") // 14:01:00 rotates reopened file
+		ensureError(t, lw.Close())
+
+		ensureArchives(t, directory, "first.120000.log")
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120000.log")), []byte("line 1\nline 2\n"))
+	})
+
+	t.Run("rotate before writing", func(t *testing.T) {
+		directory := t.TempDir()
+		if err := os.WriteFile(filepath.Join(directory, "first.log"), []byte("existing\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		lw, clock := newLogWriter(t, &Config{
+			BufferSizeMax: -1,
+			Directory:     directory,
+			MaxBytes:      10,
+		})
+
+		clock.Advance(time.Minute)
+		write(t, lw, "line 1\n") // 12:01:00 rotates existing file
+		ensureError(t, lw.Close())
+
+		// The existing file was never written to by this LogWriter,
+		// so it is named for the time it was rotated.
+		ensureArchives(t, directory, "first.120100.log")
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "first.120100.log")), []byte("existing\n"))
+	})
+
+	t.Run("formatted only when rotated", func(t *testing.T) {
+		directory := t.TempDir()
+		var calls int
+		clock := newTestClock()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "first",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			TimeFormatter:  func(t time.Time) string { calls++; return formatter(t) },
+		})
+		ensureError(t, err)
+		setClock(lw, clock)
+		calls = 0
+
+		write(t, lw, "line 1\n") // 12:00:00
+		clock.Advance(time.Minute)
+		write(t, lw, "line 2\n") // 12:01:00
+		if got, want := calls, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		clock.Advance(time.Minute)
+		ensureError(t, lw.Rotate()) // 12:02:00
+		if got, want := calls, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, lw.Close())
+
+		ensureArchives(t, directory, "first.120000.log")
+	})
+}
+
+func TestRemoveEmptyOnClose(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:     "empty",
+			Directory:          directory,
+			RemoveEmptyOnClose: true,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		_, err = os.Stat(filepath.Join(directory, "empty.log"))
+		ensureError(t, err, "no such file")
+	})
+
+	t.Run("not empty", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:     "empty",
+			Directory:          directory,
+			RemoveEmptyOnClose: true,
+		})
+		ensureError(t, err)
+		_, err = lw.Write([]byte("unterminated"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "empty.log")), []byte("unterminated\n"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "empty",
+			Directory:      directory,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "empty.log")), nil)
+	})
+}
+
+func TestRotateOnMarker(t *testing.T) {
+	const marker = "=== RESTART ===\n"
+
+	run := func(t *testing.T, bufferSizeMax int) {
+		t.Helper()
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "marker",
+			BufferSizeMax:  bufferSizeMax,
+			Directory:      directory,
+			RotateOnMarker: []byte("=== RESTART"),
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{marker, "line 1\n", "line 2\n", marker, "line 3\n"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+
+		ensureError(t, lw.Close())
+
+		// The first marker is not rotated because the log file was
+		// empty.
+		archives := archivedLogs(t, directory, "marker")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte(marker+"line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "marker.log")), []byte(marker+"line 3\n"))
+	}
+
+	t.Run("buffered", func(t *testing.T) {
+		run(t, 1024)
+	})
+
+	t.Run("buffer overflow", func(t *testing.T) {
+		run(t, 20)
+	})
+
+	t.Run("unbuffered", func(t *testing.T) {
+		run(t, -1)
+	})
+}
+
+func TestLogWriterString(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "string",
+		BufferSizeMax:  64,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	_, err = lw.Write([]byte("line 1\npartial"))
+	ensureError(t, err)
+
+	got := fmt.Sprint(lw)
+	for _, want := range []string{
+		fmt.Sprintf("Path: %q", filepath.Join(directory, "string.log")),
+		"Size: 0",
+		"Buffer: 14/64 bytes",
+		"PendingExtents: 1",
+		"WaitingForNewline: true",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("GOT: %q; WANT: %q", got, want)
+		}
+	}
+
+	ensureError(t, lw.Close())
+}
+
+func TestIncludeSequence(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:  "sequence",
+		BufferSizeMax:   -1,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        10,
+		// Every rotation happens at the same time, so the timestamps
+		// alone would not distinguish the rotated files.
+		TimeFormatter: func(time.Time) string { return "20220322T120000" },
+	})
+	ensureError(t, err)
+
+	for i := 1; i <= 4; i++ {
+		_, err = fmt.Fprintf(lw, "line %d\n", i)
+		ensureError(t, err)
+	}
+
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "sequence")
+	if got, want := len(archives), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	for i, archive := range archives {
+		if got, want := filepath.Base(archive), fmt.Sprintf("sequence.20220322T120000.%06d.log", i+1); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archive), []byte(fmt.Sprintf("line %d\n", i+1)))
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+	run := func(t *testing.T, bufferSizeMax int) {
+		t.Helper()
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "batch",
+			BufferSizeMax:   bufferSizeMax,
+			Directory:       directory,
+			IncludeSequence: true,
+			MaxBytes:        16,
+		})
+		ensureError(t, err)
+
+		records := [][]byte{
+			[]byte("line 1\n"),
+			[]byte("line 2\n"),
+			[]byte("line 3\n"),
+			nil, // empty records are permitted
+			[]byte("line 4\n"),
+			[]byte("line 5\n"),
+		}
+
+		nw, err := lw.WriteBatch(records)
+		ensureError(t, err)
+		if got, want := nw, 35; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, directory, "batch")
+		if got, want := len(archives), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, archives[1]), []byte("line 3\nline 4\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "batch.log")), []byte("line 5\n"))
+	}
+
+	t.Run("buffered", func(t *testing.T) {
+		run(t, 64)
+	})
+
+	t.Run("unbuffered", func(t *testing.T) {
+		run(t, -1)
+	})
+}
+
+func TestRecordPrefixAndSuffix(t *testing.T) {
+	run := func(t *testing.T, bufferSizeMax int) {
+		t.Helper()
+		directory := t.TempDir()
+
+		var sequence int
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "record",
+			BufferSizeMax:  bufferSizeMax,
+			Directory:      directory,
+			MaxBytes:       32,
+			RecordPrefixFunc: func() []byte {
+				sequence++
+				return []byte(fmt.Sprintf("%d: ", sequence))
+			},
+			RecordSuffix: []byte("\n"),
+		})
+		ensureError(t, err)
+
+		for _, record := range []string{"alpha", "bravo", "charlie", "delta", "echo"} {
+			nw, err := lw.Write([]byte(record))
+			ensureError(t, err)
+			if got, want := nw, len(record); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		}
+
+		ensureError(t, lw.Close())
+
+		// Each record, including its prefix and suffix, is counted
+		// toward the size of the log file.
+		archives := archivedLogs(t, directory, "record")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("1: alpha\n2: bravo\n3: charlie\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "record.log")), []byte("4: delta\n5: echo\n"))
+	}
+
+	t.Run("buffered", func(t *testing.T) {
+		run(t, 64)
+	})
+
+	t.Run("unbuffered", func(t *testing.T) {
+		run(t, -1)
+	})
+}
+
+func TestRepairOnOpen(t *testing.T) {
+	cases := map[string]struct {
+		existing string
+		truncate bool
+		want     string
+	}{
+		"complete":          {existing: "line 1\npartial", want: "line 1\npartial\nline 2\n"},
+		"truncate":          {existing: "line 1\npartial", truncate: true, want: "line 1\nline 2\n"},
+		"truncate all":      {existing: "partial", truncate: true, want: "line 2\n"},
+		"ends with newline": {existing: "line 1\n", truncate: true, want: "line 1\nline 2\n"},
+		"empty":             {existing: "", want: "line 2\n"},
+		"long partial line": {existing: "line 1\n" + strings.Repeat("x", 10000), truncate: true, want: "line 1\nline 2\n"},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			directory := t.TempDir()
+			path := filepath.Join(directory, "repair.log")
+			ensureError(t, os.WriteFile(path, []byte(tc.existing), 0644))
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "repair",
+				BufferSizeMax:  -1,
+				Directory:      directory,
+				RepairOnOpen:   true,
+				RepairTruncate: tc.truncate,
+			})
+			ensureError(t, err)
+
+			_, err = lw.Write([]byte("line 2\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Close())
+
+			ensureBuffer(t, readFile(t, path), []byte(tc.want))
+		})
+	}
+
+	t.Run("missing", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "repair",
+			Directory:      directory,
+			RepairOnOpen:   true,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+	})
+}
+
+func TestAllowTinyBuffer(t *testing.T) {
+	t.Run("rejected by default", func(t *testing.T) {
+		for _, size := range []int{1, 2, 15} {
+			_, err := NewLogWriter(&Config{
+				BufferSizeMax: size,
+				Directory:     t.TempDir(),
+			})
+			ensureError(t, err, "AllowTinyBuffer")
+		}
+	})
+
+	t.Run("minimum allowed", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BufferSizeMax: 16,
+			Directory:     t.TempDir(),
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("escape hatch", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			AllowTinyBuffer: true,
+			BaseNamePrefix:  "tiny",
+			BufferSizeMax:   1,
+			Directory:       directory,
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{"line 1\n", "line 2\n"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "tiny.log")), []byte("line 1\nline 2\n"))
+	})
+}
+
+func TestOversizeUnterminated(t *testing.T) {
+	newWriter := func(t *testing.T, immediately bool) (*LogWriter, string) {
+		t.Helper()
+		directory := t.TempDir()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:           "oversize",
+			BufferSizeMax:            64,
+			Directory:                directory,
+			FlushOversizeImmediately: immediately,
+			IncludeSequence:          true,
+			MaxBytes:                 20,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+		return lw, directory
+	}
+
+	// fragments grow a single line well beyond both MaxBytes and
+	// BufferSizeMax before its newline arrives.
+	fragments := []string{"line 1\n", strings.Repeat("a", 30), strings.Repeat("b", 30), strings.Repeat("c", 30), "\n", "line 3\n"}
+	long := strings.Repeat("a", 30) + strings.Repeat("b", 30) + strings.Repeat("c", 30) + "\n"
+
+	t.Run("held until newline", func(t *testing.T) {
+		lw, directory := newWriter(t, false)
+
+		for _, fragment := range fragments[:4] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		// The growing line is never written while it may still grow,
+		// even when the buffer overflows, or it is flushed or rotated.
+		ensureError(t, lw.Flush())
+		ensureError(t, lw.Rotate())
+		if got, want := lw.Stats().BufferedBytes, 90; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		archives := archivedLogs(t, directory, "oversize")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+
+		for _, fragment := range fragments[4:] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		archives = archivedLogs(t, directory, "oversize")
+		if got, want := len(archives), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[1]), []byte(long))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "oversize.log")), []byte("line 3\n"))
+	})
+
+	t.Run("FlushOversizeImmediately", func(t *testing.T) {
+		lw, directory := newWriter(t, true)
+
+		for _, fragment := range fragments[:2] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		// The fragment outgrew a log file, so it begins its own.
+		if got, want := lw.Stats().BufferedBytes, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		archives := archivedLogs(t, directory, "oversize")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(strings.Repeat("a", 30)))
+
+		// The rest of the line follows it to the same log file, which
+		// is not rotated while the line is incomplete.
+		for _, fragment := range fragments[2:4] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Rotate())
+		if got, want := len(archivedLogs(t, directory, "oversize")), 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		for _, fragment := range fragments[4:] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		archives = archivedLogs(t, directory, "oversize")
+		if got, want := len(archives), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[1]), []byte(long))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "oversize.log")), []byte("line 3\n"))
+	})
+
+	t.Run("Close completes line", func(t *testing.T) {
+		lw, directory := newWriter(t, true)
+
+		for _, fragment := range fragments[:2] {
+			_, err := lw.Write([]byte(fragment))
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "oversize.log")), []byte(strings.Repeat("a", 30)+"\n"))
+	})
+}
+
+func TestMaxMemoryBytes(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:  "budget",
+		BufferSizeMax:   1024,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        1024,
+		MaxMemoryBytes:  32,
+		TailBufferSize:  8,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	ensureBounded := func(t *testing.T) {
+		t.Helper()
+		if got, limit := lw.Stats().BufferedBytes, 32-8; got > limit {
+			t.Errorf("GOT: %v; WANT: no more than %v", got, limit)
+		}
+	}
+
+	var want []byte
+	write := func(t *testing.T, s string) {
+		t.Helper()
+		_, err := lw.Write([]byte(s))
+		ensureError(t, err)
+		want = append(want, s...)
+		ensureBounded(t)
+	}
+
+	t.Run("complete lines", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			write(t, fmt.Sprintf("line %d\n", i))
+		}
+		// Lines that would exceed the budget were flushed synchronously.
+		if got := readFile(t, lw.CurrentFile()); len(got) == 0 {
+			t.Errorf("GOT: %q; WANT: lines written synchronously", got)
+		}
+	})
+
+	t.Run("long line in pieces", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			write(t, strings.Repeat(string(rune('a'+i)), 10))
+		}
+		write(t, "\n")
+		write(t, "line 10\n")
+	})
+
+	ensureError(t, lw.Close())
+
+	if got := archivedLogs(t, directory, "budget"); len(got) != 0 {
+		t.Errorf("GOT: %v; WANT: no rotations", got)
+	}
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "budget.log")), want)
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{Directory: t.TempDir(), MaxMemoryBytes: -1})
+		ensureError(t, err, "negative max memory bytes")
+		_, err = NewLogWriter(&Config{Directory: t.TempDir(), MaxMemoryBytes: 8, TailBufferSize: 8})
+		ensureError(t, err, "tail buffer size")
+	})
+}
+
+func TestBufferOverflow(t *testing.T) {
+	const overflow = "0123456789a\n" // does not fit after a 7 byte write
+
+	newWriter := func(t *testing.T) (*LogWriter, string) {
+		t.Helper()
+		directory := t.TempDir()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "overflow",
+			BufferSizeMax:  16,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+		return lw, filepath.Join(directory, "overflow.log")
+	}
+
+	t.Run("one terminated extent", func(t *testing.T) {
+		lw, path := newWriter(t)
+
+		_, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte(overflow))
+		ensureError(t, err)
+
+		// The terminated extent was flushed to make room.
+		ensureBuffer(t, readFile(t, path), []byte("line 1\n"))
+		if got, want := lw.Stats().BufferedBytes, len(overflow); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, path), []byte("line 1\n"+overflow))
+	})
+
+	t.Run("one unterminated extent", func(t *testing.T) {
+		lw, path := newWriter(t)
+
+		_, err := lw.Write([]byte("partial"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte(overflow))
+		ensureError(t, err)
+
+		// Nothing could be flushed, so the write completed the
+		// extent, which exceeds the buffer size.
+		ensureBuffer(t, readFile(t, path), nil)
+		stats := lw.Stats()
+		if got, want := stats.BufferedBytes, len("partial"+overflow); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := stats.BufferedExtents, 1; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// The next write flushes the now completed extent.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureBuffer(t, readFile(t, path), []byte("partial"+overflow))
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, path), []byte("partial"+overflow+"line 2\n"))
+	})
+
+	t.Run("terminated and unterminated extents", func(t *testing.T) {
+		lw, path := newWriter(t)
+
+		_, err := lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte("par"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte(overflow))
+		ensureError(t, err)
+
+		// Only the terminated extent was flushed, and the remainder
+		// of the line was appended to the unterminated one.
+		ensureBuffer(t, readFile(t, path), []byte("line 1\n"))
+
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, path), []byte("line 1\npar"+overflow))
+	})
+}
+
+// shortWriteFile is a logFile that writes at most max bytes of each
+// write to the file it wraps, until its budget of short writes is
+// exhausted.
+type shortWriteFile struct {
+	logFile
+	max    int
+	shorts int
+}
+
+func (f *shortWriteFile) Write(p []byte) (int, error) {
+	if f.shorts > 0 && len(p) > f.max {
+		f.shorts--
+		return f.logFile.Write(p[:f.max])
+	}
+	return f.logFile.Write(p)
+}
+
+func TestUseAfterClose(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 1024} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "closed",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+			})
+			ensureError(t, err)
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Close())
+
+			if err = lw.Close(); !errors.Is(err, fs.ErrClosed) {
+				t.Errorf("GOT: %v; WANT: %v", err, fs.ErrClosed)
+			}
+			if _, err = lw.Write([]byte("line 2\n")); !errors.Is(err, fs.ErrClosed) {
+				t.Errorf("GOT: %v; WANT: %v", err, fs.ErrClosed)
+			}
+			if err = lw.Close(); !errors.Is(err, fs.ErrClosed) {
+				t.Errorf("GOT: %v; WANT: %v", err, fs.ErrClosed)
+			}
+			ensureBuffer(t, readFile(t, filepath.Join(directory, "closed.log")), []byte("line 1\n"))
+		})
+	}
+}
+
+func TestCloseDrainsBuffer(t *testing.T) {
+	const want = "line 1\nline 2\nline 3\npartial\n"
+
+	t.Run("after short write", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "drain",
+			BufferSizeMax:  1024,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "partial"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+
+		// A short write in the middle of the second extent leaves
+		// residual completed and partial extents in the buffer.
+		lw.filePointer = &shortWriteFile{logFile: lw.filePointer, max: 10, shorts: 1}
+		ensureError(t, lw.Flush(), io.ErrShortWrite.Error())
+		if got, want := len(lw.buf), len(want)-1-10; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.extents, []int{4, 7, 7}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+		if len(lw.buf) != 0 || len(lw.extents) != 0 {
+			t.Errorf("GOT: %d bytes in %v extents; WANT: empty buffer", len(lw.buf), lw.extents)
+		}
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "drain.log")), []byte(want))
+	})
+
+	t.Run("retry after Close fails", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "drain",
+			BufferSizeMax:  1024,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+
+		for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "partial"} {
+			_, err = lw.Write([]byte(line))
+			ensureError(t, err)
+		}
+
+		lw.filePointer = &shortWriteFile{logFile: lw.filePointer, max: 10, shorts: 1}
+		ensureError(t, lw.Close(), io.ErrShortWrite.Error())
+		if len(lw.buf) == 0 {
+			t.Fatalf("GOT: empty buffer; WANT: residual data after failed Close")
+		}
+
+		// The log file was reopened with an ordinary file, so the
+		// second Close writes the residual data.
+		ensureError(t, lw.Close())
+		if len(lw.buf) != 0 || len(lw.extents) != 0 {
+			t.Errorf("GOT: %d bytes in %v extents; WANT: empty buffer", len(lw.buf), lw.extents)
+		}
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "drain.log")), []byte(want))
+	})
+}
+
+func TestRequireExisting(t *testing.T) {
+	t.Run("missing", func(t *testing.T) {
+		directory := t.TempDir()
+
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "provisioned",
+			Directory:       directory,
+			RequireExisting: true,
+		})
+		ensureError(t, err, "must already exist")
+
+		if _, err = os.Stat(filepath.Join(directory, "provisioned.log")); !os.IsNotExist(err) {
+			t.Errorf("GOT: %v; WANT: log file not created", err)
+		}
+	})
+
+	t.Run("existing", func(t *testing.T) {
+		directory := t.TempDir()
+		path := filepath.Join(directory, "provisioned.log")
+		ensureError(t, os.WriteFile(path, nil, 0644))
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "provisioned",
+			BufferSizeMax:   -1,
+			Directory:       directory,
+			RequireExisting: true,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+
+		// The replacement of the rotated log file is created.
+		ensureError(t, lw.Rotate())
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, directory, "provisioned")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+		ensureBuffer(t, readFile(t, path), []byte("line 2\n"))
+	})
+}
+
+func TestMaxBytesOverflow(t *testing.T) {
+	t.Run("invalid", func(t *testing.T) {
+		for _, maxBytes := range []int64{-1, maxMaxBytes + 1, math.MaxInt64} {
+			_, err := NewLogWriter(&Config{
+				Directory: t.TempDir(),
+				MaxBytes:  maxBytes,
+			})
+			ensureError(t, err, "max bytes", strconv.FormatInt(maxBytes, 10))
+		}
+	})
+
+	for _, bufferSizeMax := range []int{-1, 1024} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "overflow",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+				MaxBytes:       maxMaxBytes,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Flush())
+
+			// Pretend the open log file is so large that adding the
+			// size of the next write to it would wrap negative.
+			lw.fileSizeNow = math.MaxInt64 - 2
+
+			_, err = lw.Write([]byte("line 2\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Close())
+
+			if got, want := lw.stats.Rotations, int64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			archives := archivedLogs(t, directory, "overflow")
+			if got, want := len(archives), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+			ensureBuffer(t, readFile(t, filepath.Join(directory, "overflow.log")), []byte("line 2\n"))
+		})
+	}
+}
+
+func TestNoFollowSymlinks(t *testing.T) {
+	directory := t.TempDir()
+	target := filepath.Join(directory, "target")
+	ensureError(t, os.WriteFile(target, nil, 0644))
+	if err := os.Symlink(target, filepath.Join(directory, "linked.log")); err != nil {
+		t.Skip(err)
+	}
+
+	t.Run("followed", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "linked",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+		})
+		ensureError(t, err)
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, target), []byte("line 1\n"))
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "linked",
+			Directory:        directory,
+			NoFollowSymlinks: true,
+		})
+		ensureError(t, err, "symbolic link")
+		ensureBuffer(t, readFile(t, target), []byte("line 1\n"))
+	})
+
+	t.Run("regular file", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "regular",
+			BufferSizeMax:    -1,
+			Directory:        directory,
+			NoFollowSymlinks: true,
+		})
+		ensureError(t, err)
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "regular.log")), []byte("line 1\n"))
+	})
+}
+
+func TestStageActive(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "staged",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		StageActive:    true,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	staging := filepath.Join(directory, ".staged.log.partial")
+	if got, want := lw.CurrentFile(), staging; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+
+	// Nothing matching the names of log files exists while the log file
+	// is being written.
+	if matches, _ := filepath.Glob(filepath.Join(directory, "staged*.log")); len(matches) > 0 {
+		t.Errorf("GOT: %v; WANT: no log files", matches)
+	}
+
+	ensureError(t, lw.Rotate())
+	archives := archivedLogs(t, directory, "staged")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+	ensureBuffer(t, readFile(t, staging), []byte("line 2\n"))
+
+	_, err = NewLogWriter(&Config{Directory: directory, NoRotate: true, StageActive: true})
+	ensureError(t, err, "StageActive")
+}
+
+func TestSharedAppend(t *testing.T) {
+	directory := t.TempDir()
+
+	// Two LogWriters append to the same log file, just as two processes
+	// would.
+	clock := newTestClock()
+	newWriter := func() *LogWriter {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "shared",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			MaxBytes:       40,
+			SharedAppend:   true,
+		})
+		ensureError(t, err)
+		setClock(lw, clock)
+		return lw
+	}
+	writers := []*LogWriter{newWriter(), newWriter()}
+
+	want := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		line := fmt.Sprintf("line %d %02d\n", i%2, i)
+		want[line] = true
+		clock.Advance(time.Second)
+		_, err := writers[i%2].Write([]byte(line))
+		ensureError(t, err)
+	}
+	for _, lw := range writers {
+		ensureError(t, lw.Close())
+	}
+
+	// Each log file is rotated based on the combined size of the writes
+	// of both writers.
+	archives := archivedLogs(t, directory, "shared")
+	if got, want := len(archives), 4; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	got := make(map[string]bool)
+	for _, file := range append(archives, filepath.Join(directory, "shared.log")) {
+		contents := readFile(t, file)
+		if got, want := len(contents), 40; got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", file, got, want)
+		}
+		for _, line := range strings.SplitAfter(string(contents), "\n") {
+			if line != "" {
+				got[line] = true
+			}
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err := NewLogWriter(&Config{Directory: directory, SharedAppend: true})
+	ensureError(t, err, "BufferSizeMax is -1")
+}
+
+func BenchmarkBufferedWrite(b *testing.B) {
+	const total = 1 << 20 // 1 MiB
+
+	lines := bytes.SplitAfter(novel[:total], newline)
+	directory := b.TempDir()
+
+	b.ReportAllocs()
+	b.SetBytes(total)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "bench",
+			BufferSizeMax:  4096,
+			Directory:      directory,
+			MaxBytes:       256 * 1024,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, line := range lines {
+			if _, err = lw.Write(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err = lw.Close(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
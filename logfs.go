@@ -0,0 +1,293 @@
+package golw
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// FS returns a read-only view of the log directory that only includes
+// the files of this LogWriter: its open log file and the log files it
+// has rotated. The view satisfies fs.ReadDirFS and fs.StatFS, so it may
+// be used with fs.WalkDir, or served with http.FileServer(http.FS(...)).
+// Opening a file with a .gz extension returns a file that transparently
+// decompresses its contents, and its FileInfo reports the decompressed
+// size, which is determined by decompressing the entire file. The view
+// reads the directory each time it is used, so it always reflects the
+// current set of log files.
+func (lw *LogWriter) FS() fs.FS {
+	lw.lock()
+	defer lw.unlock()
+	return &logFS{
+		active:    activeName(&lw.cfg),
+		fsys:      os.DirFS(lw.cfg.Directory),
+		prefix:    lw.cfg.BaseNamePrefix,
+		separator: lw.cfg.NameSeparator,
+	}
+}
+
+// logFS is the fs.FS returned by FS.
+type logFS struct {
+	active    string // base name of the open log file
+	fsys      fs.FS
+	prefix    string
+	separator string // separator follows prefix in names of rotated log files
+}
+
+// includes returns true when name is the name of a file in the log
+// directory that belongs to the LogWriter.
+func (lfs *logFS) includes(name string) bool {
+	if name == lfs.active {
+		return true
+	}
+	if _, ok := trimNamePrefix(name, lfs.prefix, lfs.separator); !ok || strings.ContainsRune(name, '/') {
+		return false
+	}
+	return strings.HasSuffix(name, ".log") || strings.HasSuffix(name, ".log.gz")
+}
+
+// check returns an error when name is not a valid name of the root
+// directory, or of a file that belongs to the LogWriter.
+func (lfs *logFS) check(op, name string) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name != "." && !lfs.includes(name) {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return nil
+}
+
+// Open opens the named file, or the root directory when name is ".".
+func (lfs *logFS) Open(name string) (fs.File, error) {
+	if err := lfs.check("open", name); err != nil {
+		return nil, err
+	}
+
+	f, err := lfs.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "." {
+		return &logDir{File: f, lfs: lfs}, nil
+	}
+
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+		}
+		return &gzipFile{File: f, zr: zr, lfs: lfs, name: name, size: -1}, nil
+	}
+
+	return f, nil
+}
+
+// ReadDir returns the sorted entries of the root directory that belong
+// to the LogWriter.
+func (lfs *logFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		if err := lfs.check("readdir", name); err != nil {
+			return nil, err
+		}
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	entries, err := fs.ReadDir(lfs.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	return lfs.filter(entries), nil
+}
+
+// Stat returns the FileInfo of the named file, or the root directory
+// when name is ".".
+func (lfs *logFS) Stat(name string) (fs.FileInfo, error) {
+	if err := lfs.check("stat", name); err != nil {
+		return nil, err
+	}
+	info, err := fs.Stat(lfs.fsys, name)
+	if err != nil || !strings.HasSuffix(name, ".gz") {
+		return info, err
+	}
+	size, err := lfs.decompressedSize(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return gzipInfo{FileInfo: info, size: size}, nil
+}
+
+// decompressedSize returns the number of bytes the named compressed
+// file holds once decompressed.
+func (lfs *logFS) decompressedSize(name string) (int64, error) {
+	f, err := lfs.fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(io.Discard, zr)
+	if err2 := zr.Close(); err == nil {
+		err = err2
+	}
+	return size, err
+}
+
+// filter returns the entries that belong to the LogWriter.
+func (lfs *logFS) filter(entries []fs.DirEntry) []fs.DirEntry {
+	filtered := entries[:0]
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !lfs.includes(entry.Name()) {
+			continue
+		}
+		if strings.HasSuffix(entry.Name(), ".gz") {
+			entry = gzipEntry{DirEntry: entry, lfs: lfs}
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// logDir is the root directory of a logFS, whose entries are limited to
+// those that belong to the LogWriter.
+type logDir struct {
+	fs.File
+	lfs *logFS
+}
+
+// ReadDir returns the entries of the directory that belong to the
+// LogWriter, as described by fs.ReadDirFile.
+func (d *logDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rdf, ok := d.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: ".", Err: fs.ErrInvalid}
+	}
+	for {
+		entries, err := rdf.ReadDir(n)
+		entries = d.lfs.filter(entries)
+		// When every entry read was filtered out, read more entries
+		// rather than returning an empty slice without an error,
+		// which would look like the end of the directory.
+		if n <= 0 || len(entries) > 0 || err != nil {
+			return entries, err
+		}
+	}
+}
+
+// gzipEntry is the directory entry of a compressed log file, whose
+// FileInfo reports its decompressed size.
+type gzipEntry struct {
+	fs.DirEntry
+	lfs *logFS
+}
+
+func (e gzipEntry) Info() (fs.FileInfo, error) {
+	return e.lfs.Stat(e.Name())
+}
+
+// gzipInfo is the FileInfo of a compressed log file, which reports its
+// decompressed size, so that it agrees with the number of bytes read
+// from the file, such as the Content-Length http.FileServer sends.
+type gzipInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+func (fi gzipInfo) Size() int64 { return fi.size }
+
+// gzipFile is a compressed log file whose contents are decompressed as
+// they are read.
+type gzipFile struct {
+	fs.File
+	zr   *gzip.Reader
+	lfs  *logFS
+	name string
+	pos  int64 // pos is the offset of the next decompressed byte read
+	size int64 // size is the decompressed size, or -1 until determined
+}
+
+func (f *gzipFile) Read(p []byte) (int, error) {
+	n, err := f.zr.Read(p)
+	f.pos += int64(n)
+	return n, err
+}
+
+func (f *gzipFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if f.size < 0 {
+		if f.size, err = f.lfs.decompressedSize(f.name); err != nil {
+			f.size = -1
+			return nil, &fs.PathError{Op: "stat", Path: f.name, Err: err}
+		}
+	}
+	return gzipInfo{FileInfo: info, size: f.size}, nil
+}
+
+// Seek sets the offset of the next decompressed byte read, as described
+// by io.Seeker, so the file may be served with http.FileServer, which
+// seeks to detect its content type, and to serve byte ranges. Seeking
+// backwards decompresses the file again from its start, and seeking
+// forwards decompresses and discards the bytes skipped.
+func (f *gzipFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		info, err := f.Stat()
+		if err != nil {
+			return 0, err
+		}
+		offset += info.Size()
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset < f.pos {
+		rs, ok := f.File.(io.Seeker)
+		if !ok {
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errors.New("cannot seek backwards in compressed file")}
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if err := f.zr.Reset(f.File); err != nil {
+			return 0, &fs.PathError{Op: "seek", Path: f.name, Err: err}
+		}
+		f.pos = 0
+	}
+	if offset > f.pos {
+		n, err := io.CopyN(io.Discard, f.zr, offset-f.pos)
+		f.pos += n
+		if err == io.EOF {
+			// Seeking beyond the end is allowed, and later reads
+			// return io.EOF.
+			f.pos = offset
+		} else if err != nil {
+			return f.pos, err
+		}
+	}
+	return f.pos, nil
+}
+
+func (f *gzipFile) Close() error {
+	err := f.zr.Close()
+	if err2 := f.File.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
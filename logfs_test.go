@@ -0,0 +1,136 @@
+package golw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLogWriterFS(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "browse",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		TimeFormat:     DateTime,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Rotate())
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Flush())
+
+	// A compressed log file, and files that do not belong to this
+	// LogWriter.
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err = zw.Write([]byte("line 0\n"))
+	ensureError(t, err)
+	ensureError(t, zw.Close())
+	ensureError(t, os.WriteFile(filepath.Join(directory, "browse.2022-03-21T12-00-00.000Z.log.gz"), compressed.Bytes(), 0644))
+	ensureError(t, os.WriteFile(filepath.Join(directory, "other.log"), []byte("other\n"), 0644))
+	ensureError(t, os.WriteFile(filepath.Join(directory, "browse.txt"), []byte("notes\n"), 0644))
+
+	fsys := lw.FS()
+
+	archive := filepath.Base(archivedLogs(t, directory, "browse")[0])
+
+	ensureError(t, fstest.TestFS(fsys, "browse.log", archive, "browse.2022-03-21T12-00-00.000Z.log.gz"))
+
+	t.Run("walk", func(t *testing.T) {
+		var names []string
+		ensureError(t, fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				names = append(names, path)
+			}
+			return nil
+		}))
+		if got, want := len(names), 3; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", names, want)
+		}
+	})
+
+	t.Run("read backup", func(t *testing.T) {
+		buf, err := fs.ReadFile(fsys, archive)
+		ensureError(t, err)
+		ensureBuffer(t, buf, []byte("line 1\n"))
+	})
+
+	t.Run("read compressed backup", func(t *testing.T) {
+		buf, err := fs.ReadFile(fsys, "browse.2022-03-21T12-00-00.000Z.log.gz")
+		ensureError(t, err)
+		ensureBuffer(t, buf, []byte("line 0\n"))
+	})
+
+	t.Run("served over HTTP", func(t *testing.T) {
+		// Compress enough data that the decompressed size is much
+		// larger than the compressed size.
+		want := bytes.Repeat([]byte("line of a compressed log file\n"), 1000)
+		var compressed bytes.Buffer
+		zw := gzip.NewWriter(&compressed)
+		_, err := zw.Write(want)
+		ensureError(t, err)
+		ensureError(t, zw.Close())
+		name := "browse.2022-03-22T12-00-00.000Z.log.gz"
+		ensureError(t, os.WriteFile(filepath.Join(directory, name), compressed.Bytes(), 0644))
+		defer os.Remove(filepath.Join(directory, name))
+
+		server := httptest.NewServer(http.FileServer(http.FS(fsys)))
+		defer server.Close()
+
+		get := func(t *testing.T, header http.Header) (*http.Response, []byte) {
+			t.Helper()
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/"+name, nil)
+			ensureError(t, err)
+			for key, values := range header {
+				req.Header[key] = values
+			}
+			resp, err := http.DefaultClient.Do(req)
+			ensureError(t, err)
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			ensureError(t, err)
+			return resp, body
+		}
+
+		resp, body := get(t, nil)
+		if got, want := resp.StatusCode, http.StatusOK; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := resp.ContentLength, int64(len(want)); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, body, want)
+
+		resp, body = get(t, http.Header{"Range": {"bytes=30-59"}})
+		if got, want := resp.StatusCode, http.StatusPartialContent; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, body, want[30:60])
+	})
+
+	t.Run("other files excluded", func(t *testing.T) {
+		for _, name := range []string{"other.log", "browse.txt", "../browse.log"} {
+			if _, err := fsys.Open(name); err == nil {
+				t.Errorf("%q: GOT: %v; WANT: error", name, err)
+			}
+		}
+	})
+
+	ensureError(t, lw.Close())
+}
@@ -0,0 +1,83 @@
+package golw
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// metricsSnapshot is the JSON document returned by MetricsSnapshot.
+type metricsSnapshot struct {
+	CurrentFile string `json:"currentFile"`
+	MaxBytes    int64  `json:"maxBytes"`
+	BackupCount int    `json:"backupCount"`
+
+	Writes                 int64 `json:"writes"`
+	BytesWritten           int64 `json:"bytesWritten"`
+	Rotations              int64 `json:"rotations"`
+	BufferedBytes          int   `json:"bufferedBytes"`
+	BufferedExtents        int   `json:"bufferedExtents"`
+	FileSize               int64 `json:"fileSize"`
+	FileDescriptorHeadroom int64 `json:"fileDescriptorHeadroom"`
+	LockHoldTotalNanos     int64 `json:"lockHoldTotalNanos"`
+	LockHoldMaxNanos       int64 `json:"lockHoldMaxNanos"`
+}
+
+// MetricsSnapshot returns a JSON document describing the current Stats
+// of the LogWriter, along with its path of the current log file, its
+// MaxBytes, and the number of rotated log files in its directory, as a
+// single line suitable for serving from a monitoring endpoint or
+// appending to a JSON lines file. Durations are expressed in
+// nanoseconds. It is safe to invoke concurrently with other methods,
+// and only holds the lock while copying the statistics, not while
+// counting the rotated log files.
+func (lw *LogWriter) MetricsSnapshot() []byte {
+	lw.lock()
+	directory, prefix, separator := lw.cfg.Directory, lw.cfg.BaseNamePrefix, lw.cfg.NameSeparator
+	current := filepath.Join(directory, activeName(&lw.cfg))
+	snapshot := metricsSnapshot{
+		CurrentFile:        lw.filePath,
+		MaxBytes:           lw.cfg.MaxBytes,
+		Writes:             lw.stats.Writes,
+		BytesWritten:       lw.stats.BytesWritten,
+		Rotations:          lw.stats.Rotations,
+		BufferedBytes:      len(lw.buf),
+		BufferedExtents:    len(lw.extents),
+		FileSize:           lw.fileSizeNow,
+		LockHoldTotalNanos: int64(lw.stats.LockHoldTotal),
+		LockHoldMaxNanos:   int64(lw.stats.LockHoldMax),
+	}
+	lw.unlock()
+
+	snapshot.FileDescriptorHeadroom = fileDescriptorHeadroom()
+	snapshot.BackupCount = countBackups(directory, prefix, separator, current)
+
+	// Marshaling a struct of strings and numbers cannot fail.
+	buf, _ := json.Marshal(snapshot)
+	return buf
+}
+
+// countBackups returns the number of log files in directory rotated
+// from the log file with the specified base name prefix and name
+// separator, whether or not they are compressed, not counting the log
+// file at path current. Names whose prefix was shortened to respect
+// MaxNameBytes are counted as well.
+func countBackups(directory, prefix, separator, current string) int {
+	matches, err := filepath.Glob(filepath.Join(directory, "*"))
+	if err != nil {
+		return 0
+	}
+	var count int
+	for _, match := range matches {
+		if match == current {
+			continue
+		}
+		if _, ok := trimNamePrefix(filepath.Base(match), prefix, separator); !ok {
+			continue
+		}
+		if strings.HasSuffix(match, ".log") || strings.HasSuffix(match, ".log.gz") {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,52 @@
+package golw
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetricsSnapshot(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "metrics",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		MaxBytes:       1024,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Rotate())
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+
+	var snapshot map[string]interface{}
+	ensureError(t, json.Unmarshal(lw.MetricsSnapshot(), &snapshot))
+
+	for field, want := range map[string]interface{}{
+		"currentFile":     filepath.Join(directory, "metrics.log"),
+		"maxBytes":        1024.0,
+		"backupCount":     1.0,
+		"writes":          2.0,
+		"bytesWritten":    14.0,
+		"rotations":       1.0,
+		"bufferedBytes":   0.0,
+		"bufferedExtents": 0.0,
+		"fileSize":        7.0,
+	} {
+		if got := snapshot[field]; got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", field, got, want)
+		}
+	}
+	for _, field := range []string{"fileDescriptorHeadroom", "lockHoldTotalNanos", "lockHoldMaxNanos"} {
+		if _, ok := snapshot[field]; !ok {
+			t.Errorf("GOT: %v; WANT: field %q", snapshot, field)
+		}
+	}
+
+	ensureError(t, lw.Close())
+}
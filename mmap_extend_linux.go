@@ -0,0 +1,19 @@
+package golw
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// extendFile extends fp to length bytes, allocating storage for them so
+// that running out of space is reported here, rather than by a SIGBUS
+// when the memory mapping of the file is written. When the file system
+// does not support allocating storage, the file is extended without it.
+func extendFile(fp *os.File, length int64) error {
+	err := syscall.Fallocate(int(fp.Fd()), 0, 0, length)
+	if errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOSYS) {
+		return fp.Truncate(length)
+	}
+	return err
+}
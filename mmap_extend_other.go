@@ -0,0 +1,13 @@
+//go:build aix || darwin || dragonfly || freebsd || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd netbsd openbsd solaris
+
+package golw
+
+import "os"
+
+// extendFile extends fp to length bytes. The extension is sparse, so
+// running out of space is only detected once the memory mapping of the
+// file is written, which raises SIGBUS.
+func extendFile(fp *os.File, length int64) error {
+	return fp.Truncate(length)
+}
@@ -0,0 +1,14 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package golw
+
+import (
+	"os"
+)
+
+const mmapSupported = false
+
+// newMmapFile is never invoked on platforms without memory mapped
+// files, because NewLogWriter rejects the configuration.
+func newMmapFile(fp *os.File, _, _ int64) logFile { return fp }
@@ -0,0 +1,124 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package golw
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const (
+	mmapSupported = true
+	mmapChunkSize = 1 << 20 // 1 MiB
+)
+
+// mmapFile writes to a file by copying data into a shared memory
+// mapping of the file, extending the file and its mapping as needed.
+type mmapFile struct {
+	fp       *os.File
+	data     []byte // data is the mapping of the file, or nil before first write
+	size     int64  // size is the number of bytes in the file, not counting its extension
+	maxBytes int64  // maxBytes limits how far the file is extended ahead of writes
+}
+
+// newMmapFile returns a logFile that appends to fp, which is open for
+// reading and writing, and has size bytes.
+func newMmapFile(fp *os.File, size, maxBytes int64) logFile {
+	// The mapping is not created until the first write, because the
+	// file may be empty, and a zero-length mapping is not allowed.
+	return &mmapFile{fp: fp, size: size, maxBytes: maxBytes}
+}
+
+// Sync commits the written contents of the memory mapping, along with
+// the rest of the file, to stable storage, by writing the modified pages
+// of the mapping to the file with msync before invoking fsync.
+func (f *mmapFile) Sync() error {
+	if err := msync(f.data); err != nil {
+		return err
+	}
+	return f.fp.Sync()
+}
+
+// Close removes the memory mapping, truncates the file to remove its
+// unwritten extension, and closes the file. Removing a shared mapping
+// does not discard its modified pages, which the operating system
+// writes to the file on its own schedule, so they are not written here.
+func (f *mmapFile) Close() error {
+	debug("mmapFile.Close: size: %d; mapped: %d\n", f.size, len(f.data))
+	var err error
+
+	if f.data != nil {
+		err = syscall.Munmap(f.data)
+		f.data = nil
+		if err2 := f.fp.Truncate(f.size); err == nil {
+			err = err2
+		}
+	}
+
+	if err2 := f.fp.Close(); err == nil {
+		err = err2
+	}
+
+	return err
+}
+
+// Write copies p into the mapping at the end of the file, first
+// extending the file and its mapping when they are too small.
+func (f *mmapFile) Write(p []byte) (int, error) {
+	need := f.size + int64(len(p))
+
+	if need > int64(len(f.data)) {
+		if err := f.grow(need); err != nil {
+			return 0, err
+		}
+	}
+
+	copy(f.data[f.size:], p)
+	f.size = need
+
+	return len(p), nil
+}
+
+// grow extends the file and its mapping so at least need bytes may be
+// written to it.
+func (f *mmapFile) grow(need int64) error {
+	// Extend the file in whole chunks to amortize the cost of
+	// remapping it, but not much beyond the maximum file size,
+	// because the file will be rotated before it gets larger.
+	length := (need + mmapChunkSize - 1) / mmapChunkSize * mmapChunkSize
+	if need <= f.maxBytes && length > f.maxBytes {
+		length = f.maxBytes
+	}
+	if length > int64(^uint(0)>>1) {
+		return fmt.Errorf("cannot memory map file larger than maximum int: %d", length)
+	}
+
+	debug("mmapFile.grow: need: %d; length: %d\n", need, length)
+
+	if f.data != nil {
+		err := syscall.Munmap(f.data)
+		f.data = nil
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := extendFile(f.fp, length); err != nil {
+		// Remove any partial extension.
+		_ = f.fp.Truncate(f.size)
+		return err
+	}
+
+	data, err := syscall.Mmap(int(f.fp.Fd()), 0, int(length), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		// Remove the extension so the file is not left with trailing
+		// zero bytes.
+		_ = f.fp.Truncate(f.size)
+		return err
+	}
+
+	f.data = data
+	return nil
+}
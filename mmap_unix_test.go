@@ -0,0 +1,131 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package golw
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMmap(t *testing.T) {
+	run := func(t *testing.T, bufferSizeMax int, maxBytes int64, existing []byte) {
+		t.Helper()
+		directory := t.TempDir()
+		activePath := filepath.Join(directory, "mmap.log")
+
+		if len(existing) > 0 {
+			if err := os.WriteFile(activePath, existing, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "mmap",
+			BufferSizeMax:  bufferSizeMax,
+			Directory:      directory,
+			MaxBytes:       maxBytes,
+			Mmap:           true,
+		})
+		ensureError(t, err)
+
+		// End the input with a newline so Close need not append
+		// one.
+		input := novel[:bytes.LastIndexByte(novel[:64*1024], '\n')+1]
+		total := int64(len(input))
+
+		nw, err := io.CopyBuffer(lw, bytes.NewReader(input), make([]byte, 1024))
+		ensureError(t, err)
+		if got, want := nw, total; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+
+		var all []byte
+		for _, archive := range archivedLogs(t, directory, "mmap") {
+			buf := readFile(t, archive)
+			if bytes.IndexByte(buf, 0) >= 0 {
+				t.Errorf("GOT: %q contains zero bytes; WANT: none", archive)
+			}
+			all = append(all, buf...)
+		}
+		all = append(all, readFile(t, activePath)...)
+
+		if want := append(append([]byte(nil), existing...), input...); !bytes.Equal(all, want) {
+			t.Errorf("GOT: %d bytes; WANT: %d bytes", len(all), len(want))
+		}
+	}
+
+	t.Run("buffered", func(t *testing.T) {
+		run(t, 512, 4096, nil)
+	})
+
+	t.Run("unbuffered", func(t *testing.T) {
+		run(t, -1, 4096, nil)
+	})
+
+	t.Run("max bytes larger than chunk", func(t *testing.T) {
+		run(t, 4096, 3*mmapChunkSize/2, nil)
+	})
+
+	t.Run("writes larger than max bytes", func(t *testing.T) {
+		run(t, -1, 100, nil)
+	})
+
+	t.Run("short existing file", func(t *testing.T) {
+		run(t, 512, 4096, []byte("existing line\n"))
+	})
+
+	t.Run("empty existing file", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "mmap",
+			Directory:      directory,
+			Mmap:           true,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "mmap.log")), nil)
+	})
+}
+
+func BenchmarkMmap(b *testing.B) {
+	const total = 1 << 20 // 1 MiB
+
+	run := func(b *testing.B, mmap bool) {
+		directory := b.TempDir()
+		input := novel[:total]
+		buf := make([]byte, 32*1024)
+
+		b.SetBytes(total)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "bench",
+				BufferSizeMax:  4096,
+				Directory:      directory,
+				MaxBytes:       256 * 1024,
+				Mmap:           mmap,
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err = io.CopyBuffer(lw, bytes.NewReader(input), buf); err != nil {
+				b.Fatal(err)
+			}
+			if err = lw.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("buffered", func(b *testing.B) { run(b, false) })
+	b.Run("mmap", func(b *testing.B) { run(b, true) })
+}
@@ -0,0 +1,14 @@
+//go:build aix || netbsd || openbsd || solaris
+// +build aix netbsd openbsd solaris
+
+package golw
+
+// msync does nothing, because the syscall package provides no way to
+// invoke msync on these operating systems. Each of them caches file
+// contents in the same pages that back shared memory mappings of the
+// file, so the modified pages of a mapping are written by fsync of the
+// file, which mmapFile.Sync invokes, and by the kernel once the mapping
+// is removed.
+func msync(data []byte) error {
+	return nil
+}
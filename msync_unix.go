@@ -0,0 +1,22 @@
+//go:build darwin || dragonfly || freebsd || linux
+// +build darwin dragonfly freebsd linux
+
+package golw
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// msync writes the modified pages of the shared memory mapping data to
+// the file it maps, waiting until they are written.
+func msync(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MSYNC, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)), syscall.MS_SYNC)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package golw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMultiDestination(t *testing.T) {
+	t.Run("alternates directories", func(t *testing.T) {
+		root := t.TempDir()
+		first, second := filepath.Join(root, "disk1"), filepath.Join(root, "disk2")
+		ensureError(t, os.Mkdir(first, 0755))
+		ensureError(t, os.Mkdir(second, 0755))
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:   "striped",
+			BufferSizeMax:    -1,
+			IncludeSequence:  true,
+			MaxBytes:         10,
+			MultiDestination: []string{first, second},
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		var currentFiles []string
+		for i := 1; i <= 5; i++ {
+			currentFiles = append(currentFiles, filepath.Base(filepath.Dir(lw.CurrentFile())))
+			_, err = fmt.Fprintf(lw, "line %d\n", i)
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		if got, want := fmt.Sprint(currentFiles), "[disk1 disk1 disk2 disk1 disk2]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		// Line 2 rotates the first log file, so each directory holds
+		// two archived log files, each with a single line.
+		for directory, want := range map[string][]string{
+			first:  {"line 1\n", "line 3\n"},
+			second: {"line 2\n", "line 4\n"},
+		} {
+			archives := archivedLogs(t, directory, "striped")
+			if got, want := len(archives), len(want); got != want {
+				t.Fatalf("%s: GOT: %v; WANT: %v", directory, got, want)
+			}
+			for i, archive := range archives {
+				ensureBuffer(t, readFile(t, archive), []byte(want[i]))
+			}
+		}
+		ensureBuffer(t, readFile(t, filepath.Join(first, "striped.log")), []byte("line 5\n"))
+		if _, err = os.Stat(filepath.Join(second, "striped.log")); !os.IsNotExist(err) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrNotExist)
+		}
+	})
+
+	t.Run("empty directory", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{MultiDestination: []string{t.TempDir(), ""}})
+		ensureError(t, err, "empty MultiDestination")
+	})
+
+	t.Run("ConfigFromMap", func(t *testing.T) {
+		cfg, err := ConfigFromMap(map[string]string{
+			"MultiDestination": "/disk1" + string(os.PathListSeparator) + "/disk2",
+		})
+		ensureError(t, err)
+		if got, want := fmt.Sprint(cfg.MultiDestination), "[/disk1 /disk2]"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+}
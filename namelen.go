@@ -0,0 +1,55 @@
+package golw
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"unicode/utf8"
+)
+
+// nameHashSize is the number of hexadecimal digits of the hash of
+// BaseNamePrefix that ends a prefix shortened to respect MaxNameBytes.
+const nameHashSize = 8
+
+// prefixHash returns the tag that ends prefix once it is shortened: a
+// tilde followed by the leading digits of the hexadecimal SHA-256 hash
+// of the entire prefix, so that different prefixes that share leading
+// bytes are still shortened to different names.
+func prefixHash(prefix string) string {
+	sum := sha256.Sum256([]byte(prefix))
+	return "~" + hex.EncodeToString(sum[:nameHashSize/2])
+}
+
+// fitPrefix returns prefix, shortened when needed so that it followed
+// by rest is no longer than max bytes, and whether it was shortened. A
+// shortened prefix keeps as many of its leading bytes as fit, without
+// splitting a UTF-8 encoded rune, followed by the tag from prefixHash.
+// When not even the tag fits, prefix is returned unchanged, and the
+// file system rejects the name.
+func fitPrefix(prefix, rest string, max int) (string, bool) {
+	if max <= 0 || len(prefix)+len(rest) <= max {
+		return prefix, false
+	}
+	keep := max - len(rest) - 1 - nameHashSize
+	if keep < 0 {
+		return prefix, false
+	}
+	for keep > 0 && !utf8.RuneStart(prefix[keep]) {
+		keep--
+	}
+	return prefix[:keep] + prefixHash(prefix), true
+}
+
+// trimNamePrefix returns name without its leading prefix and separator,
+// whether prefix appears in full or as shortened by fitPrefix, and true
+// when name begins with either.
+func trimNamePrefix(name, prefix, separator string) (string, bool) {
+	if strings.HasPrefix(name, prefix+separator) {
+		return name[len(prefix)+len(separator):], true
+	}
+	tag := prefixHash(prefix) + separator
+	if i := strings.Index(name, tag); i >= 0 && strings.HasPrefix(prefix, name[:i]) {
+		return name[i+len(tag):], true
+	}
+	return "", false
+}
@@ -0,0 +1,108 @@
+package golw
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxNameBytes(t *testing.T) {
+	directory := t.TempDir()
+	long := strings.Repeat("x", 300)
+
+	var reported []error
+	newLogWriter := func(t *testing.T, prefix string) (*LogWriter, *testClock) {
+		t.Helper()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  prefix,
+			BufferSizeMax:   -1,
+			Directory:       directory,
+			IncludeSequence: true,
+			OnError:         func(err error) { reported = append(reported, err) },
+		})
+		ensureError(t, err)
+		clock := newTestClock()
+		setClock(lw, clock)
+		return lw, clock
+	}
+
+	// Two prefixes that only differ beyond the limit.
+	first, clock := newLogWriter(t, long+"a")
+	second, _ := newLogWriter(t, long+"b")
+	if first.CurrentFile() == second.CurrentFile() {
+		t.Fatalf("GOT: %v; WANT: unique names", first.CurrentFile())
+	}
+
+	var rotated []string
+	for i := 0; i < 3; i++ {
+		_, err := first.Write([]byte("line\n"))
+		ensureError(t, err)
+		ensureError(t, first.Rotate())
+		clock.Advance(time.Second)
+	}
+	_, err := second.Write([]byte("line\n"))
+	ensureError(t, err)
+	ensureError(t, second.Rotate())
+	ensureError(t, first.Close())
+	ensureError(t, second.Close())
+
+	// Each LogWriter reports the first shortened name only.
+	if got, want := len(reported), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", reported, want)
+	}
+	ensureError(t, reported[0], "BaseNamePrefix", "255 bytes")
+
+	entries, err := fs.ReadDir(first.FS(), ".")
+	ensureError(t, err)
+	for _, entry := range entries {
+		if got := len(entry.Name()); got > DefaultMaxNameBytes {
+			t.Errorf("%s: GOT: %v; WANT: at most %v bytes", entry.Name(), got, DefaultMaxNameBytes)
+		}
+		if entry.Name() != filepath.Base(first.CurrentFile()) {
+			rotated = append(rotated, entry.Name())
+		}
+	}
+	if got, want := len(rotated), 3; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if !sort.StringsAreSorted(rotated) {
+		t.Errorf("GOT: %q; WANT: sorted names", rotated)
+	}
+	for i, name := range rotated {
+		info, ok := first.ParseBackupName(name)
+		if !ok {
+			t.Fatalf("%s: GOT: %v; WANT: %v", name, ok, true)
+		}
+		if got, want := info.Sequence, i+1; got != want {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, got, want)
+		}
+		if _, ok = second.ParseBackupName(name); ok {
+			t.Errorf("%s: GOT: %v; WANT: %v", name, ok, false)
+		}
+	}
+
+	// Shortened names are decoded given the entire prefix, including
+	// once ClobberSuffix or compression lengthens them.
+	cfg := &Config{BaseNamePrefix: long + "a", ClobberPolicy: ClobberSuffix, IncludeSequence: true}
+	stem := strings.TrimSuffix(rotated[0], ".log")
+	for name, want := range map[string]BackupInfo{
+		rotated[0]:         {Sequence: 1},
+		stem + "-2.log":    {Sequence: 1, Suffix: 2},
+		rotated[0] + ".gz": {Sequence: 1, Compressed: true},
+		stem + "-2.log.gz": {Sequence: 1, Suffix: 2, Compressed: true},
+	} {
+		info, ok := ParseBackupName(cfg, name)
+		if !ok {
+			t.Fatalf("%s: GOT: %v; WANT: %v", name, ok, true)
+		}
+		if info.Sequence != want.Sequence || info.Suffix != want.Suffix || info.Compressed != want.Compressed || !info.Time.Equal(newTestClock().Now()) {
+			t.Errorf("%s: GOT: %#v; WANT: %#v", name, info, want)
+		}
+	}
+
+	_, err = NewLogWriter(&Config{Directory: directory, MaxNameBytes: -1})
+	ensureError(t, err, "max name bytes")
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package golw
+
+// oNoFollow is zero because this platform cannot open a file without
+// following a symbolic link, so openLog relies on os.Lstat instead.
+const oNoFollow = 0
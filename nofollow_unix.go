@@ -0,0 +1,9 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package golw
+
+import "syscall"
+
+// oNoFollow is the flag that causes opening a symbolic link to fail.
+const oNoFollow = syscall.O_NOFOLLOW
@@ -0,0 +1,53 @@
+package golw
+
+import (
+	"bufio"
+	"os"
+)
+
+// defaultOSBufferSize is the size of the bufio.Writer wrapping the log
+// file when OSBuffered is true and OSBufferSize is zero.
+const defaultOSBufferSize = 4096
+
+// osBufferedFile is a logFile that batches writes to fp in a
+// bufio.Writer, so many small writes result in few system calls.
+type osBufferedFile struct {
+	*bufio.Writer
+	fp *os.File
+}
+
+// newOSBufferedFile returns a logFile that buffers up to size bytes
+// before writing them to fp.
+func newOSBufferedFile(fp *os.File, size int) logFile {
+	return &osBufferedFile{Writer: bufio.NewWriterSize(fp, size), fp: fp}
+}
+
+// Sync writes the buffered data to the file, then commits the file to
+// stable storage.
+func (f *osBufferedFile) Sync() error {
+	if err := f.Flush(); err != nil {
+		return err
+	}
+	return f.fp.Sync()
+}
+
+// Close writes the buffered data to the file, then closes it. The file
+// is closed even when the buffered data cannot be written.
+func (f *osBufferedFile) Close() error {
+	err := f.Flush()
+	if err2 := f.fp.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// flushOSBuffer writes the data buffered by the bufio.Writer wrapping
+// the open log file, when OSBuffered is true, to the log file.
+func (lw *LogWriter) flushOSBuffer() error {
+	if f, ok := lw.filePointer.(*osBufferedFile); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
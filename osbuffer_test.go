@@ -0,0 +1,123 @@
+package golw
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestOSBuffered(t *testing.T) {
+	t.Run("flushed on rotate and close", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "osbuffered",
+			BufferSizeMax:  -1,
+			Directory:      directory,
+			OSBuffered:     true,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		path := filepath.Join(directory, "osbuffered.log")
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		// The write remains in the bufio.Writer.
+		ensureBuffer(t, readFile(t, path), nil)
+
+		ensureError(t, lw.Flush())
+		ensureBuffer(t, readFile(t, path), []byte("line 1\n"))
+
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+
+		_, err = lw.Write([]byte("line 3\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, directory, "osbuffered")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, path), []byte("line 3\n"))
+	})
+
+	t.Run("rotates by size", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "osbuffered",
+			BufferSizeMax:   -1,
+			Directory:       directory,
+			IncludeSequence: true,
+			MaxBytes:        16,
+			OSBuffered:      true,
+			OSBufferSize:    64,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		for i := 1; i <= 4; i++ {
+			_, err = fmt.Fprintf(lw, "line %d\n", i)
+			ensureError(t, err)
+		}
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, directory, "osbuffered")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\nline 2\n"))
+		ensureBuffer(t, readFile(t, filepath.Join(directory, "osbuffered.log")), []byte("line 3\nline 4\n"))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{Directory: t.TempDir(), OSBuffered: true})
+		ensureError(t, err, "BufferSizeMax is -1")
+
+		_, err = NewLogWriter(&Config{BufferSizeMax: -1, Directory: t.TempDir(), OSBuffered: true, OSBufferSize: -1})
+		ensureError(t, err, "negative OS buffer size")
+	})
+}
+
+func BenchmarkOSBuffered(b *testing.B) {
+	const total = 1 << 20 // 1 MiB
+
+	run := func(b *testing.B, cfg Config) {
+		cfg.BaseNamePrefix = "bench"
+		cfg.Directory = b.TempDir()
+		cfg.MaxBytes = 256 * 1024
+
+		input := novel[:total]
+		buf := make([]byte, 128)
+
+		b.SetBytes(total)
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			c := cfg
+			lw, err := NewLogWriter(&c)
+			if err != nil {
+				b.Fatal(err)
+			}
+			// Hide the WriteTo method of the reader, so the input is
+			// written in many small writes.
+			r := struct{ io.Reader }{bytes.NewReader(input)}
+			if _, err = io.CopyBuffer(lw, r, buf); err != nil {
+				b.Fatal(err)
+			}
+			if err = lw.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("buffered", func(b *testing.B) { run(b, Config{BufferSizeMax: 4096}) })
+	b.Run("unbuffered", func(b *testing.B) { run(b, Config{BufferSizeMax: -1}) })
+	b.Run("os buffered", func(b *testing.B) { run(b, Config{BufferSizeMax: -1, OSBuffered: true}) })
+}
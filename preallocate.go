@@ -0,0 +1,126 @@
+package golw
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// preparedLog is a log file created ahead of time by prepareNextLog.
+type preparedLog struct {
+	fp   *os.File
+	path string      // path is where the file was created
+	mode fs.FileMode // mode is the mode with which the file was created
+	flag int         // flag is the flags with which the file was opened
+	err  error
+}
+
+// nextLogPath returns the path of the file created ahead of time in
+// directory to replace the log file when it is rotated. Its name begins
+// with a period so it is hidden, and does not end with .log, so it is
+// not mistaken for a log file.
+func (lw *LogWriter) nextLogPath(directory string) string {
+	return filepath.Join(directory, "."+lw.cfg.BaseNamePrefix+".next")
+}
+
+// prepareNextLog creates the next log file in its own goroutine, unless
+// one is already being prepared, so it is ready for the next rotation.
+func (lw *LogWriter) prepareNextLog() {
+	if lw.nextLog != nil {
+		return
+	}
+
+	path, mode, flag := lw.nextLogPath(lw.upcomingDirectory()), lw.fileModeFor(lw.upcomingDirectory()), lw.openFlag()
+	next := make(chan preparedLog, 1)
+	lw.nextLog = next
+	if lw.tracing() {
+		lw.trace("prepareNextLog", "path", path)
+	}
+
+	go func() {
+		fp, err := os.OpenFile(path, flag|os.O_TRUNC, mode)
+		next <- preparedLog{fp: fp, path: path, mode: mode, flag: flag, err: err}
+	}()
+}
+
+// takeNextLog returns the prepared next log file, when it is ready and
+// still matches the configuration, and otherwise returns nil.
+func (lw *LogWriter) takeNextLog() *os.File {
+	if lw.nextLog == nil {
+		return nil
+	}
+
+	var prepared preparedLog
+	select {
+	case prepared = <-lw.nextLog:
+		lw.nextLog = nil
+	default:
+		debug("takeNextLog: next log file not yet ready\n")
+		return nil
+	}
+
+	if prepared.err != nil {
+		if lw.tracing() {
+			lw.trace("takeNextLog", "error", prepared.err)
+		}
+		return nil
+	}
+
+	if prepared.path != lw.nextLogPath(lw.logDirectory()) || prepared.mode != lw.fileModeFor(lw.logDirectory()) || prepared.flag != lw.openFlag() {
+		// Configuration changed after the file was prepared.
+		debug("takeNextLog: discarding next log file prepared with old configuration\n")
+		discardPreparedLog(prepared)
+		return nil
+	}
+
+	if err := os.Rename(prepared.path, lw.filePath); err != nil {
+		if lw.tracing() {
+			lw.trace("takeNextLog", "error", err)
+		}
+		discardPreparedLog(prepared)
+		return nil
+	}
+
+	return prepared.fp
+}
+
+// discardNextLog waits for the next log file being prepared, then
+// removes it.
+func (lw *LogWriter) discardNextLog() {
+	if lw.nextLog == nil {
+		return
+	}
+	prepared := <-lw.nextLog
+	lw.nextLog = nil
+	if prepared.err == nil {
+		discardPreparedLog(prepared)
+	}
+}
+
+func discardPreparedLog(prepared preparedLog) {
+	_ = prepared.fp.Close()
+	_ = os.Remove(prepared.path)
+}
+
+// openNextLog opens the log file after rotating it, using the prepared
+// next log file when it is ready, then prepares another one.
+func (lw *LogWriter) openNextLog() error {
+	if !lw.cfg.PreallocateNext {
+		return lw.reopenLog()
+	}
+
+	if fp := lw.takeNextLog(); fp != nil {
+		if lw.tracing() {
+			lw.trace("openNextLog", "path", lw.filePath, "prepared", true)
+		}
+		if err := lw.useLogFile(fp); err != nil {
+			lw.idleClosed = true
+			return err
+		}
+	} else if err := lw.reopenLog(); err != nil {
+		return err
+	}
+
+	lw.prepareNextLog()
+	return nil
+}
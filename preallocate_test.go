@@ -0,0 +1,83 @@
+package golw
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPreallocateNext(t *testing.T) {
+	directory := t.TempDir()
+	next := filepath.Join(directory, ".prealloc.next")
+
+	// waitForNext returns the FileInfo of the prepared next log file
+	// once it has been created.
+	waitForNext := func(t *testing.T) os.FileInfo {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			st, err := os.Stat(next)
+			if err == nil {
+				return st
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("GOT: %v; WANT: next log file prepared", err)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:  "prealloc",
+		BufferSizeMax:   -1,
+		Directory:       directory,
+		PreallocateNext: true,
+	})
+	ensureError(t, err)
+	clock := newTestClock()
+	setClock(lw, clock)
+
+	for i, line := range []string{"line 1\n", "line 2\n"} {
+		prepared := waitForNext(t)
+
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+
+		// The prepared file replaced the rotated log file.
+		current, err := os.Stat(lw.CurrentFile())
+		ensureError(t, err)
+		if !os.SameFile(prepared, current) {
+			t.Errorf("rotation %d: GOT: new file; WANT: prepared file", i+1)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	_, err = lw.Write([]byte("line 3\n"))
+	ensureError(t, err)
+	waitForNext(t)
+	ensureError(t, lw.Close())
+
+	if _, err = os.Stat(next); !os.IsNotExist(err) {
+		t.Errorf("GOT: %v; WANT: prepared file removed by Close", err)
+	}
+
+	archives := archivedLogs(t, directory, "prealloc")
+	if got, want := len(archives), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+	ensureBuffer(t, readFile(t, archives[1]), []byte("line 2\n"))
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 3\n"))
+}
+
+func TestPreallocateNextSharedAppend(t *testing.T) {
+	_, err := NewLogWriter(&Config{
+		BufferSizeMax:   -1,
+		Directory:       t.TempDir(),
+		PreallocateNext: true,
+		SharedAppend:    true,
+	})
+	ensureError(t, err, "SharedAppend", "PreallocateNext")
+}
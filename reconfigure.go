@@ -0,0 +1,174 @@
+package golw
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// Reconfigure applies the changes in cfg that do not require reopening
+// the log file, such as MaxBytes, BufferSizeMax, FlushOnBufferFull, and
+// the functions invoked by the LogWriter, without closing the open log
+// file or losing buffered writes. Like NewLogWriter, it stores the
+// default value of each field for which a default is chosen in cfg, and
+// returns an error when cfg specifies disallowed argument values. It
+// also returns an error, without applying any change, when cfg changes
+// a field that can only be set by NewLogWriter: BaseNamePrefix,
+// DirFileMode, Directory, FileMode, FrameMode, IdleCloseAfter,
+// IncludeByteRange, LingerDuration, MaxBytesBurst, MaxBytesPerSecond,
+// MaxNameBytes, Mmap, MultiDestination, OSBuffered, OSBufferSize,
+// SequenceStateFile, StageActive, or TamperEvident. A change to MaxBytes
+// takes effect with the next write, so when the open log file is
+// already larger than the new limit, it is rotated before that write. A
+// change to TailBufferSize retains as many of the most recently written
+// bytes as fit in the new size. When BufferSizeMax changes to -1, the
+// completed writes in the buffer are first written to the log file, and
+// the buffer is released. A final write that is not newline terminated
+// is then completed with a newline when CompleteLineOnUnbuffer is true,
+// and otherwise written to the log file as is, to be continued by the
+// next write.
+func (lw *LogWriter) Reconfigure(cfg *Config) error {
+	if cfg == nil {
+		cfg = new(Config)
+	}
+
+	customTimeFormatter := cfg.TimeFormatter != nil
+	timeFormatter, contentDefinedMask, err := resolveConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.tracing() {
+		lw.trace("Reconfigure")
+	}
+
+	var field string
+	switch {
+	case cfg.BaseNamePrefix != lw.cfg.BaseNamePrefix:
+		field = "BaseNamePrefix"
+	case cfg.Directory != lw.cfg.Directory:
+		field = "Directory"
+	case !equalFileModes(cfg.DirFileMode, lw.cfg.DirFileMode):
+		field = "DirFileMode"
+	case cfg.FileMode != lw.cfg.FileMode:
+		field = "FileMode"
+	case cfg.IdleCloseAfter != lw.cfg.IdleCloseAfter:
+		field = "IdleCloseAfter"
+	case cfg.IncludeByteRange != lw.cfg.IncludeByteRange:
+		field = "IncludeByteRange"
+	case cfg.LingerDuration != lw.cfg.LingerDuration:
+		field = "LingerDuration"
+	case cfg.MaxBytesBurst != lw.cfg.MaxBytesBurst:
+		field = "MaxBytesBurst"
+	case cfg.MaxBytesPerSecond != lw.cfg.MaxBytesPerSecond:
+		field = "MaxBytesPerSecond"
+	case cfg.MaxNameBytes != lw.cfg.MaxNameBytes:
+		field = "MaxNameBytes"
+	case cfg.FrameMode != lw.cfg.FrameMode:
+		field = "FrameMode"
+	case cfg.Mmap != lw.cfg.Mmap:
+		field = "Mmap"
+	case !equalStrings(cfg.MultiDestination, lw.cfg.MultiDestination):
+		field = "MultiDestination"
+	case cfg.OSBuffered != lw.cfg.OSBuffered:
+		field = "OSBuffered"
+	case cfg.OSBufferSize != lw.cfg.OSBufferSize:
+		field = "OSBufferSize"
+	case cfg.SequenceStateFile != lw.cfg.SequenceStateFile:
+		field = "SequenceStateFile"
+	case cfg.StageActive != lw.cfg.StageActive:
+		field = "StageActive"
+	case cfg.TamperEvident != lw.cfg.TamperEvident:
+		field = "TamperEvident"
+	}
+	if field != "" {
+		return fmt.Errorf("cannot reconfigure %s without creating a new LogWriter", field)
+	}
+
+	if (cfg.BufferSizeMax > 0) != (lw.cfg.BufferSizeMax > 0) {
+		if err = lw.switchBuffering(cfg); err != nil {
+			return err
+		}
+	}
+
+	lw.cfg = *cfg
+	lw.cfg.TimeFormatter = timeFormatter
+	lw.customTimeFormatter = customTimeFormatter
+	lw.contentDefinedMask = contentDefinedMask
+	lw.resizeTail(cfg.TailBufferSize)
+
+	return nil
+}
+
+// switchBuffering starts or stops buffering writes in memory, as cfg
+// requires, while the lock is held and before cfg is applied. When it
+// stops buffering, it first writes the entire buffer to the log file,
+// and returns an error without stopping when it cannot.
+func (lw *LogWriter) switchBuffering(cfg *Config) error {
+	if cfg.BufferSizeMax > 0 {
+		if lw.tracing() {
+			lw.trace("switchBuffering", "bufferSizeMax", cfg.BufferSizeMax)
+		}
+		lw.buf = make([]byte, 0, cfg.BufferSizeMax)
+		if lw.cfg.LingerDuration > 0 {
+			lw.lingerTimer = time.AfterFunc(lw.cfg.LingerDuration, lw.flushAfterLinger)
+			lw.lingerTimer.Stop() // started by next Write
+		}
+		if cfg.WarnOnLeak {
+			lw.warnOnLeak()
+		}
+		return nil
+	}
+
+	if lw.tracing() {
+		lw.trace("switchBuffering", "buffered", len(lw.buf))
+	}
+	if lw.oversizeOpen {
+		// The line is already being written directly to the log file,
+		// where unbuffered writes continue it.
+		if cfg.CompleteLineOnUnbuffer {
+			if err := lw.ensureLogOpen(); err != nil {
+				return err
+			}
+			if _, err := lw.writeOversizeContinuation(lw.lineEnding()); err != nil {
+				return err
+			}
+			lw.commitChain()
+		}
+		lw.oversizeOpen = false
+	}
+	if lw.waitingForNewline && cfg.CompleteLineOnUnbuffer {
+		ending := lw.lineEnding()
+		lw.buf = append(lw.buf, ending...)
+		lw.extents[len(lw.extents)-1] += len(ending)
+		lw.commitChain()
+		lw.waitingForNewline = false
+	}
+	if err := lw.flush(); err != nil {
+		return err
+	}
+	if len(lw.buf) > 0 {
+		// Only the final write, which is not newline terminated,
+		// remains.
+		if err := lw.ensureLogOpen(); err != nil {
+			return err
+		}
+		if _, err := lw.writeExtents(len(lw.extents), len(lw.buf)); err != nil {
+			return err
+		}
+	}
+
+	lw.buf, lw.extents, lw.writeTimes, lw.waitingForNewline = nil, nil, nil, false
+	if lw.lingerTimer != nil {
+		lw.lingerTimer.Stop()
+		lw.lingerTimer = nil
+	}
+	if lw.cfg.WarnOnLeak {
+		// No buffered data remains to be lost.
+		runtime.SetFinalizer(lw, nil)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package golw
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReconfigure(t *testing.T) {
+	directory := t.TempDir()
+	clock := newTestClock()
+
+	cfg := &Config{
+		BaseNamePrefix:  "reconfigure",
+		BufferSizeMax:   64,
+		Directory:       directory,
+		IncludeSequence: true,
+		MaxBytes:        1024,
+	}
+	lw, err := NewLogWriter(cfg)
+	ensureError(t, err)
+	setClock(lw, clock)
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n"} {
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+	}
+
+	t.Run("rejects changes requiring reopen", func(t *testing.T) {
+		for field, change := range map[string]func(*Config){
+			"Directory":      func(c *Config) { c.Directory = t.TempDir() },
+			"BaseNamePrefix": func(c *Config) { c.BaseNamePrefix = "other" },
+			"FileMode":       func(c *Config) { c.FileMode = 0600 },
+		} {
+			changed := *cfg
+			change(&changed)
+			ensureError(t, lw.Reconfigure(&changed), field)
+		}
+		if got, want := lw.cfg.MaxBytes, int64(1024); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	// Lower the size limit, while the log file and buffer hold data.
+	changed := *cfg
+	changed.MaxBytes = 14
+	ensureError(t, lw.Reconfigure(&changed))
+
+	// Buffered writes were not lost, and the open log file was not
+	// replaced.
+	if got, want := lw.Stats().BufferedBytes, 21; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	for _, line := range []string{"line 4\n", "line 5\n"} {
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Close())
+
+	// Every log file respects the new limit.
+	archives := archivedLogs(t, directory, "reconfigure")
+	if got, want := len(archives), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\nline 2\n"))
+	ensureBuffer(t, readFile(t, archives[1]), []byte("line 3\nline 4\n"))
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 5\n"))
+}
+
+func TestReconfigureBuffering(t *testing.T) {
+	for _, complete := range []bool{false, true} {
+		t.Run(fmt.Sprintf("CompleteLineOnUnbuffer %t", complete), func(t *testing.T) {
+			directory := t.TempDir()
+			cfg := &Config{
+				BaseNamePrefix:         "buffering",
+				BufferSizeMax:          64,
+				CompleteLineOnUnbuffer: complete,
+				Directory:              directory,
+			}
+			lw, err := NewLogWriter(cfg)
+			ensureError(t, err)
+
+			for _, s := range []string{"line 1\n", "line 2\n", "line 3"} {
+				_, err = lw.Write([]byte(s))
+				ensureError(t, err)
+			}
+
+			unbuffered := *cfg
+			unbuffered.BufferSizeMax = -1
+			ensureError(t, lw.Reconfigure(&unbuffered))
+
+			// The entire buffer was written and released.
+			want := "line 1\nline 2\nline 3"
+			if complete {
+				want += "\n"
+			}
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(want))
+			if got, want := lw.Stats().BufferedBytes, 0; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			// Later writes go straight to the log file.
+			_, err = lw.Write([]byte("line 4\n"))
+			ensureError(t, err)
+			want += "line 4\n"
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(want))
+
+			// Buffering can be enabled again.
+			ensureError(t, lw.Reconfigure(cfg))
+			_, err = lw.Write([]byte("line 5\n"))
+			ensureError(t, err)
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(want))
+			ensureError(t, lw.Close())
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte(want+"line 5\n"))
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// callSafely invokes fn, which invokes a function provided by the
+// user, and returns an error describing any panic it raises, rather
+// than letting a buggy callback crash the program from within a
+// method of the LogWriter.
+func callSafely(name string, fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot invoke %s without panic: %v", name, r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// reportError invokes OnError with err, when OnError is set.
+func (lw *LogWriter) reportError(err error) {
+	reportError(lw.cfg.OnError, err)
+}
+
+// reportError invokes onError with err, when onError is not nil. A
+// panic raised by onError is discarded, because there is nowhere left
+// to report it.
+func reportError(onError func(error), err error) {
+	if onError != nil {
+		_ = callSafely("OnError", func() { onError(err) })
+	}
+}
+
+// formatTime returns t formatted by TimeFormatter. When TimeFormatter
+// panics, or returns the empty string, which would produce malformed
+// rotated log file names that collide with each other, the problem is
+// reported to OnError, and t is formatted with the default formatter
+// instead.
+func (lw *LogWriter) formatTime(t time.Time) string {
+	var s string
+	if err := callSafely("TimeFormatter", func() { s = lw.cfg.TimeFormatter(t) }); err != nil {
+		lw.reportError(err)
+		return nanoDateTimeFormatter(t)
+	}
+	if s == "" {
+		lw.reportError(errors.New("cannot use empty time stamp from TimeFormatter, so used default format"))
+		return nanoDateTimeFormatter(t)
+	}
+	return s
+}
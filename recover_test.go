@@ -0,0 +1,110 @@
+package golw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCallbackPanics(t *testing.T) {
+	t.Run("subscriber", func(t *testing.T) {
+		directory := t.TempDir()
+
+		var reported []error
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:  "panic",
+			BufferSizeMax:   -1,
+			Directory:       directory,
+			IncludeSequence: true,
+			MaxBytes:        10,
+			OnError:         func(err error) { reported = append(reported, err) },
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		var delivered int
+		lw.Subscribe(func(RotationEvent) { panic("subscriber bug") })
+		lw.Subscribe(func(RotationEvent) { delivered++ })
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		if got, want := len(reported), 2; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureError(t, reported[0], "subscriber", "panic", "subscriber bug")
+		if got, want := delivered, 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := len(archivedLogs(t, directory, "panic")), 2; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("functions invoked with lock held", func(t *testing.T) {
+		directory := t.TempDir()
+
+		// NewLogWriter validates the time formatter by invoking it, so
+		// only panic after that.
+		var formatted int
+
+		var reported []error
+		lw, err := NewLogWriter(&Config{
+			ArchiveDirFunc:   func(string, int) string { panic("archive bug") },
+			BaseNamePrefix:   "panic",
+			BufferSizeMax:    -1,
+			Directory:        directory,
+			FileFooterFunc:   func(FileState) []byte { panic("footer bug") },
+			OnError:          func(err error) { reported = append(reported, err); panic("OnError bug") },
+			PostRotate:       func(string) (string, error) { panic("post rotate bug") },
+			RecordPrefixFunc: func() []byte { panic("prefix bug") },
+			TimeFormatter: func(t time.Time) string {
+				if formatted++; formatted > 1 {
+					panic("formatter bug")
+				}
+				return nanoDateTimeFormatter(t)
+			},
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		ensureError(t, lw.Close())
+
+		var got []string
+		for _, err := range reported {
+			got = append(got, err.Error())
+		}
+		for i, want := range []string{"RecordPrefixFunc", "FileFooterFunc", "TimeFormatter", "ArchiveDirFunc", "PostRotate"} {
+			if i >= len(reported) {
+				t.Fatalf("GOT: %q; WANT: %s", got, want)
+			}
+			ensureError(t, reported[i], want, "panic")
+		}
+
+		// The rotated log file was archived in Directory using the
+		// default time format, without a prefix or footer.
+		archives := archivedLogs(t, directory, "panic")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := filepath.Base(archives[0]), "panic."+nanoDateTimeFormatter(newTestClock().Now())+".log"; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+	})
+
+	t.Run("time formatter rejected", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			Directory:     t.TempDir(),
+			TimeFormatter: func(time.Time) string { panic("formatter bug") },
+		})
+		ensureError(t, err, "TimeFormatter", "formatter bug")
+	})
+}
@@ -0,0 +1,56 @@
+package golw
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// recoveryPath returns the path of the file to which RecoverOnFlushError
+// writes the completed extents the LogWriter cannot flush.
+func (lw *LogWriter) recoveryPath() string {
+	return filepath.Join(lw.logDirectory(), lw.cfg.BaseNamePrefix+".recovery")
+}
+
+// recoverBuffer appends the completed extents remaining in the buffer
+// after flushErr prevented writing them to the log file, to the recovery
+// file, and discards them from the buffer when they are all written.
+// Either way, the outcome is reported to OnError.
+func (lw *LogWriter) recoverBuffer(flushErr error) {
+	extentCount := len(lw.extents)
+	if lw.waitingForNewline {
+		extentCount--
+	}
+	var byteCount int
+	for _, extent := range lw.extents[:extentCount] {
+		byteCount += extent
+	}
+	if byteCount == 0 {
+		return
+	}
+
+	path := lw.recoveryPath()
+	if lw.tracing() {
+		lw.trace("recoverBuffer", "path", path, "bytes", byteCount, "error", flushErr)
+	}
+
+	fp, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, lw.fileModeFor(filepath.Dir(path)))
+	if err == nil {
+		_, err = fp.Write(lw.buf[:byteCount])
+		if err == nil {
+			err = fp.Sync()
+		}
+		if err2 := fp.Close(); err == nil {
+			err = err2
+		}
+	}
+	if err != nil {
+		lw.reportError(fmt.Errorf("cannot write %d unflushed bytes to recovery file after %v: %w", byteCount, flushErr, err))
+		return
+	}
+
+	lw.buf = lw.buf[:copy(lw.buf, lw.buf[byteCount:])]
+	lw.discardExtents(extentCount)
+	lw.stats.BytesMoved += int64(byteCount)
+	lw.reportError(fmt.Errorf("wrote %d unflushed bytes to recovery file %q after flush error: %w", byteCount, path, flushErr))
+}
@@ -0,0 +1,73 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// writeFailingFile is a logFile that fails every write, as when the
+// file system holding the log file is full.
+type writeFailingFile struct {
+	logFile
+}
+
+func (f writeFailingFile) Write([]byte) (int, error) {
+	return 0, errors.New("injected write failure")
+}
+
+func TestRecoverOnFlushError(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("RecoverOnFlushError %t", enabled), func(t *testing.T) {
+			directory := t.TempDir()
+
+			var reported []error
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:      "recovered",
+				BufferSizeMax:       64,
+				Directory:           directory,
+				OnError:             func(err error) { reported = append(reported, err) },
+				RecoverOnFlushError: enabled,
+			})
+			ensureError(t, err)
+
+			for _, line := range []string{"line 1\n", "line 2\n", "partial"} {
+				_, err = lw.Write([]byte(line))
+				ensureError(t, err)
+			}
+
+			healthy := lw.filePointer
+			lw.filePointer = writeFailingFile{healthy}
+			ensureError(t, lw.Flush(), "injected write failure")
+			lw.filePointer = healthy
+
+			recovery := filepath.Join(directory, "recovered.recovery")
+			if !enabled {
+				if got, want := lw.Stats().BufferedBytes, 21; got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+				if got := archivedLogs(t, directory, "recovered"); len(got) != 0 {
+					t.Errorf("GOT: %v; WANT: no rotated log files", got)
+				}
+				ensureError(t, lw.Close())
+				ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nline 2\npartial\n"))
+				return
+			}
+
+			// The completed writes were moved to the recovery file, and
+			// the final incomplete one remains buffered.
+			ensureBuffer(t, readFile(t, recovery), []byte("line 1\nline 2\n"))
+			if got, want := lw.Stats().BufferedBytes, 7; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(reported), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureError(t, reported[0], "recovery file", "injected write failure")
+
+			ensureError(t, lw.Close())
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("partial\n"))
+		})
+	}
+}
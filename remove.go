@@ -0,0 +1,49 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// removeProduced removes the log file, along with the rotated log files
+// this LogWriter produced and their sidecar metadata files, while the
+// lock is held after the log file is closed. It removes as many files
+// as it can, and returns the first error.
+func (lw *LogWriter) removeProduced() error {
+	if lw.tracing() {
+		lw.trace("removeProduced", "path", lw.filePath, "rotated", len(lw.produced))
+	}
+
+	var first error
+	remove := func(path string) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) && first == nil {
+			first = fmt.Errorf("cannot remove log file: %w", err)
+		}
+	}
+
+	remove(lw.filePath)
+	for _, path := range lw.produced {
+		if !lw.isProducedName(filepath.Base(path)) {
+			if lw.tracing() {
+				lw.trace("removeProduced", "skipped", path)
+			}
+			continue
+		}
+		remove(path)
+		remove(path + sidecarMetaExtension)
+	}
+	lw.produced = nil
+
+	return first
+}
+
+// isProducedName returns true when name is the name of a file that may
+// be a rotated log file produced by this LogWriter.
+func (lw *LogWriter) isProducedName(name string) bool {
+	_, ok := trimNamePrefix(name, lw.cfg.BaseNamePrefix, lw.cfg.NameSeparator)
+	return (ok || strings.HasPrefix(name, lw.cfg.BaseNamePrefix)) && strings.HasSuffix(name, ".log")
+}
@@ -0,0 +1,72 @@
+package golw
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestRemoveOnClose(t *testing.T) {
+	directory := t.TempDir()
+
+	// Files this LogWriter did not produce are never removed.
+	for _, name := range []string{"other.log", "scratch.previous.log"} {
+		if err := os.WriteFile(filepath.Join(directory, name), []byte("keep\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var rotations int
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:   "scratch",
+		BufferSizeMax:    -1,
+		Directory:        directory,
+		IncludeSequence:  true,
+		MaxBytes:         16,
+		RemoveOnClose:    true,
+		WriteSidecarMeta: true,
+		PostRotate: func(path string) (string, error) {
+			rotations++
+			if rotations > 1 {
+				return "", nil
+			}
+			// Move the first rotated log file to a name this
+			// LogWriter would not produce.
+			moved := filepath.Join(directory, "moved.txt")
+			return moved, os.Rename(path, moved)
+		},
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n", "line 5\n"} {
+		_, err = lw.Write([]byte(line))
+		ensureError(t, err)
+	}
+	ensureError(t, lw.Rotate())
+	if got := len(archivedLogs(t, directory, "scratch")); got < 2 {
+		t.Fatalf("GOT: %v; WANT: at least 2 rotated log files", got)
+	}
+
+	ensureError(t, lw.Close())
+
+	entries, err := os.ReadDir(directory)
+	ensureError(t, err)
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	sort.Strings(got)
+
+	want := []string{"moved.txt", "moved.txt.meta.json", "other.log", "scratch.previous.log"}
+	if len(got) != len(want) {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+			break
+		}
+	}
+}
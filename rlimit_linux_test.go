@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package golw
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRotateWithoutFileDescriptors(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "rlimit",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	if got := lw.Stats().FileDescriptorHeadroom; got <= 0 {
+		t.Errorf("GOT: %v; WANT: positive headroom", got)
+	}
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+
+	var original syscall.Rlimit
+	ensureError(t, syscall.Getrlimit(syscall.RLIMIT_NOFILE, &original))
+
+	// Lower the soft limit so the descriptor of the open log file,
+	// which is the lowest one available once it is closed, cannot be
+	// reused to open its replacement.
+	lowered := original
+	lowered.Cur = uint64(lw.filePointer.(*os.File).Fd())
+	ensureError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &lowered))
+
+	if got, want := lw.Stats().FileDescriptorHeadroom, int64(0); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	err = lw.Rotate()
+	ensureError(t, syscall.Setrlimit(syscall.RLIMIT_NOFILE, &original))
+	if err == nil {
+		t.Fatalf("GOT: %v; WANT: error opening new log file", err)
+	}
+
+	// Once file descriptors are available, the next write opens the
+	// new log file.
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "rlimit")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 2\n"))
+}
@@ -0,0 +1,6 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package golw
+
+func fileDescriptorHeadroom() int64 { return -1 }
@@ -0,0 +1,47 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+// +build darwin dragonfly freebsd linux netbsd openbsd
+
+package golw
+
+import (
+	"errors"
+	"math"
+	"os"
+	"syscall"
+)
+
+// fileDescriptorHeadroom returns the number of additional file
+// descriptors the process may open before reaching its soft limit, or
+// -1 when it cannot be determined.
+func fileDescriptorHeadroom() int64 {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return -1
+	}
+
+	limit := int64(rlimit.Cur)
+	if limit < 0 {
+		return math.MaxInt64 // no limit
+	}
+
+	fp, err := os.Open("/dev/fd")
+	if err != nil {
+		if errors.Is(err, syscall.EMFILE) {
+			return 0 // cannot even open the list of file descriptors
+		}
+		return -1
+	}
+	names, err := fp.Readdirnames(-1)
+	_ = fp.Close()
+	if err != nil {
+		return -1
+	}
+
+	// The list of open file descriptors includes the one used to read
+	// the list.
+	headroom := limit - int64(len(names)-1)
+	if headroom < 0 {
+		return 0
+	}
+	return headroom
+}
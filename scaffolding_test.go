@@ -4,7 +4,10 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"testing"
+	"time"
 )
 
 var tempdir string
@@ -45,3 +48,47 @@ func TestMain(m *testing.M) {
 
 	code = m.Run()
 }
+
+// archivedLogs returns the sorted list of paths to log files in
+// directory that have been rotated from the log file with the
+// specified base name prefix.
+func archivedLogs(tb testing.TB, directory, prefix string) []string {
+	tb.Helper()
+	matches, err := filepath.Glob(filepath.Join(directory, prefix+".*.log"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// readFile returns the contents of the file at path.
+func readFile(tb testing.TB, path string) []byte {
+	tb.Helper()
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return buf
+}
+
+// testClock is a clock for tests whose current time only changes when
+// advanced.
+type testClock struct {
+	t time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{t: time.Date(2022, time.March, 22, 12, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func (c *testClock) Now() time.Time { return c.t }
+
+// setClock sets the clock used by lw.
+func setClock(lw *LogWriter, clock *testClock) {
+	lw.mu.Lock()
+	lw.now = clock.Now
+	lw.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readSequenceState returns the rotation sequence number persisted in
+// the file at path, or zero when the file does not exist.
+func readSequenceState(path string) (uint64, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("cannot read sequence state file: %w", err)
+	}
+	sequence, err := strconv.ParseUint(strings.TrimSpace(string(buf)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse sequence state file %q: %w", path, err)
+	}
+	return sequence, nil
+}
+
+// persistSequence atomically replaces the sequence state file with one
+// holding the current rotation sequence number, by writing a temporary
+// file in the same directory, committing it to stable storage, then
+// renaming it over the sequence state file.
+func (lw *LogWriter) persistSequence() error {
+	path := lw.cfg.SequenceStateFile
+	if lw.tracing() {
+		lw.trace("persistSequence", "path", path, "sequence", lw.sequence)
+	}
+
+	fp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("cannot persist sequence number: %w", err)
+	}
+	temp := fp.Name()
+
+	_, err = fp.WriteString(strconv.FormatUint(lw.sequence, 10) + "\n")
+	if err == nil {
+		err = fp.Sync()
+	}
+	if err2 := fp.Close(); err == nil {
+		err = err2
+	}
+	if err == nil {
+		err = os.Chmod(temp, lw.fileModeFor(filepath.Dir(path)))
+	}
+	if err == nil {
+		err = os.Rename(temp, path)
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+		return fmt.Errorf("cannot persist sequence number: %w", err)
+	}
+	return nil
+}
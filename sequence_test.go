@@ -0,0 +1,65 @@
+package golw
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSequenceStateFile(t *testing.T) {
+	directory := t.TempDir()
+	state := filepath.Join(t.TempDir(), "sequence")
+
+	newWriter := func(t *testing.T) *LogWriter {
+		t.Helper()
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "resumed",
+			BufferSizeMax:     -1,
+			Directory:         directory,
+			IncludeSequence:   true,
+			SequenceStateFile: state,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+		return lw
+	}
+
+	rotate := func(t *testing.T, lw *LogWriter) {
+		t.Helper()
+		_, err := lw.Write([]byte("line\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+	}
+
+	lw := newWriter(t)
+	rotate(t, lw)
+	rotate(t, lw)
+	ensureError(t, lw.Close())
+	ensureBuffer(t, readFile(t, state), []byte("2\n"))
+
+	// Remove the rotated log files, as retention would, so nothing in
+	// the directory reveals the sequence numbers already used.
+	for _, archive := range archivedLogs(t, directory, "resumed") {
+		ensureError(t, os.Remove(archive))
+	}
+
+	lw = newWriter(t)
+	rotate(t, lw)
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "resumed")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := archives[0], ".000003.log"; !strings.HasSuffix(got, want) {
+		t.Errorf("GOT: %v; WANT: suffix %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, state), []byte("3\n"))
+
+	t.Run("invalid", func(t *testing.T) {
+		ensureError(t, os.WriteFile(state, []byte("three\n"), 0644))
+		_, err := NewLogWriter(&Config{Directory: t.TempDir(), SequenceStateFile: state})
+		ensureError(t, err, "cannot parse sequence state file")
+	})
+}
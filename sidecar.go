@@ -0,0 +1,76 @@
+package golw
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// sidecarMetaExtension is appended to the name of a rotated log file to
+// form the name of its companion metadata file.
+const sidecarMetaExtension = ".meta.json"
+
+// SidecarMeta is the metadata the LogWriter writes as JSON next to each
+// rotated log file when WriteSidecarMeta is true.
+type SidecarMeta struct {
+	// Path is the path of the rotated log file.
+	Path string `json:"path"`
+
+	// FirstWrite is the time the LogWriter first wrote data to the log
+	// file, or the zero time when it never wrote to the log file.
+	FirstWrite time.Time `json:"firstWrite"`
+
+	// LastWrite is the time the LogWriter last wrote data to the log
+	// file, or the zero time when it never wrote to the log file.
+	LastWrite time.Time `json:"lastWrite"`
+
+	// Bytes is the size of the log file in bytes, including any
+	// contents the file had before the LogWriter opened it.
+	Bytes int64 `json:"bytes"`
+
+	// Lines is the number of newline characters the LogWriter wrote to
+	// the log file.
+	Lines int64 `json:"lines"`
+
+	// Hostname is the name of the host of the process that wrote the
+	// log file, or the empty string when it cannot be determined.
+	Hostname string `json:"hostname"`
+
+	// PID is the process ID of the process that wrote the log file.
+	PID int `json:"pid"`
+}
+
+// sidecarMeta returns the metadata of the open log file, without its
+// rotated path.
+func (lw *LogWriter) sidecarMeta() SidecarMeta {
+	hostname, _ := os.Hostname()
+	return SidecarMeta{
+		FirstWrite: lw.fileFirstWrite,
+		LastWrite:  lw.fileLastWrite,
+		Bytes:      lw.fileSizeNow,
+		Lines:      lw.fileLinesNow,
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+	}
+}
+
+// writeSidecarMeta writes meta as JSON next to the rotated log file it
+// describes.
+func (lw *LogWriter) writeSidecarMeta(meta SidecarMeta) error {
+	if lw.tracing() {
+		lw.trace("writeSidecarMeta", "path", meta.Path)
+	}
+
+	buf, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return fmt.Errorf("cannot encode sidecar metadata: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	if err = os.WriteFile(meta.Path+sidecarMetaExtension, buf, lw.fileModeFor(filepath.Dir(meta.Path))); err != nil {
+		return fmt.Errorf("cannot write sidecar metadata: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package golw
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteSidecarMeta(t *testing.T) {
+	directory := t.TempDir()
+	clock := newTestClock()
+	start := clock.Now()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:   "sidecar",
+		BufferSizeMax:    -1,
+		Directory:        directory,
+		WriteSidecarMeta: true,
+	})
+	ensureError(t, err)
+	setClock(lw, clock)
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	clock.Advance(time.Minute)
+	_, err = lw.Write([]byte("line 2\nline 3\n"))
+	ensureError(t, err)
+	clock.Advance(time.Minute)
+
+	ensureError(t, lw.Rotate())
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "sidecar")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Decode into a map to verify the names of the JSON fields.
+	var fields map[string]interface{}
+	buf := readFile(t, archives[0]+".meta.json")
+	ensureError(t, json.Unmarshal(buf, &fields))
+	for _, name := range []string{"path", "firstWrite", "lastWrite", "bytes", "lines", "hostname", "pid"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("GOT: %v; WANT: field %q", fields, name)
+		}
+	}
+
+	var meta SidecarMeta
+	ensureError(t, json.Unmarshal(buf, &meta))
+
+	hostname, _ := os.Hostname()
+	want := SidecarMeta{
+		Path:       archives[0],
+		FirstWrite: start,
+		LastWrite:  start.Add(time.Minute),
+		Bytes:      21,
+		Lines:      3,
+		Hostname:   hostname,
+		PID:        os.Getpid(),
+	}
+	if !meta.FirstWrite.Equal(want.FirstWrite) || !meta.LastWrite.Equal(want.LastWrite) {
+		t.Errorf("GOT: %v, %v; WANT: %v, %v", meta.FirstWrite, meta.LastWrite, want.FirstWrite, want.LastWrite)
+	}
+	meta.FirstWrite, meta.LastWrite = want.FirstWrite, want.LastWrite
+	if meta != want {
+		t.Errorf("GOT: %#v; WANT: %#v", meta, want)
+	}
+}
@@ -0,0 +1,21 @@
+package golw
+
+import (
+	"io"
+	"log"
+)
+
+// NewStdLogger returns a log.Logger from the standard library that
+// writes to a new LogWriter created from cfg, along with the LogWriter
+// as an io.Closer, which must be closed to flush the log once the
+// program no longer logs. The log.Logger serializes its output, and
+// writes each entry, terminated with a newline, with a single Write, so
+// an entry is never split across log files, even when logged by
+// concurrent goroutines. The prefix and flag are passed to log.New.
+func NewStdLogger(cfg *Config, prefix string, flag int) (*log.Logger, io.Closer, error) {
+	lw, err := NewLogWriter(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return log.New(lw, prefix, flag), lw, nil
+}
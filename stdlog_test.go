@@ -0,0 +1,71 @@
+package golw
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestNewStdLogger(t *testing.T) {
+	t.Run("invalid config", func(t *testing.T) {
+		_, _, err := NewStdLogger(&Config{Directory: t.TempDir(), MaxBytes: -1}, "", 0)
+		ensureError(t, err, "negative")
+	})
+
+	for _, bufferSizeMax := range []int{-1, 256} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			logger, closer, err := NewStdLogger(&Config{
+				BaseNamePrefix:  "std",
+				BufferSizeMax:   bufferSizeMax,
+				Directory:       directory,
+				IncludeSequence: true,
+				MaxBytes:        200,
+			}, "app: ", log.Lmsgprefix)
+			ensureError(t, err)
+
+			const goroutines, lines = 8, 50
+
+			var wg sync.WaitGroup
+			for g := 0; g < goroutines; g++ {
+				wg.Add(1)
+				go func(g int) {
+					defer wg.Done()
+					for i := 0; i < lines; i++ {
+						logger.Printf("goroutine %d line %d", g, i)
+					}
+				}(g)
+			}
+			wg.Wait()
+			ensureError(t, closer.Close())
+
+			files := append(archivedLogs(t, directory, "std"), filepath.Join(directory, "std.log"))
+			if len(files) < 2 {
+				t.Fatalf("GOT: %v; WANT: several log files", files)
+			}
+
+			seen := make(map[string]bool)
+			for _, file := range files {
+				contents := readFile(t, file)
+				if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+					t.Errorf("%s: GOT: %q; WANT: newline terminated", file, contents)
+				}
+				for _, line := range bytes.Split(bytes.TrimSuffix(contents, []byte("\n")), []byte("\n")) {
+					var g, i int
+					if _, err := fmt.Sscanf(string(line), "app: goroutine %d line %d", &g, &i); err != nil {
+						t.Errorf("%s: GOT: %q; WANT: complete line", file, line)
+						continue
+					}
+					seen[string(line)] = true
+				}
+			}
+			if got, want := len(seen), goroutines*lines; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
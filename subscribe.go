@@ -0,0 +1,121 @@
+package golw
+
+import (
+	"time"
+)
+
+// RotationEvent describes a log file that has been rotated.
+type RotationEvent struct {
+	// ArchivePath is the path the rotated log file was renamed to, or
+	// the empty string when it was streamed to ArchiveWriter and
+	// removed.
+	ArchivePath string
+
+	// Bytes is the size of the rotated log file in bytes.
+	Bytes int64
+
+	// Time is the time the log file was rotated.
+	Time time.Time
+}
+
+type subscriber struct {
+	id uint64
+	fn func(RotationEvent)
+}
+
+// Subscribe registers fn to be invoked with a RotationEvent after each
+// time the LogWriter rotates its log file, and returns a function that
+// unregisters it. Multiple subscribers may be registered, and are
+// invoked in the order they were registered. Subscribers are invoked
+// after the LogWriter releases its lock, from the goroutine whose
+// method invocation caused the rotation, so a subscriber may invoke
+// methods on the LogWriter without deadlocking. Because of this, when
+// multiple goroutines write to the LogWriter, subscribers may be
+// invoked concurrently. A panic raised by a subscriber is recovered
+// and reported to OnError. The returned unsubscribe function may be
+// invoked more than once.
+func (lw *LogWriter) Subscribe(fn func(RotationEvent)) (unsubscribe func()) {
+	lw.lock()
+	defer lw.unlock()
+
+	lw.subscriberLast++
+	id := lw.subscriberLast
+	lw.subscribers = append(lw.subscribers, subscriber{id: id, fn: fn})
+
+	return func() {
+		lw.lock()
+		defer lw.unlock()
+
+		for i, s := range lw.subscribers {
+			if s.id == id {
+				// Make a new slice rather than modifying the existing
+				// one, which may be in use by unlock.
+				subscribers := make([]subscriber, 0, len(lw.subscribers)-1)
+				subscribers = append(subscribers, lw.subscribers[:i]...)
+				lw.subscribers = append(subscribers, lw.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// queueRotationEvent stores event for delivery to subscribers once the
+// lock is released.
+func (lw *LogWriter) queueRotationEvent(event RotationEvent) {
+	if len(lw.subscribers) > 0 {
+		lw.rotationsPending = append(lw.rotationsPending, event)
+	}
+}
+
+// queueArchiveComplete stores the final path of an archived log file
+// for delivery to OnArchiveComplete once the lock is released.
+func (lw *LogWriter) queueArchiveComplete(finalPath string) {
+	if lw.cfg.OnArchiveComplete != nil {
+		lw.archivesPending = append(lw.archivesPending, finalPath)
+	}
+}
+
+// lock acquires the lock, noting when it was acquired when measuring
+// how long the lock is held.
+func (lw *LogWriter) lock() {
+	lw.mu.Lock()
+	if lw.cfg.MeasureLockContention {
+		lw.lockAcquired = time.Now()
+	}
+}
+
+// unlock releases the lock, then delivers any rotation events queued
+// while it was held to subscribers, followed by any archived log files
+// queued while it was held to OnArchiveComplete.
+func (lw *LogWriter) unlock() {
+	if !lw.lockAcquired.IsZero() {
+		held := time.Since(lw.lockAcquired)
+		lw.lockAcquired = time.Time{}
+		lw.stats.LockHoldTotal += held
+		if held > lw.stats.LockHoldMax {
+			lw.stats.LockHoldMax = held
+		}
+	}
+
+	events := lw.rotationsPending
+	subscribers := lw.subscribers
+	lw.rotationsPending = nil
+	archives := lw.archivesPending
+	onArchiveComplete := lw.cfg.OnArchiveComplete
+	onError := lw.cfg.OnError
+	lw.archivesPending = nil
+	lw.mu.Unlock()
+
+	for _, event := range events {
+		for _, s := range subscribers {
+			if err := callSafely("subscriber", func() { s.fn(event) }); err != nil {
+				reportError(onError, err)
+			}
+		}
+	}
+	for _, finalPath := range archives {
+		if err := callSafely("OnArchiveComplete", func() { onArchiveComplete(finalPath) }); err != nil {
+			reportError(onError, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package golw
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestSubscribe(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "subscribe",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		MaxBytes:       10,
+	})
+	ensureError(t, err)
+
+	var calls []string
+
+	unsubscribeFirst := lw.Subscribe(func(event RotationEvent) {
+		calls = append(calls, fmt.Sprintf("first %d", event.Bytes))
+	})
+	lw.Subscribe(func(event RotationEvent) {
+		calls = append(calls, fmt.Sprintf("second %d", event.Bytes))
+	})
+
+	for i := 0; i < 3; i++ {
+		_, err = fmt.Fprintf(lw, "line %d\n", i)
+		ensureError(t, err)
+		if i == 1 {
+			unsubscribeFirst()
+			unsubscribeFirst() // idempotent
+		}
+	}
+
+	ensureError(t, lw.Close())
+
+	if got, want := fmt.Sprint(calls), "[first 7 second 7 second 7]"; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	archives := archivedLogs(t, directory, "subscribe")
+	if got, want := len(archives), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+}
+
+func TestSubscribeArchivePath(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "subscribe",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		MaxBytes:       10,
+	})
+	ensureError(t, err)
+
+	var events []RotationEvent
+	lw.Subscribe(func(event RotationEvent) {
+		events = append(events, event)
+		// Invoking methods on the LogWriter from a subscriber must not
+		// deadlock.
+		_, err := lw.Write([]byte("!\n"))
+		ensureError(t, err)
+	})
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, events[0].ArchivePath), []byte("line 1\n"))
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "subscribe.log")), []byte("line 2\n!\n"))
+}
+
+func TestOnArchiveComplete(t *testing.T) {
+	directory := t.TempDir()
+
+	var lw *LogWriter
+	var finalPaths []string
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix:   "archived",
+		BufferSizeMax:    -1,
+		Directory:        directory,
+		MaxBytes:         10,
+		WriteSidecarMeta: true,
+		OnArchiveComplete: func(finalPath string) {
+			finalPaths = append(finalPaths, finalPath)
+			// The sidecar metadata file has already been written, and
+			// invoking methods on the LogWriter must not deadlock.
+			readFile(t, finalPath+sidecarMetaExtension)
+			_ = lw.Stats()
+		},
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("line 2\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	archives := archivedLogs(t, directory, "archived")
+	if got, want := fmt.Sprint(finalPaths), fmt.Sprint(archives); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := len(finalPaths), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, finalPaths[0]), []byte("line 1\n"))
+}
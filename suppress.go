@@ -0,0 +1,20 @@
+package golw
+
+import "time"
+
+// suppressRotation updates whether rotating the log file because it is
+// full is suppressed at time now by SuppressRotationDuring.
+func (lw *LogWriter) suppressRotation(now time.Time) {
+	if lw.cfg.SuppressRotationDuring == nil {
+		lw.suppressed = false
+		return
+	}
+	var suppressed bool
+	if err := callSafely("SuppressRotationDuring", func() { suppressed = lw.cfg.SuppressRotationDuring(now) }); err != nil {
+		lw.reportError(err)
+	}
+	if suppressed != lw.suppressed && lw.tracing() {
+		lw.trace("suppressRotation", "path", lw.filePath, "suppressed", suppressed)
+	}
+	lw.suppressed = suppressed
+}
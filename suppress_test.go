@@ -0,0 +1,72 @@
+package golw
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSuppressRotationDuring(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+			clock := newTestClock()
+			windowEnd := clock.Now().Add(10 * time.Second)
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "window",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+				MaxBytes:       20,
+				SuppressRotationDuring: func(now time.Time) bool {
+					return now.Before(windowEnd)
+				},
+			})
+			ensureError(t, err)
+			setClock(lw, clock)
+
+			// The log file grows beyond MaxBytes during the window.
+			for i := 0; i < 6; i++ {
+				_, err = fmt.Fprintf(lw, "line %d\n", i)
+				ensureError(t, err)
+				ensureError(t, lw.Flush())
+				clock.Advance(time.Second)
+			}
+			if got := archivedLogs(t, directory, "window"); len(got) != 0 {
+				t.Fatalf("GOT: %v; WANT: no rotated log files", got)
+			}
+			if got, want := lw.Stats().FileSize, int64(42); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			// The first write after the window rotates the full log file.
+			clock.Advance(5 * time.Second)
+			_, err = lw.Write([]byte("line 6\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Flush())
+
+			archives := archivedLogs(t, directory, "window")
+			if got, want := len(archives), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", got, want)
+			}
+			var want strings.Builder
+			for i := 0; i < 6; i++ {
+				fmt.Fprintf(&want, "line %d\n", i)
+			}
+			ensureBuffer(t, readFile(t, archives[0]), []byte(want.String()))
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 6\n"))
+
+			ensureError(t, lw.Close())
+		})
+	}
+
+	t.Run("Mmap", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			Directory:              t.TempDir(),
+			Mmap:                   true,
+			SuppressRotationDuring: func(time.Time) bool { return false },
+		})
+		ensureError(t, err, "SuppressRotationDuring")
+	})
+}
@@ -0,0 +1,62 @@
+package golw
+
+// Tail returns a copy of the bytes most recently written to the
+// LogWriter, up to TailBufferSize bytes, oldest first. It returns nil
+// when TailBufferSize is zero, or nothing has been written. Because
+// the retained bytes are limited by size rather than by line, the
+// first line of the returned bytes is usually incomplete.
+func (lw *LogWriter) Tail() []byte {
+	lw.lock()
+	defer lw.unlock()
+	return lw.tailContents()
+}
+
+// tailContents returns a copy of the bytes in the tail ring buffer,
+// oldest first, while the lock is held.
+func (lw *LogWriter) tailContents() []byte {
+	if !lw.tailWrapped {
+		if lw.tailNext == 0 {
+			return nil
+		}
+		return append([]byte(nil), lw.tail[:lw.tailNext]...)
+	}
+	contents := make([]byte, 0, len(lw.tail))
+	contents = append(contents, lw.tail[lw.tailNext:]...)
+	return append(contents, lw.tail[:lw.tailNext]...)
+}
+
+// appendTail stores p in the tail ring buffer while the lock is held,
+// overwriting the oldest bytes once the ring buffer is full.
+func (lw *LogWriter) appendTail(p []byte) {
+	size := len(lw.tail)
+	if size == 0 {
+		return
+	}
+	if len(p) >= size {
+		// Only the final bytes of p fit in the ring buffer.
+		copy(lw.tail, p[len(p)-size:])
+		lw.tailNext = 0
+		lw.tailWrapped = true
+		return
+	}
+	nc := copy(lw.tail[lw.tailNext:], p)
+	copy(lw.tail, p[nc:])
+	if lw.tailNext+len(p) >= size {
+		lw.tailWrapped = true
+	}
+	lw.tailNext = (lw.tailNext + len(p)) % size
+}
+
+// resizeTail changes the size of the tail ring buffer while the lock is
+// held, retaining as many of the most recently written bytes as fit.
+func (lw *LogWriter) resizeTail(size int) {
+	if size == len(lw.tail) {
+		return
+	}
+	contents := lw.tailContents()
+	lw.tail, lw.tailNext, lw.tailWrapped = nil, 0, false
+	if size > 0 {
+		lw.tail = make([]byte, size)
+		lw.appendTail(contents)
+	}
+}
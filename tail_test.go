@@ -0,0 +1,104 @@
+package golw
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "tail",
+			Directory:      t.TempDir(),
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		if got := lw.Tail(); got != nil {
+			t.Errorf("GOT: %q; WANT: %v", got, nil)
+		}
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix: "tail",
+			Directory:      t.TempDir(),
+			TailBufferSize: -1,
+		})
+		ensureError(t, err, "negative tail buffer size")
+	})
+
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "tail",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+				MaxBytes:       100,
+				TailBufferSize: 20,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+			ensureBuffer(t, lw.Tail(), []byte("line 1\n"))
+
+			// Write more than the ring buffer holds, across several
+			// log files.
+			for i := 2; i <= 40; i++ {
+				_, err = lw.Write([]byte(fmt.Sprintf("line %d\n", i)))
+				ensureError(t, err)
+			}
+			ensureBuffer(t, lw.Tail(), []byte(" 38\nline 39\nline 40\n"))
+
+			// A single write larger than the ring buffer keeps only
+			// its final bytes.
+			_, err = lw.Write([]byte("this line is longer than the tail buffer\n"))
+			ensureError(t, err)
+			ensureBuffer(t, lw.Tail(), []byte("han the tail buffer\n"))
+
+			ensureError(t, lw.Close())
+		})
+	}
+
+	t.Run("Reconfigure", func(t *testing.T) {
+		cfg := &Config{
+			BaseNamePrefix: "tail",
+			Directory:      t.TempDir(),
+			TailBufferSize: 16,
+		}
+		lw, err := NewLogWriter(cfg)
+		ensureError(t, err)
+
+		for i := 1; i <= 3; i++ {
+			_, err = lw.Write([]byte(fmt.Sprintf("line %d\n", i)))
+			ensureError(t, err)
+		}
+		ensureBuffer(t, lw.Tail(), []byte("1\nline 2\nline 3\n"))
+
+		cfg.TailBufferSize = 7
+		ensureError(t, lw.Reconfigure(cfg))
+		ensureBuffer(t, lw.Tail(), []byte("line 3\n"))
+
+		cfg.TailBufferSize = 32
+		ensureError(t, lw.Reconfigure(cfg))
+		ensureBuffer(t, lw.Tail(), []byte("line 3\n"))
+		_, err = lw.Write([]byte("line 4\n"))
+		ensureError(t, err)
+		ensureBuffer(t, lw.Tail(), []byte("line 3\nline 4\n"))
+
+		cfg.TailBufferSize = 0
+		ensureError(t, lw.Reconfigure(cfg))
+		if got := lw.Tail(); got != nil {
+			t.Errorf("GOT: %q; WANT: %v", got, nil)
+		}
+
+		ensureError(t, lw.Close())
+	})
+}
@@ -0,0 +1,49 @@
+package golw
+
+import (
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+// throttle blocks until n bytes may be written without exceeding the
+// configured rate, or returns an error when the LogWriter is closed
+// while waiting. It takes the n bytes from the token bucket before
+// waiting, so that concurrent writers wait their turn, and so a write
+// larger than the bucket waits for the time it takes to write it.
+func (lw *LogWriter) throttle(n int) error {
+	if lw.throttleDone == nil || n == 0 {
+		return nil
+	}
+
+	// This is invoked before the lock is acquired, so it reads neither
+	// the configuration nor TraceFunc, which Reconfigure may change.
+	lw.throttleMu.Lock()
+	rate := lw.throttleRate
+	now := time.Now()
+	lw.throttleTokens += now.Sub(lw.throttleLast).Seconds() * rate
+	if lw.throttleTokens > lw.throttleBurst {
+		lw.throttleTokens = lw.throttleBurst
+	}
+	lw.throttleLast = now
+	lw.throttleTokens -= float64(n)
+	tokens := lw.throttleTokens
+	lw.throttleMu.Unlock()
+
+	if tokens >= 0 {
+		return nil
+	}
+
+	wait := time.Duration(-tokens / rate * float64(time.Second))
+	debug("throttle: waiting %s to write %d bytes\n", wait, n)
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-lw.throttleDone:
+		return fmt.Errorf("cannot write throttled data: %w", fs.ErrClosed)
+	}
+}
@@ -0,0 +1,99 @@
+package golw
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMaxBytesPerSecond(t *testing.T) {
+	t.Run("rate", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "throttle",
+			Directory:         t.TempDir(),
+			MaxBytesBurst:     1000,
+			MaxBytesPerSecond: 10000,
+		})
+		ensureError(t, err)
+
+		line := []byte(strings.Repeat("x", 99) + "\n")
+
+		// The first 1000 bytes are a burst, and the remaining 4000
+		// bytes ought to take 400 milliseconds.
+		start := time.Now()
+		for i := 0; i < 50; i++ {
+			_, err = lw.Write(line)
+			ensureError(t, err)
+		}
+		elapsed := time.Since(start)
+
+		if elapsed < 350*time.Millisecond || elapsed > 2*time.Second {
+			t.Errorf("GOT: %v; WANT: about 400ms", elapsed)
+		}
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("close releases blocked writer", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "throttle-close",
+			Directory:         t.TempDir(),
+			MaxBytesPerSecond: 1,
+		})
+		ensureError(t, err)
+
+		// Exhaust the bucket, so the next write would block for an
+		// hour.
+		_, err = lw.Write([]byte("\n"))
+		ensureError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := lw.Write([]byte(strings.Repeat("x", 3599) + "\n"))
+			done <- err
+		}()
+
+		time.Sleep(10 * time.Millisecond)
+		ensureError(t, lw.Close())
+
+		select {
+		case err = <-done:
+			if !errors.Is(err, fs.ErrClosed) {
+				t.Errorf("GOT: %v; WANT: %v", err, fs.ErrClosed)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("GOT: blocked writer; WANT: writer released by Close")
+		}
+	})
+
+	t.Run("concurrent Reconfigure", func(t *testing.T) {
+		// Run with the race detector to check throttled writes do not
+		// read the configuration Reconfigure replaces.
+		cfg := Config{
+			BaseNamePrefix:    "throttle-reconfigure",
+			Directory:         t.TempDir(),
+			MaxBytesPerSecond: 1 << 20,
+		}
+		lw, err := NewLogWriter(&cfg)
+		ensureError(t, err)
+
+		done := make(chan error, 1)
+		go func() {
+			for i := 0; i < 100; i++ {
+				if _, err := lw.Write([]byte("line\n")); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}()
+		for i := 0; i < 100; i++ {
+			cfg.FlushOnBufferFull = i%2 == 0
+			ensureError(t, lw.Reconfigure(&cfg))
+		}
+		ensureError(t, <-done)
+		ensureError(t, lw.Close())
+	})
+}
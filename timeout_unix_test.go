@@ -0,0 +1,60 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package golw
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWriteTimeout(t *testing.T) {
+	directory := t.TempDir()
+	fifoPath := filepath.Join(directory, "fifo.log")
+
+	if err := syscall.Mkfifo(fifoPath, 0600); err != nil {
+		t.Skip(err)
+	}
+
+	// Open the reading end of the named pipe without blocking, so the
+	// LogWriter can open the writing end, but never read from it.
+	reader, err := os.OpenFile(fifoPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+	ensureError(t, err)
+	defer reader.Close()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "fifo",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		WriteTimeout:   50 * time.Millisecond,
+	})
+	ensureError(t, err)
+
+	// Write more than the pipe can hold so the write blocks.
+	p := make([]byte, 4<<20)
+	for i := range p {
+		p[i] = '.'
+	}
+	p[len(p)-1] = '\n'
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := lw.Write(p)
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("GOT: %v; WANT: %v", err, os.ErrDeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("GOT: write blocked; WANT: write timeout")
+	}
+
+	_ = lw.Close()
+}
@@ -0,0 +1,52 @@
+package golw
+
+import (
+	"strings"
+	"time"
+)
+
+// invalidFileNameRunes returns the characters, in addition to control
+// characters, which are invalid in file names on the specified
+// platform.
+func invalidFileNameRunes(goos string) string {
+	if goos == "windows" {
+		return `<>:"/\|?*`
+	}
+	return "/"
+}
+
+// isInvalidFileNameRune returns true when r is invalid in file names
+// on the specified platform.
+func isInvalidFileNameRune(r rune, goos string) bool {
+	if r < ' ' || (goos == "windows" && r == 0x7f) {
+		return true
+	}
+	return strings.ContainsRune(invalidFileNameRunes(goos), r)
+}
+
+// invalidTimestampRunes returns the characters in timestamp that are
+// invalid in file names on the specified platform, or the empty string
+// when there are none.
+func invalidTimestampRunes(timestamp, goos string) string {
+	var invalid []rune
+	for _, r := range timestamp {
+		if isInvalidFileNameRune(r, goos) && !strings.ContainsRune(string(invalid), r) {
+			invalid = append(invalid, r)
+		}
+	}
+	return string(invalid)
+}
+
+// makeSanitizingFormatter returns a time formatter that replaces each
+// character produced by formatter that is invalid in file names on the
+// specified platform with a hyphen.
+func makeSanitizingFormatter(formatter func(time.Time) string, goos string) func(time.Time) string {
+	return func(t time.Time) string {
+		return strings.Map(func(r rune) rune {
+			if isInvalidFileNameRune(r, goos) {
+				return '-'
+			}
+			return r
+		}, formatter(t))
+	}
+}
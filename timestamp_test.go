@@ -0,0 +1,110 @@
+package golw
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInvalidTimestampRunes(t *testing.T) {
+	stamp := time.Date(2022, time.March, 22, 12, 34, 56, 0, time.UTC).Format(time.RFC3339)
+
+	if got, want := invalidTimestampRunes(stamp, "windows"), ":"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := invalidTimestampRunes(stamp, "linux"), ""; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := invalidTimestampRunes("2022/03/22", "linux"), "/"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+	if got, want := invalidTimestampRunes(time.Now().Format(DateTime), "windows"), ""; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestMakeSanitizingFormatter(t *testing.T) {
+	formatter := makeSanitizingFormatter(makeDateTimeFormatter(time.RFC3339), "windows")
+
+	got := formatter(time.Date(2022, time.March, 22, 12, 34, 56, 0, time.UTC))
+	if want := "2022-03-22T12-34-56Z"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
+
+func TestSanitizeTimestamp(t *testing.T) {
+	t.Run("rejects invalid characters", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix: "sanitize",
+			Directory:      t.TempDir(),
+			TimeFormat:     "2006/01/02",
+		})
+		ensureError(t, err, "invalid in file names", "/")
+	})
+
+	t.Run("replaces invalid characters", func(t *testing.T) {
+		directory := t.TempDir()
+
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "sanitize",
+			BufferSizeMax:     -1,
+			Directory:         directory,
+			MaxBytes:          4,
+			SanitizeTimestamp: true,
+			TimeFormat:        "2006/01/02",
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("one\n"))
+		ensureError(t, err)
+		_, err = lw.Write([]byte("two\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+
+		archives := archivedLogs(t, directory, "sanitize")
+		if got, want := len(archives), 1; got != want {
+			t.Fatalf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, archives[0]), []byte("one\n"))
+	})
+}
+
+func TestEmptyTimeStamp(t *testing.T) {
+	directory := t.TempDir()
+
+	var reported []error
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "empty",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		OnError:        func(err error) { reported = append(reported, err) },
+		TimeFormatter:  func(time.Time) string { return "" },
+	})
+	ensureError(t, err)
+	clock := newTestClock()
+	setClock(lw, clock)
+
+	var want []string
+	for i := 0; i < 2; i++ {
+		want = append(want, filepath.Join(directory, "empty."+nanoDateTimeFormatter(clock.Now())+".log"))
+		_, err = lw.Write([]byte("line\n"))
+		ensureError(t, err)
+		ensureError(t, lw.Rotate())
+		clock.Advance(time.Second)
+	}
+	ensureError(t, lw.Close())
+
+	got := archivedLogs(t, directory, "empty")
+	if len(got) != len(want) {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("GOT: %v; WANT: %v", got[i], want[i])
+		}
+	}
+	if got, want := len(reported), 2; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureError(t, reported[0], "empty time stamp")
+}
@@ -0,0 +1,28 @@
+package golw
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSanitizeTimestampWindows(t *testing.T) {
+	t.Run("rejects colons", func(t *testing.T) {
+		_, err := NewLogWriter(&Config{
+			BaseNamePrefix: "sanitize",
+			Directory:      t.TempDir(),
+			TimeFormat:     time.RFC3339,
+		})
+		ensureError(t, err, "invalid in file names", ":")
+	})
+
+	t.Run("replaces colons", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix:    "sanitize",
+			Directory:         t.TempDir(),
+			SanitizeTimestamp: true,
+			TimeFormat:        time.RFC3339,
+		})
+		ensureError(t, err)
+		ensureError(t, lw.Close())
+	})
+}
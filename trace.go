@@ -0,0 +1,45 @@
+package golw
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tracing returns true when trace events are consumed, either by
+// TraceFunc, or by debug in development builds. Callers check it before
+// invoking trace, so the arguments of trace events are not even
+// evaluated when nothing consumes them.
+func (lw *LogWriter) tracing() bool {
+	return debugging || lw.cfg.TraceFunc != nil
+}
+
+// trace emits the trace event for the operation op, with the fields in
+// kv as alternating keys and values, to TraceFunc, and to debug in
+// development builds. A panic raised by TraceFunc is reported to
+// OnError.
+func (lw *LogWriter) trace(op string, kv ...interface{}) {
+	if debugging {
+		debug("%s\n", formatTraceEvent(op, kv))
+	}
+	if lw.cfg.TraceFunc != nil {
+		if err := callSafely("TraceFunc", func() { lw.cfg.TraceFunc(op, kv...) }); err != nil {
+			lw.reportError(err)
+		}
+	}
+}
+
+// formatTraceEvent returns the trace event for the operation op, with
+// the fields in kv as alternating keys and values, formatted as a
+// single line.
+func formatTraceEvent(op string, kv []interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(op)
+	for i := 0; i < len(kv); i += 2 {
+		if i+1 < len(kv) {
+			fmt.Fprintf(&sb, " %v=%v", kv[i], kv[i+1])
+		} else {
+			fmt.Fprintf(&sb, " %v", kv[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package golw
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceFunc(t *testing.T) {
+	directory := t.TempDir()
+
+	type event struct {
+		op string
+		kv []interface{}
+	}
+	var events []event
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "trace",
+		BufferSizeMax:  -1,
+		Directory:      directory,
+		TraceFunc: func(op string, kv ...interface{}) {
+			events = append(events, event{op, kv})
+		},
+	})
+	ensureError(t, err)
+	setClock(lw, newTestClock())
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+
+	events = nil
+	ensureError(t, lw.Rotate())
+
+	var ops []string
+	for _, e := range events {
+		ops = append(ops, e.op)
+	}
+	if got, want := strings.Join(ops, ","), "rotateLog,closeLog,renameLog,openLog"; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+
+	rename := events[2].kv
+	if got, want := len(rename), 4; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := rename[0], interface{}("path"); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := rename[1], interface{}(filepath.Join(directory, "trace.log")); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	archived := archivedLogs(t, directory, "trace")
+	if len(archived) != 1 {
+		t.Fatalf("GOT: %v; WANT: 1 rotated log file", archived)
+	}
+	if got, want := rename[3], interface{}(archived[0]); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, lw.Close())
+}
+
+func TestTraceFuncPanic(t *testing.T) {
+	var reported []error
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "trace",
+		Directory:      t.TempDir(),
+		OnError:        func(err error) { reported = append(reported, err) },
+		TraceFunc:      func(string, ...interface{}) { panic("tracer bug") },
+	})
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	if len(reported) == 0 {
+		t.Fatalf("GOT: %v; WANT: reported panic", reported)
+	}
+	ensureError(t, reported[0], "TraceFunc", "tracer bug")
+}
+
+func TestFormatTraceEvent(t *testing.T) {
+	got := formatTraceEvent("renameLog", []interface{}{"path", "a.log", "bytes", 42, "odd"})
+	if want := "renameLog path=a.log bytes=42 odd"; got != want {
+		t.Errorf("GOT: %q; WANT: %q", got, want)
+	}
+}
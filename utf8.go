@@ -0,0 +1,22 @@
+package golw
+
+import (
+	"unicode/utf8"
+)
+
+// replacementCharacter is the UTF-8 encoding of U+FFFD, which replaces
+// invalid bytes when ReplaceInvalidUTF8 is true.
+var replacementCharacter = []byte(string(utf8.RuneError))
+
+// invalidUTF8Offset returns the offset of the first byte of p that is
+// not part of a valid UTF-8 encoded character, or -1 when p is valid.
+func invalidUTF8Offset(p []byte) int {
+	for i := 0; i < len(p); {
+		r, size := utf8.DecodeRune(p[i:])
+		if r == utf8.RuneError && size <= 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+package golw
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateUTF8(t *testing.T) {
+	cases := map[string]struct {
+		input    string
+		valid    bool
+		offset   int    // offset of first invalid byte
+		replaced string // written when replacing invalid bytes
+	}{
+		"ascii":               {input: "hello\n", valid: true, replaced: "hello\n"},
+		"multibyte":           {input: "héllo, 世界\n", valid: true, replaced: "héllo, 世界\n"},
+		"invalid byte":        {input: "bad \xff byte\n", offset: 4, replaced: "bad � byte\n"},
+		"truncated at end":    {input: "caf\xc3", offset: 3, replaced: "caf�"},
+		"truncated threebyte": {input: "x\xe4\xb8\n", offset: 1, replaced: "x�\n"},
+	}
+
+	for _, replace := range []bool{false, true} {
+		for name, tc := range cases {
+			policy := "reject"
+			if replace {
+				policy = "replace"
+			}
+			t.Run(policy+"/"+name, func(t *testing.T) {
+				directory := t.TempDir()
+
+				lw, err := NewLogWriter(&Config{
+					BaseNamePrefix:     "utf8",
+					BufferSizeMax:      -1,
+					Directory:          directory,
+					ReplaceInvalidUTF8: replace,
+					ValidateUTF8:       true,
+				})
+				ensureError(t, err)
+
+				nw, err := lw.Write([]byte(tc.input))
+
+				var want string
+				if tc.valid || replace {
+					ensureError(t, err)
+					if got, want := nw, len(tc.input); got != want {
+						t.Errorf("GOT: %v; WANT: %v", got, want)
+					}
+					want = tc.replaced
+				} else {
+					ensureError(t, err, "invalid UTF-8")
+					if got, want := invalidUTF8Offset([]byte(tc.input)), tc.offset; got != want {
+						t.Errorf("GOT: %v; WANT: %v", got, want)
+					}
+				}
+
+				ensureError(t, lw.Close())
+				ensureBuffer(t, readFile(t, filepath.Join(directory, "utf8.log")), []byte(want))
+			})
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package golw
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// verifyArchives decompresses each compressed rotated log file in the
+// log directory, and renames each one that cannot be decompressed in
+// full, such as one truncated by a crash while it was being compressed,
+// by appending ".corrupt" to its name, reporting it to OnError. Renamed
+// files no longer belong to the LogWriter, so they are not included in
+// FS, nor recognized by ParseBackupName.
+func (lw *LogWriter) verifyArchives() error {
+	directory := lw.logDirectory()
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // CreateDirectory creates it when opening the log file
+		}
+		return fmt.Errorf("cannot read log directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if info, ok := ParseBackupName(&lw.cfg, name); !ok || !info.Compressed {
+			continue
+		}
+		path := filepath.Join(directory, name)
+		verr := verifyGzip(path)
+		if verr == nil {
+			continue
+		}
+		if lw.tracing() {
+			lw.trace("verifyArchives", "corrupt", path, "error", verr)
+		}
+		quarantine := path + ".corrupt"
+		if err = os.Rename(path, quarantine); err != nil {
+			lw.reportError(fmt.Errorf("cannot quarantine corrupt rotated log file: %w", err))
+			continue
+		}
+		lw.reportError(fmt.Errorf("cannot decompress rotated log file, renamed to %q: %w", quarantine, verr))
+	}
+	return nil
+}
+
+// verifyGzip returns an error when the file at path cannot be
+// decompressed in full, which includes verifying the checksum at the end
+// of each of its gzip members.
+func verifyGzip(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(io.Discard, zr); err != nil {
+		return err
+	}
+	return zr.Close()
+}
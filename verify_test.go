@@ -0,0 +1,58 @@
+package golw
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyOnStart(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte("line 1\nline 2\n"))
+	ensureError(t, err)
+	ensureError(t, zw.Close())
+
+	for _, verify := range []bool{false, true} {
+		t.Run(fmt.Sprintf("VerifyOnStart %t", verify), func(t *testing.T) {
+			directory := t.TempDir()
+			valid := filepath.Join(directory, "verify.1647950400000000000.log.gz")
+			corrupt := filepath.Join(directory, "verify.1647950500000000000.log.gz")
+			ensureError(t, os.WriteFile(valid, compressed.Bytes(), 0644))
+			ensureError(t, os.WriteFile(corrupt, compressed.Bytes()[:compressed.Len()-6], 0644))
+
+			var reported []error
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "verify",
+				Directory:      directory,
+				OnError:        func(err error) { reported = append(reported, err) },
+				VerifyOnStart:  verify,
+			})
+			ensureError(t, err)
+			ensureError(t, lw.Close())
+
+			if _, err = os.Stat(valid); err != nil {
+				t.Errorf("GOT: %v; WANT: valid file to remain", err)
+			}
+			_, err = os.Stat(corrupt)
+			if !verify {
+				ensureError(t, err)
+				if got, want := len(reported), 0; got != want {
+					t.Errorf("GOT: %v; WANT: %v", reported, want)
+				}
+				return
+			}
+			if !os.IsNotExist(err) {
+				t.Errorf("GOT: %v; WANT: corrupt file to be renamed", err)
+			}
+			ensureBuffer(t, readFile(t, corrupt+".corrupt"), compressed.Bytes()[:compressed.Len()-6])
+			if got, want := len(reported), 1; got != want {
+				t.Fatalf("GOT: %v; WANT: %v", reported, want)
+			}
+			ensureError(t, reported[0], "cannot decompress", ".corrupt")
+		})
+	}
+}
@@ -0,0 +1,376 @@
+package golw
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// Writer is the interface implemented by LogWriter, allowing programs
+// to depend on the interface rather than the concrete type, and inject
+// a different implementation, such as the in-memory one provided by
+// the golwtest package, when testing.
+type Writer interface {
+	io.WriteCloser
+
+	// CurrentFile returns the path of the log file currently being
+	// written to.
+	CurrentFile() string
+
+	// Flush writes all completed writes to the log file.
+	Flush() error
+
+	// Rotate flushes completed writes, then rotates the log file.
+	Rotate() error
+
+	// Stats returns a snapshot of the writer's statistics.
+	Stats() Stats
+}
+
+var _ Writer = (*LogWriter)(nil)
+
+// Stats holds statistics about a LogWriter.
+type Stats struct {
+	// Writes is the number of non-empty writes to the LogWriter.
+	Writes int64
+
+	// BytesWritten is the number of bytes written to all log files
+	// since the LogWriter was created.
+	BytesWritten int64
+
+	// Rotations is the number of times the log file was rotated.
+	Rotations int64
+
+	// BytesLogged is the number of bytes accepted by writes to the
+	// LogWriter since it was created, including any record prefix,
+	// suffix, or frame header.
+	BytesLogged int64
+
+	// BytesMoved is the number of bytes the LogWriter has copied since
+	// it was created: into and within its buffer, to log files, and to
+	// each ArchiveWriter or other file system when archiving rotated
+	// log files.
+	BytesMoved int64
+
+	// WriteAmplification is BytesMoved divided by BytesLogged, or zero
+	// before anything is logged. It is 1 when each logged byte is
+	// written once directly to the log file, and grows with each
+	// additional copy made by buffering or archiving, so it shows the
+	// overhead of a configuration.
+	WriteAmplification float64
+
+	// BufferedBytes is the number of bytes in the buffer waiting to be
+	// written to the log file.
+	BufferedBytes int
+
+	// BufferedExtents is the number of writes in the buffer waiting to
+	// be written to the log file, including any write waiting for a
+	// newline.
+	BufferedExtents int
+
+	// FileSize is the size in bytes of the log file currently being
+	// written to.
+	FileSize int64
+
+	// FileLines is the number of newline characters the LogWriter has
+	// written to the log file currently being written to, when lines
+	// are counted, as described by CountLines, and zero otherwise.
+	FileLines int64
+
+	// RotatedLines is the number of newline characters the LogWriter
+	// wrote to the most recently rotated log file, when lines are
+	// counted, as described by CountLines, and zero otherwise.
+	RotatedLines int64
+
+	// FileDescriptorHeadroom is the number of additional files the
+	// process may open before reaching its limit of open file
+	// descriptors, or -1 when it cannot be determined, such as on
+	// operating systems other than some Unix like ones.
+	FileDescriptorHeadroom int64
+
+	// LockHoldTotal is the total duration the internal lock has been
+	// held, when MeasureLockContention is true, and zero otherwise.
+	LockHoldTotal time.Duration
+
+	// LockHoldMax is the longest duration the internal lock has been
+	// held at once, when MeasureLockContention is true, and zero
+	// otherwise.
+	LockHoldMax time.Duration
+}
+
+// CurrentFile returns the path of the log file currently being written
+// to.
+func (lw *LogWriter) CurrentFile() string {
+	lw.lock()
+	defer lw.unlock()
+	return lw.filePath
+}
+
+// Flush writes all completed writes in the buffer to the log file,
+// rotating it as needed. A final write that is not newline terminated
+// remains in the buffer, so it is not split from the remainder of its
+// line.
+func (lw *LogWriter) Flush() error {
+	lw.lock()
+	defer lw.unlock()
+	return lw.flush()
+}
+
+// FlushIfOver writes all completed writes in the buffer to the log
+// file, just like Flush, but only when the buffer holds more than
+// threshold bytes; otherwise it does nothing. It is inexpensive to
+// call when the buffer is small, so a program may call it whenever it
+// detects memory pressure to release buffered data proactively.
+func (lw *LogWriter) FlushIfOver(threshold int) error {
+	lw.lock()
+	defer lw.unlock()
+
+	if len(lw.buf) <= threshold {
+		return nil
+	}
+	return lw.flush()
+}
+
+// WriteSync writes p to the LogWriter just like Write, then writes all
+// completed writes in the buffer to the log file, just like Flush, so
+// important messages reach the log file immediately without otherwise
+// changing the buffering of the LogWriter. When p is not newline
+// terminated, it remains in the buffer until its line is completed.
+// When WriteSyncFsync is true, it also commits the log file to stable
+// storage.
+func (lw *LogWriter) WriteSync(p []byte) (int, error) {
+	if err := lw.throttle(len(p)); err != nil {
+		return 0, err
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	nw, err := lw.write(p)
+	if err != nil {
+		return nw, err
+	}
+	if err = lw.flush(); err != nil {
+		return nw, err
+	}
+	if lw.cfg.WriteSyncFsync {
+		if err = lw.syncLog(); err != nil {
+			return nw, err
+		}
+	}
+	return nw, nil
+}
+
+// Barrier writes all completed writes in the buffer to the log file,
+// just like Flush, then commits the log file to stable storage, and
+// only returns once the log file has been committed, so a program may
+// defer an action until everything it has already logged is durable.
+// Unlike WriteSync, it does not write anything, and commits the log file
+// regardless of WriteSyncFsync. Log files rotated while the buffer is
+// written are committed to stable storage before they are rotated. A
+// final write that is not newline terminated remains in the buffer, and
+// is not committed.
+func (lw *LogWriter) Barrier() error {
+	lw.lock()
+	defer lw.unlock()
+
+	lw.barrier = true
+	err := lw.flush()
+	lw.barrier = false
+	if err != nil {
+		return err
+	}
+	if err = lw.ensureLogOpen(); err != nil {
+		return err
+	}
+	if lw.tracing() {
+		lw.trace("Barrier", "path", lw.filePath, "bytes", lw.fileSizeNow)
+	}
+	return lw.syncLog()
+}
+
+// WriteSession writes p to the LogWriter just like Write, on behalf of
+// the session identified by id. When RotateOnSessionChange is true and
+// id differs from the session ID WriteSession was most recently invoked
+// with, the LogWriter first writes all completed writes in the buffer
+// to the log file, then rotates the log file, just like Rotate, so that
+// p is the first write to a new log file. An empty p neither writes
+// anything nor changes the session.
+func (lw *LogWriter) WriteSession(id string, p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := lw.throttle(len(p)); err != nil {
+		return 0, err
+	}
+
+	lw.lock()
+	defer lw.unlock()
+
+	if lw.cfg.RotateOnSessionChange && id != lw.session {
+		if lw.tracing() {
+			lw.trace("WriteSession", "from", lw.session, "to", id)
+		}
+		if err := lw.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	lw.session = id
+	return lw.write(p)
+}
+
+// syncLog commits the open log file to stable storage when it supports
+// doing so.
+func (lw *LogWriter) syncLog() error {
+	if lw.filePointer == nil {
+		return fmt.Errorf("cannot sync log file: %w", fs.ErrClosed)
+	}
+	if syncer, ok := lw.filePointer.(interface{ Sync() error }); ok {
+		if err := syncer.Sync(); err != nil {
+			return fmt.Errorf("cannot sync log file: %w", err)
+		}
+	}
+	return nil
+}
+
+// flush writes all completed writes in the buffer to the log file
+// while the lock is held.
+func (lw *LogWriter) flush() error {
+	if !lw.hasCompletedExtent() {
+		return lw.flushOSBuffer() // nothing else can be written
+	}
+	if err := lw.ensureLogOpen(); err != nil {
+		return err
+	}
+	return lw.flushCompletedExtents()
+}
+
+// Rotate writes all completed writes in the buffer to the log file,
+// then rotates the log file, so the next write goes to a new log file.
+// Rotate does nothing when the log file is empty. A final write that
+// is not newline terminated remains in the buffer to be written to the
+// new log file. When CoalesceRotations is true, Rotate also does
+// nothing when another rotation completes while it waits for the lock.
+func (lw *LogWriter) Rotate() error {
+	rotations := atomic.LoadUint32(&lw.rotationCount)
+	lw.lock()
+	defer lw.unlock()
+	if lw.cfg.NoRotate {
+		return errors.New("cannot rotate log file when NoRotate is true")
+	}
+	if lw.cfg.CoalesceRotations && atomic.LoadUint32(&lw.rotationCount) != rotations {
+		if lw.tracing() {
+			lw.trace("Rotate", "coalesced", lw.filePath)
+		}
+		return nil
+	}
+	return lw.rotate()
+}
+
+// Reopen writes all completed writes in the buffer to the log file,
+// then closes the log file and opens the file at its path again,
+// creating it when it no longer exists. After external tools such as
+// logrotate rename the log file, invoking Reopen causes subsequent
+// writes to be appended to a new log file at the original path, rather
+// than to the renamed file. A final write that is not newline
+// terminated remains in the buffer to be written to the new log file.
+func (lw *LogWriter) Reopen() error {
+	lw.lock()
+	defer lw.unlock()
+
+	if err := lw.flush(); err != nil {
+		return err
+	}
+	if lw.idleClosed {
+		// The log file is opened again by the next write.
+		return nil
+	}
+
+	if lw.tracing() {
+		lw.trace("Reopen", "path", lw.filePath)
+	}
+	err := lw.closeLog()
+	lw.resetLogFile()
+	lw.contentDefinedHash, lw.contentDefinedCut = 0, false
+	if rerr := lw.reopenLog(); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// rotate writes all completed writes in the buffer to the log file,
+// then rotates the log file when it is not empty, while the lock is
+// held.
+func (lw *LogWriter) rotate() error {
+	if err := lw.flush(); err != nil {
+		return err
+	}
+	if lw.fileSizeNow == 0 || lw.oversizeOpen {
+		// Rotating while an oversize line is incomplete would split it
+		// across log files.
+		return nil
+	}
+	if err := lw.ensureLogOpen(); err != nil {
+		return err
+	}
+	return lw.rotateLog()
+}
+
+// Stats returns a snapshot of the LogWriter's statistics.
+func (lw *LogWriter) Stats() Stats {
+	lw.lock()
+	defer lw.unlock()
+
+	stats := lw.stats
+	stats.BufferedBytes = len(lw.buf)
+	stats.BufferedExtents = len(lw.extents)
+	stats.FileSize = lw.fileSizeNow
+	stats.FileLines = lw.fileLinesNow
+	stats.FileDescriptorHeadroom = fileDescriptorHeadroom()
+	if stats.BytesLogged > 0 {
+		stats.WriteAmplification = float64(stats.BytesMoved) / float64(stats.BytesLogged)
+	}
+	return stats
+}
+
+// NextRotation returns the reason the log file will next be rotated,
+// the time at which it will be, and the number of bytes that may still
+// be written before it fills and is rotated. The LogWriter rotates log
+// files based on their size and contents rather than on a schedule, so
+// at is the current time when the rotation is already due, and the
+// zero time otherwise. The reason is one of:
+//
+//	"content": a content defined boundary was found, so the next write
+//	           begins a new log file.
+//	"none":    NoRotate is set, so the log file is never rotated.
+//	"retry":   a previous rotation failed, and is retried by the next
+//	           write.
+//	"size":    the log file is rotated once sizeRemaining more bytes are
+//	           written, although a rotation marker, a new session, or a
+//	           content defined boundary may rotate it sooner.
+//
+// The remaining size accounts for data waiting in the buffer, and is
+// zero when the rotation is already due.
+func (lw *LogWriter) NextRotation() (reason string, at time.Time, sizeRemaining int64) {
+	lw.lock()
+	defer lw.unlock()
+
+	switch {
+	case lw.cfg.NoRotate:
+		return "none", time.Time{}, math.MaxInt64
+	case lw.rotateRetry && lw.fileSizeNow > 0:
+		return "retry", lw.now(), 0
+	case lw.contentDefinedCut:
+		return "content", lw.now(), 0
+	}
+
+	sizeRemaining = lw.maxFileBytes() - lw.fileSizeNow - int64(len(lw.buf))
+	if sizeRemaining < 0 {
+		sizeRemaining = 0
+	}
+	return "size", time.Time{}, sizeRemaining
+}
@@ -0,0 +1,603 @@
+package golw
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterMethods(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "writer",
+		BufferSizeMax:  64,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	if got, want := lw.CurrentFile(), filepath.Join(directory, "writer.log"); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	// Rotating an empty log file does nothing.
+	ensureError(t, lw.Rotate())
+	if got, want := len(archivedLogs(t, directory, "writer")), 0; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("partial"))
+	ensureError(t, err)
+
+	stats := lw.Stats()
+	if got, want := stats.BufferedBytes, 14; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+	if got, want := stats.BufferedExtents, 2; got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, lw.Flush())
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\n"))
+
+	ensureError(t, lw.Rotate())
+	archives := archivedLogs(t, directory, "writer")
+	if got, want := len(archives), 1; got != want {
+		t.Fatalf("GOT: %v; WANT: %v", got, want)
+	}
+	ensureBuffer(t, readFile(t, archives[0]), []byte("line 1\n"))
+
+	_, err = lw.Write([]byte(" line\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Flush())
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("partial line\n"))
+
+	stats = lw.Stats()
+	stats.FileDescriptorHeadroom = 0 // depends on the test process
+	// Each byte is copied into the buffer and written to a log file, and
+	// the partial write is moved to the start of the buffer once.
+	want := Stats{Writes: 3, BytesWritten: 20, Rotations: 1, BytesLogged: 20, BytesMoved: 47, WriteAmplification: 2.35, FileSize: 13}
+	if got := stats; got != want {
+		t.Errorf("GOT: %#v; WANT: %#v", got, want)
+	}
+
+	ensureError(t, lw.Close())
+}
+
+func TestFlushIfOver(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "threshold",
+		BufferSizeMax:  64,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+
+	t.Run("below threshold", func(t *testing.T) {
+		ensureError(t, lw.FlushIfOver(7))
+		if got, want := lw.Stats().BufferedBytes, 7; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		if got, want := lw.Stats().FileSize, int64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+	})
+
+	t.Run("above threshold", func(t *testing.T) {
+		ensureError(t, lw.FlushIfOver(6))
+		if got, want := lw.Stats().BufferedBytes, 0; got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+		ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\n"))
+	})
+
+	ensureError(t, lw.Close())
+}
+
+func TestWriteSync(t *testing.T) {
+	for _, fsync := range []bool{false, true} {
+		t.Run(fmt.Sprintf("WriteSyncFsync %t", fsync), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "sync",
+				BufferSizeMax:  1024,
+				Directory:      directory,
+				WriteSyncFsync: fsync,
+			})
+			ensureError(t, err)
+
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), nil)
+
+			// WriteSync writes its data, along with buffered data that
+			// preceded it, to the log file immediately.
+			nw, err := lw.WriteSync([]byte("important\n"))
+			ensureError(t, err)
+			if got, want := nw, 10; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nimportant\n"))
+
+			_, err = lw.Write([]byte("line 3\n"))
+			ensureError(t, err)
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nimportant\n"))
+
+			ensureError(t, lw.Close())
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("line 1\nimportant\nline 3\n"))
+		})
+	}
+}
+
+func TestWriteSession(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 1024} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:        "session",
+				BufferSizeMax:         bufferSizeMax,
+				Directory:             directory,
+				IncludeSequence:       true,
+				MaxBytes:              32,
+				RotateOnSessionChange: true,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			writes := []struct {
+				id, line string
+			}{
+				{"a", "a 1\n"},
+				{"a", "a 2\n"},
+				{"b", "b 1\n"},
+				{"b", ""}, // empty writes change nothing
+				{"a", "a 3\n"},
+				{"c", "c 1, long enough to exceed max\n"},
+				{"c", "c 2\n"},
+			}
+			for _, w := range writes {
+				nw, err := lw.WriteSession(w.id, []byte(w.line))
+				ensureError(t, err)
+				if got, want := nw, len(w.line); got != want {
+					t.Errorf("GOT: %v; WANT: %v", got, want)
+				}
+			}
+
+			// Write does not change the session.
+			_, err = lw.Write([]byte("no session\n"))
+			ensureError(t, err)
+			_, err = lw.WriteSession("c", []byte("c 3\n"))
+			ensureError(t, err)
+			ensureError(t, lw.Close())
+
+			files := append(archivedLogs(t, directory, "session"), lw.CurrentFile())
+			want := []string{
+				"a 1\na 2\n",
+				"b 1\n",
+				"a 3\n",
+				"c 1, long enough to exceed max\n",
+				"c 2\nno session\nc 3\n",
+			}
+			if got := len(files); got != len(want) {
+				t.Fatalf("GOT: %v; WANT: %v", got, len(want))
+			}
+			for i, file := range files {
+				ensureBuffer(t, readFile(t, file), []byte(want[i]))
+			}
+		})
+	}
+}
+
+func TestNoRotate(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			directory := t.TempDir()
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "append",
+				BufferSizeMax:  bufferSizeMax,
+				Directory:      directory,
+				MaxBytes:       16,
+				NoRotate:       true,
+			})
+			ensureError(t, err)
+
+			// Write far more than MaxBytes, including a write larger
+			// than MaxBytes.
+			var want []byte
+			for i := 0; i < 100; i++ {
+				line := []byte(fmt.Sprintf("line %d\n", i))
+				if i == 50 {
+					line = []byte(strings.Repeat("x", 100) + "\n")
+				}
+				_, err = lw.Write(line)
+				ensureError(t, err)
+				want = append(want, line...)
+			}
+			ensureError(t, lw.Rotate(), "NoRotate")
+			ensureError(t, lw.Close())
+
+			if got := archivedLogs(t, directory, "append"); len(got) != 0 {
+				t.Errorf("GOT: %v; WANT: no rotated log files", got)
+			}
+			if got := lw.Stats().Rotations; got != 0 {
+				t.Errorf("GOT: %v; WANT: %v", got, 0)
+			}
+			ensureBuffer(t, readFile(t, lw.CurrentFile()), want)
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]Config{
+			"ContentDefinedRotation": {ContentDefinedRotation: true},
+			"MultiDestination":       {MultiDestination: []string{t.TempDir()}},
+			"PreallocateNext":        {PreallocateNext: true},
+			"RotateOnMarker":         {RotateOnMarker: []byte("MARK")},
+			"RotateOnSessionChange":  {RotateOnSessionChange: true},
+		}
+		for field, cfg := range cases {
+			cfg.Directory = t.TempDir()
+			cfg.NoRotate = true
+			_, err := NewLogWriter(&cfg)
+			ensureError(t, err, "NoRotate", field)
+		}
+	})
+}
+
+func TestReopen(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "reopen",
+		BufferSizeMax:  64,
+		Directory:      directory,
+		NoRotate:       true,
+	})
+	ensureError(t, err)
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("partial"))
+	ensureError(t, err)
+
+	// Simulate logrotate renaming the log file.
+	renamed := filepath.Join(directory, "reopen.log.1")
+	if err = os.Rename(lw.CurrentFile(), renamed); err != nil {
+		t.Fatal(err)
+	}
+	ensureError(t, lw.Reopen())
+
+	_, err = lw.Write([]byte(" line\n"))
+	ensureError(t, err)
+	ensureError(t, lw.Close())
+
+	ensureBuffer(t, readFile(t, renamed), []byte("line 1\n"))
+	ensureBuffer(t, readFile(t, lw.CurrentFile()), []byte("partial line\n"))
+}
+
+func TestCountLines(t *testing.T) {
+	for _, bufferSizeMax := range []int{-1, 64} {
+		t.Run(fmt.Sprintf("BufferSizeMax %d", bufferSizeMax), func(t *testing.T) {
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix: "count",
+				BufferSizeMax:  bufferSizeMax,
+				CountLines:     true,
+				Directory:      t.TempDir(),
+				MaxBytes:       50,
+			})
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			// Seven lines of seven bytes each fit in the first log
+			// file, and the remaining three are written to its
+			// replacement. One line is split across two writes.
+			for i := 0; i < 10; i++ {
+				if i == 4 {
+					_, err = lw.Write([]byte("line"))
+					ensureError(t, err)
+					_, err = lw.Write([]byte(" 4\n"))
+				} else {
+					_, err = lw.Write([]byte(fmt.Sprintf("line %d\n", i)))
+				}
+				ensureError(t, err)
+			}
+			ensureError(t, lw.Flush())
+
+			stats := lw.Stats()
+			if got, want := stats.Rotations, int64(1); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.RotatedLines, int64(7); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.FileLines, int64(3); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+
+			ensureError(t, lw.Close())
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "count",
+			BufferSizeMax:  -1,
+			Directory:      t.TempDir(),
+		})
+		ensureError(t, err)
+
+		_, err = lw.Write([]byte("line 1\nline 2\n"))
+		ensureError(t, err)
+		if got, want := lw.Stats().FileLines, int64(0); got != want {
+			t.Errorf("GOT: %v; WANT: %v", got, want)
+		}
+
+		ensureError(t, lw.Close())
+	})
+}
+
+func TestMeasureLockContention(t *testing.T) {
+	for _, measure := range []bool{false, true} {
+		t.Run(fmt.Sprintf("MeasureLockContention %t", measure), func(t *testing.T) {
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:        "contention",
+				Directory:             t.TempDir(),
+				MeasureLockContention: measure,
+			})
+			ensureError(t, err)
+
+			for i := 0; i < 100; i++ {
+				_, err = fmt.Fprintf(lw, "line %d\n", i)
+				ensureError(t, err)
+			}
+			ensureError(t, lw.Flush())
+
+			stats := lw.Stats()
+			if measure {
+				if stats.LockHoldTotal <= 0 || stats.LockHoldMax <= 0 {
+					t.Errorf("GOT: %v, %v; WANT: positive durations", stats.LockHoldTotal, stats.LockHoldMax)
+				}
+				if stats.LockHoldMax > stats.LockHoldTotal {
+					t.Errorf("GOT: %v > %v; WANT: max no more than total", stats.LockHoldMax, stats.LockHoldTotal)
+				}
+			} else if stats.LockHoldTotal != 0 || stats.LockHoldMax != 0 {
+				t.Errorf("GOT: %v, %v; WANT: zero durations", stats.LockHoldTotal, stats.LockHoldMax)
+			}
+
+			ensureError(t, lw.Close())
+		})
+	}
+}
+
+func TestWriteAmplification(t *testing.T) {
+	cases := map[string]struct {
+		cfg  Config
+		want float64
+	}{
+		"unbuffered": {
+			cfg:  Config{BufferSizeMax: -1},
+			want: 1,
+		},
+		"buffered": {
+			cfg:  Config{BufferSizeMax: 1024},
+			want: 2,
+		},
+		"buffered and streamed": {
+			cfg: Config{
+				ArchiveWriter: func(string) (io.WriteCloser, error) { return new(archiveBuffer), nil },
+				BufferSizeMax: 1024,
+			},
+			want: 3,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cfg := tc.cfg
+			cfg.BaseNamePrefix = "amplified"
+			cfg.Directory = t.TempDir()
+
+			lw, err := NewLogWriter(&cfg)
+			ensureError(t, err)
+			setClock(lw, newTestClock())
+
+			if got := lw.Stats().WriteAmplification; got != 0 {
+				t.Errorf("GOT: %v; WANT: 0 before writes", got)
+			}
+
+			for i := 0; i < 10; i++ {
+				_, err = fmt.Fprintf(lw, "line %d\n", i)
+				ensureError(t, err)
+			}
+			ensureError(t, lw.Flush())
+			if cfg.ArchiveWriter != nil {
+				ensureError(t, lw.Rotate())
+			}
+
+			stats := lw.Stats()
+			if got, want := stats.BytesLogged, int64(70); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := stats.WriteAmplification, tc.want; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			ensureError(t, lw.Close())
+		})
+	}
+}
+
+func TestNextRotation(t *testing.T) {
+	ensureNextRotation := func(t *testing.T, lw *LogWriter, wantReason string, wantRemaining int64) {
+		t.Helper()
+		reason, at, remaining := lw.NextRotation()
+		if reason != wantReason || remaining != wantRemaining {
+			t.Errorf("GOT: %q, %v; WANT: %q, %v", reason, remaining, wantReason, wantRemaining)
+		}
+		if !at.IsZero() {
+			t.Errorf("GOT: %v; WANT: zero time", at)
+		}
+	}
+
+	t.Run("size", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "next",
+			BufferSizeMax:  64,
+			Directory:      t.TempDir(),
+			MaxBytes:       100,
+		})
+		ensureError(t, err)
+		setClock(lw, newTestClock())
+		ensureNextRotation(t, lw, "size", 100)
+
+		// Buffered bytes count toward the log file they will be
+		// written to.
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		ensureNextRotation(t, lw, "size", 93)
+
+		ensureError(t, lw.Flush())
+		ensureNextRotation(t, lw, "size", 93)
+
+		ensureError(t, lw.Rotate())
+		ensureNextRotation(t, lw, "size", 100)
+
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "next",
+			BufferSizeMax:  -1,
+			Directory:      t.TempDir(),
+			MaxBytes:       100,
+		})
+		ensureError(t, err)
+		clock := newTestClock()
+		setClock(lw, clock)
+
+		_, err = lw.Write([]byte("line 1\n"))
+		ensureError(t, err)
+		lw.filePointer = closeFailingFile{lw.filePointer}
+		ensureError(t, lw.Rotate(), "injected close failure")
+
+		reason, at, remaining := lw.NextRotation()
+		if reason != "retry" || remaining != 0 || !at.Equal(clock.Now()) {
+			t.Errorf("GOT: %q, %v, %v; WANT: %q, %v, %v", reason, at, remaining, "retry", clock.Now(), 0)
+		}
+
+		// The next write retries the rotation.
+		_, err = lw.Write([]byte("line 2\n"))
+		ensureError(t, err)
+		ensureNextRotation(t, lw, "size", 93)
+		ensureError(t, lw.Close())
+	})
+
+	t.Run("none", func(t *testing.T) {
+		lw, err := NewLogWriter(&Config{
+			BaseNamePrefix: "next",
+			Directory:      t.TempDir(),
+			MaxBytes:       100,
+			NoRotate:       true,
+		})
+		ensureError(t, err)
+		ensureNextRotation(t, lw, "none", math.MaxInt64)
+		ensureError(t, lw.Close())
+	})
+}
+
+func TestCoalesceRotations(t *testing.T) {
+	for _, coalesce := range []bool{false, true} {
+		t.Run(fmt.Sprintf("CoalesceRotations %t", coalesce), func(t *testing.T) {
+			directory := t.TempDir()
+			started, release := make(chan struct{}), make(chan struct{})
+			var postRotates int
+
+			lw, err := NewLogWriter(&Config{
+				BaseNamePrefix:    "coalesce",
+				BufferSizeMax:     -1,
+				CoalesceRotations: coalesce,
+				Directory:         directory,
+				IncludeSequence:   true,
+				MaxBytes:          10,
+				PostRotate: func(path string) (string, error) {
+					// Hold the first rotation in progress until the
+					// explicit Rotate is waiting for the lock.
+					if postRotates++; postRotates == 1 {
+						close(started)
+						<-release
+					}
+					return "", nil
+				},
+			})
+			ensureError(t, err)
+
+			_, err = lw.Write([]byte("line 1\n"))
+			ensureError(t, err)
+
+			writeDone := make(chan error)
+			go func() {
+				_, err := lw.Write([]byte("line 2\n")) // rotates for MaxBytes
+				writeDone <- err
+			}()
+			<-started
+
+			rotateDone := make(chan error)
+			go func() { rotateDone <- lw.Rotate() }()
+			time.Sleep(10 * time.Millisecond) // allow Rotate to wait for the lock
+			close(release)
+
+			ensureError(t, <-writeDone)
+			ensureError(t, <-rotateDone)
+			ensureError(t, lw.Close())
+
+			want := int64(2)
+			if coalesce {
+				want = 1
+			}
+			if got := lw.Stats().Rotations; got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+			if got, want := len(archivedLogs(t, directory, "coalesce")), int(want); got != want {
+				t.Errorf("GOT: %v; WANT: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBarrier(t *testing.T) {
+	directory := t.TempDir()
+
+	lw, err := NewLogWriter(&Config{
+		BaseNamePrefix: "barrier",
+		BufferSizeMax:  64,
+		Directory:      directory,
+	})
+	ensureError(t, err)
+
+	_, err = lw.Write([]byte("line 1\n"))
+	ensureError(t, err)
+	_, err = lw.Write([]byte("line 2"))
+	ensureError(t, err)
+	ensureError(t, lw.Barrier())
+
+	// The completed line is readable through a new handle, while the
+	// incomplete one remains in the buffer.
+	ensureBuffer(t, readFile(t, filepath.Join(directory, "barrier.log")), []byte("line 1\n"))
+	if got, want := lw.Stats().BufferedBytes, len("line 2"); got != want {
+		t.Errorf("GOT: %v; WANT: %v", got, want)
+	}
+
+	ensureError(t, lw.Close())
+}